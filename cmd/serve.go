@@ -10,7 +10,10 @@ import (
 )
 
 var (
-	servePort int
+	servePort         int
+	serveCursorSecret string
+	serveDefaultCount uint64
+	serveMaxCount     uint64
 )
 
 func init() {
@@ -18,6 +21,9 @@ func init() {
 
 	flags := serveCmd.Flags()
 	flags.IntVar(&servePort, "port", defaultPort, "listen on this port")
+	flags.StringVar(&serveCursorSecret, "cursor-secret", "", "secret for signing paging cursors (random if not provided)")
+	flags.Uint64Var(&serveDefaultCount, "default-count", 500, "number of features to list when no count is given")
+	flags.Uint64Var(&serveMaxCount, "max-count", 10000, "maximum number of features to list in one response")
 
 	rootCmd.AddCommand(serveCmd)
 }
@@ -39,7 +45,14 @@ var serveCmd = &cobra.Command{
 			return migrateErr
 		}
 
-		router := handlers.New(db)
+		router, routerErr := handlers.New(db, &handlers.Config{
+			CursorSecret: []byte(serveCursorSecret),
+			DefaultCount: serveDefaultCount,
+			MaxCount:     serveMaxCount,
+		})
+		if routerErr != nil {
+			return routerErr
+		}
 
 		address := fmt.Sprintf(":%d", servePort)
 		fmt.Printf("Listening on http://localhost%s\n", address)
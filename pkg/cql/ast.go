@@ -0,0 +1,273 @@
+// Package cql parses OGC Common Query Language (CQL2) filters in the text
+// and JSON encodings and translates them into SQL.
+package cql
+
+import (
+	"time"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// GeometryProperty is the name used to refer to feature geometry
+const GeometryProperty = "geometry"
+
+// IDProperty is the name used to refer to the feature identifier
+const IDProperty = "id"
+
+// Expression is a boolean expression
+type Expression interface {
+	toSQL(*Options) (string, []interface{})
+}
+
+// Operand is a value used in a predicate
+type Operand interface {
+	operand()
+}
+
+// And is true if all of its arguments are true
+type And struct {
+	Args []Expression
+}
+
+// Or is true if any of its arguments are true
+type Or struct {
+	Args []Expression
+}
+
+// Not negates its argument
+type Not struct {
+	Arg Expression
+}
+
+// Boolean is a literal TRUE or FALSE used as a predicate
+type Boolean struct {
+	Value bool
+}
+
+// Comparison compares two scalar operands (=, <>, <, >, <=, >=)
+type Comparison struct {
+	Op    string
+	Left  Operand
+	Right Operand
+}
+
+// Like matches a character operand against a pattern using % and _ wildcards
+type Like struct {
+	Value   Operand
+	Pattern Operand
+}
+
+// Between is true if a value falls within an inclusive range
+type Between struct {
+	Value Operand
+	Low   Operand
+	High  Operand
+}
+
+// In is true if a value is equal to any value in a list
+type In struct {
+	Value Operand
+	List  []Operand
+}
+
+// IsNull is true if a property is missing or null
+type IsNull struct {
+	Value Operand
+}
+
+// Spatial is a spatial predicate (e.g. S_INTERSECTS)
+type Spatial struct {
+	Op    string
+	Left  Operand
+	Right Operand
+}
+
+// Temporal is a temporal predicate (e.g. T_DURING)
+type Temporal struct {
+	Op    string
+	Left  Operand
+	Right Operand
+}
+
+// Property refers to a feature property by name
+type Property struct {
+	Name string
+}
+
+// String is a character literal
+type String struct {
+	Value string
+}
+
+// Number is a numeric literal
+type Number struct {
+	Value float64
+}
+
+// Bool is a boolean literal used as an operand
+type Bool struct {
+	Value bool
+}
+
+// Timestamp is an instant
+type Timestamp struct {
+	Value time.Time
+}
+
+// Date is a calendar date
+type Date struct {
+	Value time.Time
+}
+
+// Interval is a time interval.  The Start and End are a Timestamp, Date,
+// Property, or nil when unbounded.
+type Interval struct {
+	Start Operand
+	End   Operand
+}
+
+// Geometry is a geometry literal
+type Geometry struct {
+	Value *geojson.Geometry
+}
+
+// BBox is a bounding box literal (minx, miny, maxx, maxy)
+type BBox struct {
+	Values []float64
+}
+
+func (*Property) operand()  {}
+func (*String) operand()    {}
+func (*Number) operand()    {}
+func (*Bool) operand()      {}
+func (*Timestamp) operand() {}
+func (*Date) operand()      {}
+func (*Interval) operand()  {}
+func (*Geometry) operand()  {}
+func (*BBox) operand()      {}
+
+var comparisonOps = map[string]bool{
+	"=":  true,
+	"<>": true,
+	"<":  true,
+	">":  true,
+	"<=": true,
+	">=": true,
+}
+
+// spatialOps maps CQL2 spatial predicates to PostGIS functions
+var spatialOps = map[string]string{
+	"S_INTERSECTS": "ST_Intersects",
+	"S_EQUALS":     "ST_Equals",
+	"S_DISJOINT":   "ST_Disjoint",
+	"S_TOUCHES":    "ST_Touches",
+	"S_WITHIN":     "ST_Within",
+	"S_OVERLAPS":   "ST_Overlaps",
+	"S_CROSSES":    "ST_Crosses",
+	"S_CONTAINS":   "ST_Contains",
+}
+
+// bound identifies the start or end of the left (a) or right (b) operand of
+// a temporal predicate
+type bound int
+
+const (
+	aStart bound = iota
+	aEnd
+	bStart
+	bEnd
+)
+
+// temporalCondition compares the bounds of two intervals
+type temporalCondition struct {
+	left  bound
+	op    string
+	right bound
+}
+
+// temporalRelation is true if all conditions are met (or none are if negated)
+type temporalRelation struct {
+	conditions []temporalCondition
+	negate     bool
+}
+
+var intersects = []temporalCondition{{aStart, "<=", bEnd}, {aEnd, ">=", bStart}}
+
+// temporalOps maps CQL2 temporal predicates to relations between intervals
+var temporalOps = map[string]temporalRelation{
+	"T_AFTER":        {conditions: []temporalCondition{{aStart, ">", bEnd}}},
+	"T_BEFORE":       {conditions: []temporalCondition{{aEnd, "<", bStart}}},
+	"T_CONTAINS":     {conditions: []temporalCondition{{aStart, "<", bStart}, {aEnd, ">", bEnd}}},
+	"T_DISJOINT":     {conditions: intersects, negate: true},
+	"T_DURING":       {conditions: []temporalCondition{{aStart, ">", bStart}, {aEnd, "<", bEnd}}},
+	"T_EQUALS":       {conditions: []temporalCondition{{aStart, "=", bStart}, {aEnd, "=", bEnd}}},
+	"T_FINISHEDBY":   {conditions: []temporalCondition{{aStart, "<", bStart}, {aEnd, "=", bEnd}}},
+	"T_FINISHES":     {conditions: []temporalCondition{{aStart, ">", bStart}, {aEnd, "=", bEnd}}},
+	"T_INTERSECTS":   {conditions: intersects},
+	"T_MEETS":        {conditions: []temporalCondition{{aEnd, "=", bStart}}},
+	"T_METBY":        {conditions: []temporalCondition{{aStart, "=", bEnd}}},
+	"T_OVERLAPPEDBY": {conditions: []temporalCondition{{aStart, ">", bStart}, {aStart, "<", bEnd}, {aEnd, ">", bEnd}}},
+	"T_OVERLAPS":     {conditions: []temporalCondition{{aStart, "<", bStart}, {aEnd, ">", bStart}, {aEnd, "<", bEnd}}},
+	"T_STARTEDBY":    {conditions: []temporalCondition{{aStart, "=", bStart}, {aEnd, ">", bEnd}}},
+	"T_STARTS":       {conditions: []temporalCondition{{aStart, "=", bStart}, {aEnd, "<", bEnd}}},
+}
+
+// Properties returns the names of all properties referenced in an expression
+func Properties(expr Expression) []string {
+	seen := map[string]bool{}
+	names := []string{}
+	add := func(operands ...Operand) {
+		for _, operand := range operands {
+			switch o := operand.(type) {
+			case *Property:
+				if !seen[o.Name] {
+					seen[o.Name] = true
+					names = append(names, o.Name)
+				}
+			case *Interval:
+				if p, ok := o.Start.(*Property); ok && !seen[p.Name] {
+					seen[p.Name] = true
+					names = append(names, p.Name)
+				}
+				if p, ok := o.End.(*Property); ok && !seen[p.Name] {
+					seen[p.Name] = true
+					names = append(names, p.Name)
+				}
+			}
+		}
+	}
+
+	var walk func(Expression)
+	walk = func(expr Expression) {
+		switch e := expr.(type) {
+		case *And:
+			for _, arg := range e.Args {
+				walk(arg)
+			}
+		case *Or:
+			for _, arg := range e.Args {
+				walk(arg)
+			}
+		case *Not:
+			walk(e.Arg)
+		case *Comparison:
+			add(e.Left, e.Right)
+		case *Like:
+			add(e.Value, e.Pattern)
+		case *Between:
+			add(e.Value, e.Low, e.High)
+		case *In:
+			add(e.Value)
+			add(e.List...)
+		case *IsNull:
+			add(e.Value)
+		case *Spatial:
+			add(e.Left, e.Right)
+		case *Temporal:
+			add(e.Left, e.Right)
+		}
+	}
+	walk(expr)
+
+	return names
+}
@@ -0,0 +1,156 @@
+package cql
+
+import (
+	"fmt"
+)
+
+// check makes sure that the operands of each predicate have the right types
+func check(expr Expression) error {
+	switch e := expr.(type) {
+	case *And:
+		for _, arg := range e.Args {
+			if err := check(arg); err != nil {
+				return err
+			}
+		}
+	case *Or:
+		for _, arg := range e.Args {
+			if err := check(arg); err != nil {
+				return err
+			}
+		}
+	case *Not:
+		return check(e.Arg)
+	case *Boolean:
+		return nil
+	case *Comparison:
+		if !comparisonOps[e.Op] {
+			return fmt.Errorf("unsupported comparison operator '%s'", e.Op)
+		}
+		if err := checkScalar(e.Op, e.Left, e.Right); err != nil {
+			return err
+		}
+		return checkTemporalID(e.Op, e.Left, e.Right)
+	case *Like:
+		for _, operand := range []Operand{e.Value, e.Pattern} {
+			switch o := operand.(type) {
+			case *String:
+			case *Property:
+				if o.Name == GeometryProperty {
+					return fmt.Errorf("LIKE cannot be used with the '%s' property", GeometryProperty)
+				}
+			default:
+				return fmt.Errorf("LIKE requires character operands")
+			}
+		}
+	case *Between:
+		if err := checkScalar("BETWEEN", e.Value, e.Low, e.High); err != nil {
+			return err
+		}
+		return checkTemporalID("BETWEEN", e.Value, e.Low, e.High)
+	case *In:
+		if len(e.List) == 0 {
+			return fmt.Errorf("IN requires a list of values")
+		}
+		if err := checkScalar("IN", e.Value); err != nil {
+			return err
+		}
+		if err := checkScalar("IN", e.List...); err != nil {
+			return err
+		}
+		return checkTemporalID("IN", append([]Operand{e.Value}, e.List...)...)
+	case *IsNull:
+		if _, ok := e.Value.(*Property); !ok {
+			return fmt.Errorf("IS NULL requires a property")
+		}
+	case *Spatial:
+		if _, ok := spatialOps[e.Op]; !ok {
+			return fmt.Errorf("unsupported spatial predicate '%s'", e.Op)
+		}
+		for _, operand := range []Operand{e.Left, e.Right} {
+			switch o := operand.(type) {
+			case *Geometry, *BBox:
+			case *Property:
+				if o.Name != GeometryProperty {
+					return fmt.Errorf("%s cannot be used with the '%s' property", e.Op, o.Name)
+				}
+			default:
+				return fmt.Errorf("%s requires spatial operands", e.Op)
+			}
+		}
+	case *Temporal:
+		if _, ok := temporalOps[e.Op]; !ok {
+			return fmt.Errorf("unsupported temporal predicate '%s'", e.Op)
+		}
+		for _, operand := range []Operand{e.Left, e.Right} {
+			switch o := operand.(type) {
+			case *Timestamp, *Date:
+			case *Property:
+				if err := checkTemporalProperty(e.Op, o); err != nil {
+					return err
+				}
+			case *Interval:
+				if err := checkInstant(o.Start); err != nil {
+					return err
+				}
+				if err := checkInstant(o.End); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("%s requires temporal operands", e.Op)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported expression")
+	}
+
+	return nil
+}
+
+func checkScalar(op string, operands ...Operand) error {
+	for _, operand := range operands {
+		switch o := operand.(type) {
+		case *String, *Number, *Bool, *Timestamp, *Date:
+		case *Property:
+			if o.Name == GeometryProperty {
+				return fmt.Errorf("%s cannot be used with the '%s' property", op, GeometryProperty)
+			}
+		default:
+			return fmt.Errorf("%s requires scalar operands", op)
+		}
+	}
+	return nil
+}
+
+// checkTemporalProperty makes sure a property can be compared as a time
+func checkTemporalProperty(op string, property *Property) error {
+	if property.Name == IDProperty {
+		return fmt.Errorf("%s cannot be used with the '%s' property", op, IDProperty)
+	}
+	return checkScalar(op, property)
+}
+
+// checkTemporalID makes sure the id property is not compared with dates or
+// timestamps
+func checkTemporalID(op string, operands ...Operand) error {
+	if scalarMode(operands...) == jsonMode {
+		return nil
+	}
+	for _, operand := range operands {
+		if o, ok := operand.(*Property); ok && o.Name == IDProperty {
+			return fmt.Errorf("%s cannot compare the '%s' property with a date or timestamp", op, IDProperty)
+		}
+	}
+	return nil
+}
+
+func checkInstant(operand Operand) error {
+	switch o := operand.(type) {
+	case nil, *Timestamp, *Date:
+		return nil
+	case *Property:
+		return checkTemporalProperty("INTERVAL", o)
+	default:
+		return fmt.Errorf("INTERVAL bounds must be a date, timestamp, property, or '..'")
+	}
+}
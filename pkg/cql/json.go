@@ -0,0 +1,240 @@
+package cql
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// jsonNode is an object in the CQL2 JSON encoding
+type jsonNode struct {
+	Op        string            `json:"op"`
+	Args      []json.RawMessage `json:"args"`
+	Property  *string           `json:"property"`
+	Timestamp *string           `json:"timestamp"`
+	Date      *string           `json:"date"`
+	Interval  []json.RawMessage `json:"interval"`
+	BBox      []float64         `json:"bbox"`
+	Type      string            `json:"type"`
+}
+
+// ParseJSON parses a filter in the CQL2 JSON encoding
+func ParseJSON(data []byte) (Expression, error) {
+	expr, err := parseJSONExpression(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := check(expr); err != nil {
+		return nil, err
+	}
+
+	return expr, nil
+}
+
+func parseJSONExpression(data []byte) (Expression, error) {
+	trimmed := bytes.TrimSpace(data)
+	if bytes.Equal(trimmed, []byte("true")) || bytes.Equal(trimmed, []byte("false")) {
+		return &Boolean{Value: bytes.Equal(trimmed, []byte("true"))}, nil
+	}
+
+	node := &jsonNode{}
+	if err := json.Unmarshal(data, node); err != nil {
+		return nil, fmt.Errorf("expected an object with 'op' and 'args': %s", err)
+	}
+	if node.Op == "" {
+		return nil, errors.New("expected an object with 'op' and 'args'")
+	}
+
+	op := strings.ToLower(node.Op)
+	switch op {
+	case "and", "or":
+		if len(node.Args) < 2 {
+			return nil, fmt.Errorf("'%s' requires at least two arguments", op)
+		}
+		args := make([]Expression, len(node.Args))
+		for i, raw := range node.Args {
+			arg, err := parseJSONExpression(raw)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = arg
+		}
+		if op == "and" {
+			return &And{Args: args}, nil
+		}
+		return &Or{Args: args}, nil
+
+	case "not":
+		if len(node.Args) != 1 {
+			return nil, errors.New("'not' requires one argument")
+		}
+		arg, err := parseJSONExpression(node.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Arg: arg}, nil
+
+	case "isnull":
+		operands, err := parseJSONOperands(op, node.Args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return &IsNull{Value: operands[0]}, nil
+
+	case "like":
+		operands, err := parseJSONOperands(op, node.Args, 2)
+		if err != nil {
+			return nil, err
+		}
+		return &Like{Value: operands[0], Pattern: operands[1]}, nil
+
+	case "between":
+		operands, err := parseJSONOperands(op, node.Args, 3)
+		if err != nil {
+			return nil, err
+		}
+		return &Between{Value: operands[0], Low: operands[1], High: operands[2]}, nil
+
+	case "in":
+		if len(node.Args) != 2 {
+			return nil, errors.New("'in' requires two arguments")
+		}
+		value, err := parseJSONOperand(node.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		items := []json.RawMessage{}
+		if err := json.Unmarshal(node.Args[1], &items); err != nil {
+			return nil, errors.New("the second argument to 'in' must be an array")
+		}
+		list := make([]Operand, len(items))
+		for i, item := range items {
+			operand, err := parseJSONOperand(item)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = operand
+		}
+		return &In{Value: value, List: list}, nil
+	}
+
+	upper := strings.ToUpper(op)
+	if comparisonOps[op] {
+		operands, err := parseJSONOperands(op, node.Args, 2)
+		if err != nil {
+			return nil, err
+		}
+		return &Comparison{Op: op, Left: operands[0], Right: operands[1]}, nil
+	}
+
+	if _, ok := spatialOps[upper]; ok {
+		operands, err := parseJSONOperands(op, node.Args, 2)
+		if err != nil {
+			return nil, err
+		}
+		return &Spatial{Op: upper, Left: operands[0], Right: operands[1]}, nil
+	}
+
+	if _, ok := temporalOps[upper]; ok {
+		operands, err := parseJSONOperands(op, node.Args, 2)
+		if err != nil {
+			return nil, err
+		}
+		return &Temporal{Op: upper, Left: operands[0], Right: operands[1]}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported operator '%s'", node.Op)
+}
+
+func parseJSONOperands(op string, args []json.RawMessage, count int) ([]Operand, error) {
+	if len(args) != count {
+		return nil, fmt.Errorf("'%s' requires %d argument(s)", op, count)
+	}
+	operands := make([]Operand, count)
+	for i, arg := range args {
+		operand, err := parseJSONOperand(arg)
+		if err != nil {
+			return nil, err
+		}
+		operands[i] = operand
+	}
+	return operands, nil
+}
+
+func parseJSONOperand(data []byte) (Operand, error) {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+
+	switch v := value.(type) {
+	case string:
+		return &String{Value: v}, nil
+	case float64:
+		return &Number{Value: v}, nil
+	case bool:
+		return &Bool{Value: v}, nil
+	case map[string]interface{}:
+		// handled below
+	default:
+		return nil, fmt.Errorf("unsupported operand %s", data)
+	}
+
+	node := &jsonNode{}
+	if err := json.Unmarshal(data, node); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case node.Property != nil:
+		return &Property{Name: *node.Property}, nil
+	case node.Timestamp != nil:
+		return parseTimestamp(*node.Timestamp)
+	case node.Date != nil:
+		return parseDate(*node.Date)
+	case node.Interval != nil:
+		if len(node.Interval) != 2 {
+			return nil, errors.New("an interval must have a start and end")
+		}
+		bounds := make([]Operand, 2)
+		for i, raw := range node.Interval {
+			var s string
+			if err := json.Unmarshal(raw, &s); err == nil {
+				bound, err := parseBound(s)
+				if err != nil {
+					return nil, err
+				}
+				bounds[i] = bound
+				continue
+			}
+			bound, err := parseJSONOperand(raw)
+			if err != nil {
+				return nil, err
+			}
+			bounds[i] = bound
+		}
+		return &Interval{Start: bounds[0], End: bounds[1]}, nil
+	case node.BBox != nil:
+		if len(node.BBox) != 4 && len(node.BBox) != 6 {
+			return nil, errors.New("a bbox must have 4 or 6 values")
+		}
+		values := node.BBox
+		if len(values) == 6 {
+			values = []float64{values[0], values[1], values[3], values[4]}
+		}
+		return &BBox{Values: values}, nil
+	case node.Type != "":
+		geometry, err := geojson.UnmarshalGeometry(data)
+		if err != nil {
+			return nil, err
+		}
+		return &Geometry{Value: geometry}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported operand %s", data)
+}
@@ -0,0 +1,94 @@
+package cql
+
+import (
+	"testing"
+	"time"
+
+	geojson "github.com/paulmach/go.geojson"
+	"github.com/stretchr/testify/assert"
+)
+
+func pointGeometry(x, y float64) *geojson.Geometry {
+	return geojson.NewPointGeometry([]float64{x, y})
+}
+
+func TestParseJSON(t *testing.T) {
+	assert := assert.New(t)
+	cases := []struct {
+		input    string
+		expected Expression
+	}{
+		{
+			`{"op": "=", "args": [{"property": "name"}, "France"]}`,
+			&Comparison{Op: "=", Left: &Property{Name: "name"}, Right: &String{Value: "France"}},
+		},
+		{
+			`{"op": "and", "args": [
+				{"op": "like", "args": [{"property": "name"}, "Fr%"]},
+				{"op": "not", "args": [{"op": "isNull", "args": [{"property": "pop"}]}]}
+			]}`,
+			&And{Args: []Expression{
+				&Like{Value: &Property{Name: "name"}, Pattern: &String{Value: "Fr%"}},
+				&Not{Arg: &IsNull{Value: &Property{Name: "pop"}}},
+			}},
+		},
+		{
+			`{"op": "between", "args": [{"property": "pop"}, 1, 2]}`,
+			&Between{Value: &Property{Name: "pop"}, Low: &Number{Value: 1}, High: &Number{Value: 2}},
+		},
+		{
+			`{"op": "in", "args": [{"property": "name"}, ["a", "b"]]}`,
+			&In{Value: &Property{Name: "name"}, List: []Operand{&String{Value: "a"}, &String{Value: "b"}}},
+		},
+		{
+			`{"op": "s_intersects", "args": [{"property": "geometry"}, {"type": "Point", "coordinates": [1, 2]}]}`,
+			&Spatial{Op: "S_INTERSECTS", Left: &Property{Name: "geometry"}, Right: &Geometry{Value: pointGeometry(1, 2)}},
+		},
+		{
+			`{"op": "s_within", "args": [{"property": "geometry"}, {"bbox": [-10, -20, 10, 20]}]}`,
+			&Spatial{Op: "S_WITHIN", Left: &Property{Name: "geometry"}, Right: &BBox{Values: []float64{-10, -20, 10, 20}}},
+		},
+		{
+			`{"op": "t_during", "args": [{"property": "updated"}, {"interval": ["2020-01-01T12:00:00Z", ".."]}]}`,
+			&Temporal{Op: "T_DURING", Left: &Property{Name: "updated"}, Right: &Interval{
+				Start: &Timestamp{Value: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)},
+			}},
+		},
+		{
+			`{"op": "t_after", "args": [{"property": "updated"}, {"date": "2020-01-01"}]}`,
+			&Temporal{Op: "T_AFTER", Left: &Property{Name: "updated"}, Right: &Date{Value: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		},
+		{
+			`true`,
+			&Boolean{Value: true},
+		},
+	}
+
+	for i, c := range cases {
+		expr, err := ParseJSON([]byte(c.input))
+		if assert.Nil(err, "expected no error for case %d", i) {
+			assert.Equal(c.expected, expr, "unexpected expression for case %d", i)
+		}
+	}
+}
+
+func TestParseJSONInvalid(t *testing.T) {
+	assert := assert.New(t)
+	cases := []string{
+		``,
+		`{}`,
+		`"name"`,
+		`{"op": "=", "args": [{"property": "name"}]}`,
+		`{"op": "~", "args": [{"property": "name"}, "a"]}`,
+		`{"op": "and", "args": [true]}`,
+		`{"op": "in", "args": [{"property": "name"}, "a"]}`,
+		`{"op": "s_intersects", "args": [{"property": "name"}, {"bbox": [1, 2, 3, 4]}]}`,
+		`{"op": "s_intersects", "args": [{"property": "geometry"}, {"bbox": [1, 2, 3]}]}`,
+		`{"op": "t_after", "args": [{"property": "updated"}, {"timestamp": "yesterday"}]}`,
+	}
+
+	for _, input := range cases {
+		_, err := ParseJSON([]byte(input))
+		assert.NotNil(err, "expected an error for '%s'", input)
+	}
+}
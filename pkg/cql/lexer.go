@@ -0,0 +1,142 @@
+package cql
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenQuotedIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+	tokenLeftParen
+	tokenRightParen
+	tokenComma
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+// is checks for an unquoted identifier (case insensitive)
+func (t token) is(keyword string) bool {
+	return t.kind == tokenIdent && strings.EqualFold(t.value, keyword)
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of input"
+	}
+	return fmt.Sprintf("'%s'", t.value)
+}
+
+// lex splits CQL2 text into tokens
+func lex(input string) ([]token, error) {
+	tokens := []token{}
+	runes := []rune(input)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLeftParen, value: "(", pos: start})
+			i++
+
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRightParen, value: ")", pos: start})
+			i++
+
+		case r == ',':
+			tokens = append(tokens, token{kind: tokenComma, value: ",", pos: start})
+			i++
+
+		case r == '=':
+			tokens = append(tokens, token{kind: tokenOperator, value: "=", pos: start})
+			i++
+
+		case r == '<' || r == '>':
+			op := string(r)
+			if i+1 < len(runes) && (runes[i+1] == '=' || (r == '<' && runes[i+1] == '>')) {
+				op += string(runes[i+1])
+			}
+			tokens = append(tokens, token{kind: tokenOperator, value: op, pos: start})
+			i += len(op)
+
+		case r == '\'':
+			var value strings.Builder
+			i++
+			for {
+				if i >= len(runes) {
+					return nil, fmt.Errorf("unterminated string starting at position %d", start)
+				}
+				if runes[i] == '\'' {
+					// two single quotes escape a quote
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						value.WriteRune('\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				value.WriteRune(runes[i])
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenString, value: value.String(), pos: start})
+
+		case r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("unterminated identifier starting at position %d", start)
+			}
+			tokens = append(tokens, token{kind: tokenQuotedIdent, value: string(runes[i+1 : end]), pos: start})
+			i = end + 1
+
+		case unicode.IsDigit(r) || r == '.' || ((r == '-' || r == '+') && i+1 < len(runes) && (unicode.IsDigit(runes[i+1]) || runes[i+1] == '.')):
+			i++
+			for i < len(runes) {
+				c := runes[i]
+				if unicode.IsDigit(c) || c == '.' {
+					i++
+				} else if (c == 'e' || c == 'E') && i+1 < len(runes) {
+					i++
+					if runes[i] == '-' || runes[i] == '+' {
+						i++
+					}
+				} else {
+					break
+				}
+			}
+			tokens = append(tokens, token{kind: tokenNumber, value: string(runes[start:i]), pos: start})
+
+		case unicode.IsLetter(r) || r == '_':
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == ':' || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, value: string(runes[start:i]), pos: start})
+
+		default:
+			return nil, fmt.Errorf("unexpected character '%c' at position %d", r, start)
+		}
+	}
+
+	tokens = append(tokens, token{kind: tokenEOF, pos: len(runes)})
+	return tokens, nil
+}
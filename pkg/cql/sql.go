@@ -0,0 +1,284 @@
+package cql
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tschaub/pgfs/pkg/geo"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+)
+
+// Options configure the translation of an expression into SQL
+type Options struct {
+	// ID is the feature identifier column
+	ID string
+	// Geometry is the geometry column
+	Geometry string
+	// Properties is the JSONB properties column
+	Properties string
+	// SRID of the geometry column
+	SRID int
+	// CRS of geometry literals
+	CRS geo.CRS
+}
+
+// filter implements the squirrel Sqlizer interface for an expression
+type filter struct {
+	expr    Expression
+	options *Options
+}
+
+// Where returns a SQL condition for the expression.  Literal values and
+// property names are bound as parameters.
+func Where(expr Expression, options *Options) sq.Sqlizer {
+	return &filter{expr: expr, options: options}
+}
+
+func (f *filter) ToSql() (string, []interface{}, error) {
+	sql, args := f.expr.toSQL(f.options)
+	return sql, args, nil
+}
+
+// fragment is a piece of SQL and its arguments
+type fragment struct {
+	sql  string
+	args []interface{}
+}
+
+func join(fragments []fragment, sep string) (string, []interface{}) {
+	parts := make([]string, len(fragments))
+	args := []interface{}{}
+	for i, f := range fragments {
+		parts[i] = f.sql
+		args = append(args, f.args...)
+	}
+	return strings.Join(parts, sep), args
+}
+
+func (e *And) toSQL(options *Options) (string, []interface{}) {
+	return junction(e.Args, " AND ", options)
+}
+
+func (e *Or) toSQL(options *Options) (string, []interface{}) {
+	return junction(e.Args, " OR ", options)
+}
+
+func junction(exprs []Expression, sep string, options *Options) (string, []interface{}) {
+	fragments := make([]fragment, len(exprs))
+	for i, expr := range exprs {
+		sql, args := expr.toSQL(options)
+		fragments[i] = fragment{sql: sql, args: args}
+	}
+	sql, args := join(fragments, sep)
+	return "(" + sql + ")", args
+}
+
+func (e *Not) toSQL(options *Options) (string, []interface{}) {
+	sql, args := e.Arg.toSQL(options)
+	return fmt.Sprintf("NOT (%s)", sql), args
+}
+
+func (e *Boolean) toSQL(options *Options) (string, []interface{}) {
+	if e.Value {
+		return "TRUE", nil
+	}
+	return "FALSE", nil
+}
+
+// mode determines how scalar operands are cast for comparison
+type mode int
+
+const (
+	// jsonMode compares JSONB values so that mixed types never fail
+	jsonMode mode = iota
+	textMode
+	timestampMode
+	dateMode
+)
+
+// scalarMode picks a mode based on the literals being compared
+func scalarMode(operands ...Operand) mode {
+	m := jsonMode
+	for _, operand := range operands {
+		switch operand.(type) {
+		case *Timestamp:
+			m = timestampMode
+		case *Date:
+			if m != timestampMode {
+				m = dateMode
+			}
+		}
+	}
+	return m
+}
+
+func scalar(operand Operand, m mode, options *Options) fragment {
+	switch o := operand.(type) {
+	case *Property:
+		if o.Name == IDProperty {
+			switch m {
+			case jsonMode:
+				return fragment{sql: fmt.Sprintf("to_jsonb(%s)", options.ID)}
+			case textMode:
+				return fragment{sql: fmt.Sprintf("%s::text", options.ID)}
+			}
+		}
+		switch m {
+		case jsonMode:
+			return fragment{sql: fmt.Sprintf("%s->?", options.Properties), args: []interface{}{o.Name}}
+		case textMode:
+			return fragment{sql: fmt.Sprintf("%s->>?", options.Properties), args: []interface{}{o.Name}}
+		// property values that are not dates or timestamps are null (see the
+		// pgfs_date and pgfs_timestamptz functions created by the migrations)
+		case dateMode:
+			return fragment{sql: fmt.Sprintf("pgfs_date(%s->>?)", options.Properties), args: []interface{}{o.Name}}
+		}
+		return fragment{sql: fmt.Sprintf("pgfs_timestamptz(%s->>?)", options.Properties), args: []interface{}{o.Name}}
+
+	case *String:
+		switch m {
+		case jsonMode:
+			return fragment{sql: "to_jsonb(?::text)", args: []interface{}{o.Value}}
+		case dateMode:
+			return fragment{sql: "?::date", args: []interface{}{o.Value}}
+		case timestampMode:
+			return fragment{sql: "?::timestamptz", args: []interface{}{o.Value}}
+		}
+		return fragment{sql: "?::text", args: []interface{}{o.Value}}
+
+	case *Number:
+		if m == jsonMode {
+			return fragment{sql: "to_jsonb(?::numeric)", args: []interface{}{o.Value}}
+		}
+		return fragment{sql: "?::numeric", args: []interface{}{o.Value}}
+
+	case *Bool:
+		if m == jsonMode {
+			return fragment{sql: "to_jsonb(?::boolean)", args: []interface{}{o.Value}}
+		}
+		return fragment{sql: "?::boolean", args: []interface{}{o.Value}}
+
+	case *Timestamp:
+		return fragment{sql: "?::timestamptz", args: []interface{}{o.Value}}
+
+	case *Date:
+		if m == dateMode {
+			return fragment{sql: "?::date", args: []interface{}{o.Value.Format(dateLayout)}}
+		}
+		return fragment{sql: "?::timestamptz", args: []interface{}{o.Value}}
+	}
+
+	panic(fmt.Sprintf("unexpected scalar operand %T", operand))
+}
+
+func (e *Comparison) toSQL(options *Options) (string, []interface{}) {
+	m := scalarMode(e.Left, e.Right)
+	sql, args := join([]fragment{scalar(e.Left, m, options), scalar(e.Right, m, options)}, fmt.Sprintf(" %s ", e.Op))
+	return "(" + sql + ")", args
+}
+
+func (e *Like) toSQL(options *Options) (string, []interface{}) {
+	sql, args := join([]fragment{scalar(e.Value, textMode, options), scalar(e.Pattern, textMode, options)}, " LIKE ")
+	return "(" + sql + ")", args
+}
+
+func (e *Between) toSQL(options *Options) (string, []interface{}) {
+	m := scalarMode(e.Value, e.Low, e.High)
+	value := scalar(e.Value, m, options)
+	sql, args := join([]fragment{scalar(e.Low, m, options), scalar(e.High, m, options)}, " AND ")
+	return fmt.Sprintf("(%s BETWEEN %s)", value.sql, sql), append(value.args, args...)
+}
+
+func (e *In) toSQL(options *Options) (string, []interface{}) {
+	m := scalarMode(append([]Operand{e.Value}, e.List...)...)
+	value := scalar(e.Value, m, options)
+	fragments := make([]fragment, len(e.List))
+	for i, item := range e.List {
+		fragments[i] = scalar(item, m, options)
+	}
+	sql, args := join(fragments, ", ")
+	return fmt.Sprintf("(%s IN (%s))", value.sql, sql), append(value.args, args...)
+}
+
+func (e *IsNull) toSQL(options *Options) (string, []interface{}) {
+	p := e.Value.(*Property)
+	if p.Name == IDProperty || p.Name == GeometryProperty {
+		return "FALSE", nil
+	}
+	return fmt.Sprintf("(COALESCE(jsonb_typeof(%s->?), 'null') = 'null')", options.Properties), []interface{}{p.Name}
+}
+
+func spatial(operand Operand, options *Options) fragment {
+	switch o := operand.(type) {
+	case *Property:
+		return fragment{sql: options.Geometry}
+	case *Geometry:
+		data, _ := o.Value.MarshalJSON()
+		geometry := fmt.Sprintf("ST_SetSRID(ST_GeomFromGeoJSON(?), %d)", options.CRS.SRID)
+		if options.CRS.LatLon {
+			geometry = fmt.Sprintf("ST_FlipCoordinates(%s)", geometry)
+		}
+		return fragment{sql: fmt.Sprintf("ST_Transform(%s, %d)", geometry, options.SRID), args: []interface{}{string(data)}}
+	case *BBox:
+		v := o.Values
+		if options.CRS.LatLon {
+			v = []float64{v[1], v[0], v[3], v[2]}
+		}
+		// latitudes outside the geometry column CRS cannot be transformed
+		box := (&geo.BBox{MinX: v[0], MinY: v[1], MaxX: v[2], MaxY: v[3], CRS: options.CRS}).Clamp(geo.CRS{SRID: options.SRID})
+		return fragment{
+			sql:  fmt.Sprintf("ST_Transform(ST_MakeEnvelope(?, ?, ?, ?, %d), %d)", options.CRS.SRID, options.SRID),
+			args: []interface{}{box.MinX, box.MinY, box.MaxX, box.MaxY},
+		}
+	}
+
+	panic(fmt.Sprintf("unexpected spatial operand %T", operand))
+}
+
+func (e *Spatial) toSQL(options *Options) (string, []interface{}) {
+	sql, args := join([]fragment{spatial(e.Left, options), spatial(e.Right, options)}, ", ")
+	return fmt.Sprintf("%s(%s)", spatialOps[e.Op], sql), args
+}
+
+// interval returns the start and end of a temporal operand
+func interval(operand Operand, options *Options) (fragment, fragment) {
+	switch o := operand.(type) {
+	case *Interval:
+		start := fragment{sql: "'-infinity'::timestamptz"}
+		if o.Start != nil {
+			start, _ = interval(o.Start, options)
+		}
+		end := fragment{sql: "'infinity'::timestamptz"}
+		if o.End != nil {
+			_, end = interval(o.End, options)
+		}
+		return start, end
+	case *Date:
+		start := fragment{sql: "?::timestamptz", args: []interface{}{o.Value}}
+		end := fragment{sql: "?::timestamptz", args: []interface{}{o.Value.AddDate(0, 0, 1).Add(-time.Microsecond)}}
+		return start, end
+	}
+
+	f := scalar(operand, timestampMode, options)
+	return f, f
+}
+
+func (e *Temporal) toSQL(options *Options) (string, []interface{}) {
+	bounds := make([]fragment, 4)
+	bounds[aStart], bounds[aEnd] = interval(e.Left, options)
+	bounds[bStart], bounds[bEnd] = interval(e.Right, options)
+
+	relation := temporalOps[e.Op]
+	fragments := make([]fragment, len(relation.conditions))
+	for i, condition := range relation.conditions {
+		sql, args := join([]fragment{bounds[condition.left], bounds[condition.right]}, fmt.Sprintf(" %s ", condition.op))
+		fragments[i] = fragment{sql: sql, args: args}
+	}
+
+	sql, args := join(fragments, " AND ")
+	if relation.negate {
+		return fmt.Sprintf("NOT (%s)", sql), args
+	}
+	return "(" + sql + ")", args
+}
@@ -0,0 +1,116 @@
+package cql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tschaub/pgfs/pkg/geo"
+)
+
+var testOptions = &Options{
+	ID:         "features.id",
+	Geometry:   "features.geometry",
+	Properties: "features.properties",
+	SRID:       4326,
+	CRS:        geo.CRS84,
+}
+
+func TestWhere(t *testing.T) {
+	assert := assert.New(t)
+	day := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		input string
+		sql   string
+		args  []interface{}
+	}{
+		{
+			"name = 'France' AND pop > 10",
+			"((features.properties->? = to_jsonb(?::text)) AND (features.properties->? > to_jsonb(?::numeric)))",
+			[]interface{}{"name", "France", "pop", float64(10)},
+		},
+		{
+			"id = 'abc'",
+			"(to_jsonb(features.id) = to_jsonb(?::text))",
+			[]interface{}{"abc"},
+		},
+		{
+			"name NOT LIKE 'Fr%'",
+			"NOT ((features.properties->>? LIKE ?::text))",
+			[]interface{}{"name", "Fr%"},
+		},
+		{
+			"pop BETWEEN 1 AND 2",
+			"(features.properties->? BETWEEN to_jsonb(?::numeric) AND to_jsonb(?::numeric))",
+			[]interface{}{"pop", float64(1), float64(2)},
+		},
+		{
+			"name IN ('a', 'b')",
+			"(features.properties->? IN (to_jsonb(?::text), to_jsonb(?::text)))",
+			[]interface{}{"name", "a", "b"},
+		},
+		{
+			"name IS NULL",
+			"(COALESCE(jsonb_typeof(features.properties->?), 'null') = 'null')",
+			[]interface{}{"name"},
+		},
+		{
+			"updated = DATE('2020-01-01')",
+			"(pgfs_date(features.properties->>?) = ?::date)",
+			[]interface{}{"updated", "2020-01-01"},
+		},
+		{
+			"S_INTERSECTS(geometry, POINT(1 2))",
+			"ST_Intersects(features.geometry, ST_Transform(ST_SetSRID(ST_GeomFromGeoJSON(?), 4326), 4326))",
+			[]interface{}{`{"type":"Point","coordinates":[1,2]}`},
+		},
+		{
+			"S_WITHIN(geometry, BBOX(1, 2, 3, 4))",
+			"ST_Within(features.geometry, ST_Transform(ST_MakeEnvelope(?, ?, ?, ?, 4326), 4326))",
+			[]interface{}{float64(1), float64(2), float64(3), float64(4)},
+		},
+		{
+			"T_AFTER(updated, DATE('2020-01-01'))",
+			"(pgfs_timestamptz(features.properties->>?) > ?::timestamptz)",
+			[]interface{}{"updated", day.AddDate(0, 0, 1).Add(-time.Microsecond)},
+		},
+		{
+			"T_DISJOINT(INTERVAL(start, end), INTERVAL('..', '2020-01-01'))",
+			"NOT (pgfs_timestamptz(features.properties->>?) <= ?::timestamptz AND pgfs_timestamptz(features.properties->>?) >= '-infinity'::timestamptz)",
+			[]interface{}{"start", day.AddDate(0, 0, 1).Add(-time.Microsecond), "end"},
+		},
+	}
+
+	for i, c := range cases {
+		expr, err := Parse(c.input)
+		if !assert.Nil(err, "expected no parse error for case %d", i) {
+			continue
+		}
+		sql, args, err := Where(expr, testOptions).ToSql()
+		assert.Nil(err, "expected no error for case %d", i)
+		assert.Equal(c.sql, sql, "unexpected SQL for case %d", i)
+		assert.Equal(c.args, args, "unexpected args for case %d", i)
+	}
+}
+
+func TestWhereCRS(t *testing.T) {
+	expr, err := Parse("S_INTERSECTS(geometry, BBOX(2, 1, 4, 3))")
+	assert.Nil(t, err)
+
+	options := *testOptions
+	options.CRS = geo.CRS{SRID: 4326, LatLon: true}
+	sql, args, err := Where(expr, &options).ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "ST_Intersects(features.geometry, ST_Transform(ST_MakeEnvelope(?, ?, ?, ?, 4326), 4326))", sql)
+	assert.Equal(t, []interface{}{float64(1), float64(2), float64(3), float64(4)}, args)
+
+	// latitudes are limited to the area of use of the geometry column CRS
+	expr, err = Parse("S_INTERSECTS(geometry, BBOX(-180, -90, 180, 90))")
+	assert.Nil(t, err)
+	options = *testOptions
+	options.SRID = 3857
+	sql, args, err = Where(expr, &options).ToSql()
+	assert.Nil(t, err)
+	assert.Equal(t, "ST_Intersects(features.geometry, ST_Transform(ST_MakeEnvelope(?, ?, ?, ?, 4326), 3857))", sql)
+	assert.Equal(t, []interface{}{float64(-180), float64(-85.06), float64(180), float64(85.06)}, args)
+}
@@ -0,0 +1,643 @@
+package cql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+const dateLayout = "2006-01-02"
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// Parse parses a filter in the CQL2 text encoding
+func Parse(input string) (Expression, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	expr, err := p.booleanExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	if next := p.peek(); next.kind != tokenEOF {
+		return nil, p.unexpected(next)
+	}
+
+	if err := check(expr); err != nil {
+		return nil, err
+	}
+
+	return expr, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) peekAt(offset int) token {
+	if p.pos+offset >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.pos+offset]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) unexpected(t token) error {
+	return fmt.Errorf("unexpected %s at position %d", t, t.pos)
+}
+
+func (p *parser) expect(kind tokenKind, value string) error {
+	t := p.next()
+	if t.kind != kind {
+		return fmt.Errorf("expected '%s' but found %s at position %d", value, t, t.pos)
+	}
+	return nil
+}
+
+func (p *parser) expectKeyword(keyword string) error {
+	t := p.next()
+	if !t.is(keyword) {
+		return fmt.Errorf("expected %s but found %s at position %d", keyword, t, t.pos)
+	}
+	return nil
+}
+
+// booleanExpression = booleanTerm {OR booleanTerm}
+func (p *parser) booleanExpression() (Expression, error) {
+	term, err := p.booleanTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	args := []Expression{term}
+	for p.peek().is("OR") {
+		p.next()
+		term, err := p.booleanTerm()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, term)
+	}
+
+	if len(args) == 1 {
+		return term, nil
+	}
+	return &Or{Args: args}, nil
+}
+
+// booleanTerm = booleanFactor {AND booleanFactor}
+func (p *parser) booleanTerm() (Expression, error) {
+	factor, err := p.booleanFactor()
+	if err != nil {
+		return nil, err
+	}
+
+	args := []Expression{factor}
+	for p.peek().is("AND") {
+		p.next()
+		factor, err := p.booleanFactor()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, factor)
+	}
+
+	if len(args) == 1 {
+		return factor, nil
+	}
+	return &And{Args: args}, nil
+}
+
+// booleanFactor = [NOT] booleanPrimary
+func (p *parser) booleanFactor() (Expression, error) {
+	if p.peek().is("NOT") {
+		p.next()
+		primary, err := p.booleanPrimary()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Arg: primary}, nil
+	}
+	return p.booleanPrimary()
+}
+
+// booleanPrimary = "(" booleanExpression ")" | predicate | TRUE | FALSE
+func (p *parser) booleanPrimary() (Expression, error) {
+	t := p.peek()
+
+	if t.kind == tokenLeftParen {
+		p.next()
+		expr, err := p.booleanExpression()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenRightParen, ")"); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	}
+
+	if t.kind == tokenIdent && p.peekAt(1).kind == tokenLeftParen {
+		name := strings.ToUpper(t.value)
+		if _, ok := spatialOps[name]; ok {
+			p.next()
+			left, right, err := p.arguments(p.spatialOperand)
+			if err != nil {
+				return nil, err
+			}
+			return &Spatial{Op: name, Left: left, Right: right}, nil
+		}
+		if _, ok := temporalOps[name]; ok {
+			p.next()
+			left, right, err := p.arguments(p.temporalOperand)
+			if err != nil {
+				return nil, err
+			}
+			return &Temporal{Op: name, Left: left, Right: right}, nil
+		}
+	}
+
+	if (t.is("TRUE") || t.is("FALSE")) && !p.startsPredicate(p.peekAt(1)) {
+		p.next()
+		return &Boolean{Value: t.is("TRUE")}, nil
+	}
+
+	return p.predicate()
+}
+
+// startsPredicate is true for tokens that may follow the first operand of a predicate
+func (p *parser) startsPredicate(t token) bool {
+	return t.kind == tokenOperator || t.is("LIKE") || t.is("BETWEEN") || t.is("IN") || t.is("IS") || t.is("NOT")
+}
+
+// arguments parses two comma-delimited operands in parentheses
+func (p *parser) arguments(operand func() (Operand, error)) (Operand, Operand, error) {
+	if err := p.expect(tokenLeftParen, "("); err != nil {
+		return nil, nil, err
+	}
+	left, err := operand()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := p.expect(tokenComma, ","); err != nil {
+		return nil, nil, err
+	}
+	right, err := operand()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := p.expect(tokenRightParen, ")"); err != nil {
+		return nil, nil, err
+	}
+	return left, right, nil
+}
+
+// predicate parses comparison, LIKE, BETWEEN, IN, and IS NULL predicates
+func (p *parser) predicate() (Expression, error) {
+	value, err := p.scalar()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.next()
+	if t.kind == tokenOperator {
+		right, err := p.scalar()
+		if err != nil {
+			return nil, err
+		}
+		return &Comparison{Op: t.value, Left: value, Right: right}, nil
+	}
+
+	if t.is("IS") {
+		negate := false
+		if p.peek().is("NOT") {
+			p.next()
+			negate = true
+		}
+		if err := p.expectKeyword("NULL"); err != nil {
+			return nil, err
+		}
+		return maybeNot(&IsNull{Value: value}, negate), nil
+	}
+
+	negate := false
+	if t.is("NOT") {
+		negate = true
+		t = p.next()
+	}
+
+	switch {
+	case t.is("LIKE"):
+		pattern, err := p.scalar()
+		if err != nil {
+			return nil, err
+		}
+		return maybeNot(&Like{Value: value, Pattern: pattern}, negate), nil
+
+	case t.is("BETWEEN"):
+		low, err := p.scalar()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("AND"); err != nil {
+			return nil, err
+		}
+		high, err := p.scalar()
+		if err != nil {
+			return nil, err
+		}
+		return maybeNot(&Between{Value: value, Low: low, High: high}, negate), nil
+
+	case t.is("IN"):
+		if err := p.expect(tokenLeftParen, "("); err != nil {
+			return nil, err
+		}
+		list := []Operand{}
+		for {
+			item, err := p.scalar()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+			if p.peek().kind != tokenComma {
+				break
+			}
+			p.next()
+		}
+		if err := p.expect(tokenRightParen, ")"); err != nil {
+			return nil, err
+		}
+		return maybeNot(&In{Value: value, List: list}, negate), nil
+	}
+
+	return nil, p.unexpected(t)
+}
+
+func maybeNot(expr Expression, negate bool) Expression {
+	if negate {
+		return &Not{Arg: expr}
+	}
+	return expr
+}
+
+// scalar parses a property name, character, numeric, boolean, or instant literal
+func (p *parser) scalar() (Operand, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		return &String{Value: t.value}, nil
+	case tokenNumber:
+		v, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %s at position %d as a number", t, t.pos)
+		}
+		return &Number{Value: v}, nil
+	case tokenQuotedIdent:
+		return &Property{Name: t.value}, nil
+	case tokenIdent:
+		switch {
+		case t.is("TRUE"), t.is("FALSE"):
+			return &Bool{Value: t.is("TRUE")}, nil
+		case (t.is("TIMESTAMP") || t.is("DATE")) && p.peek().kind == tokenLeftParen:
+			return p.instant(t)
+		case isKeyword(t):
+			return nil, p.unexpected(t)
+		}
+		return &Property{Name: t.value}, nil
+	}
+	return nil, p.unexpected(t)
+}
+
+// instant parses the rest of a TIMESTAMP('...') or DATE('...') literal
+func (p *parser) instant(t token) (Operand, error) {
+	if err := p.expect(tokenLeftParen, "("); err != nil {
+		return nil, err
+	}
+	s := p.next()
+	if s.kind != tokenString {
+		return nil, fmt.Errorf("expected a string but found %s at position %d", s, s.pos)
+	}
+	if err := p.expect(tokenRightParen, ")"); err != nil {
+		return nil, err
+	}
+	if t.is("DATE") {
+		return parseDate(s.value)
+	}
+	return parseTimestamp(s.value)
+}
+
+func parseTimestamp(value string) (*Timestamp, error) {
+	v, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse '%s' as a timestamp", value)
+	}
+	return &Timestamp{Value: v}, nil
+}
+
+func parseDate(value string) (*Date, error) {
+	v, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse '%s' as a date", value)
+	}
+	return &Date{Value: v}, nil
+}
+
+// temporalOperand parses a property, instant, or INTERVAL(start, end)
+func (p *parser) temporalOperand() (Operand, error) {
+	t := p.peek()
+	if !t.is("INTERVAL") || p.peekAt(1).kind != tokenLeftParen {
+		return p.scalar()
+	}
+
+	p.next()
+	start, end, err := p.arguments(p.intervalBound)
+	if err != nil {
+		return nil, err
+	}
+	return &Interval{Start: start, End: end}, nil
+}
+
+// intervalBound parses a property, instant, or a string with a date,
+// timestamp, or '..' for an unbounded interval
+func (p *parser) intervalBound() (Operand, error) {
+	if t := p.peek(); t.kind == tokenString {
+		p.next()
+		return parseBound(t.value)
+	}
+	return p.scalar()
+}
+
+func parseBound(value string) (Operand, error) {
+	if value == ".." {
+		return nil, nil
+	}
+	if strings.Contains(value, "T") {
+		return parseTimestamp(value)
+	}
+	return parseDate(value)
+}
+
+// spatialOperand parses a property or a WKT geometry or BBOX literal
+func (p *parser) spatialOperand() (Operand, error) {
+	t := p.peek()
+	if t.kind != tokenIdent {
+		return p.scalar()
+	}
+
+	if t.is("BBOX") && p.peekAt(1).kind == tokenLeftParen {
+		p.next()
+		p.next()
+		values := []float64{}
+		for {
+			v, err := p.number()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+			if p.peek().kind != tokenComma {
+				break
+			}
+			p.next()
+		}
+		if err := p.expect(tokenRightParen, ")"); err != nil {
+			return nil, err
+		}
+		if len(values) != 4 && len(values) != 6 {
+			return nil, fmt.Errorf("BBOX at position %d must have 4 or 6 values", t.pos)
+		}
+		if len(values) == 6 {
+			values = []float64{values[0], values[1], values[3], values[4]}
+		}
+		return &BBox{Values: values}, nil
+	}
+
+	if _, ok := wktTypes[strings.ToUpper(t.value)]; ok {
+		geometry, err := p.geometry()
+		if err != nil {
+			return nil, err
+		}
+		return &Geometry{Value: geometry}, nil
+	}
+
+	return p.scalar()
+}
+
+var wktTypes = map[string]geojson.GeometryType{
+	"POINT":              geojson.GeometryPoint,
+	"LINESTRING":         geojson.GeometryLineString,
+	"POLYGON":            geojson.GeometryPolygon,
+	"MULTIPOINT":         geojson.GeometryMultiPoint,
+	"MULTILINESTRING":    geojson.GeometryMultiLineString,
+	"MULTIPOLYGON":       geojson.GeometryMultiPolygon,
+	"GEOMETRYCOLLECTION": geojson.GeometryCollection,
+}
+
+// geometry parses a WKT geometry
+func (p *parser) geometry() (*geojson.Geometry, error) {
+	t := p.next()
+	geometryType, ok := wktTypes[strings.ToUpper(t.value)]
+	if t.kind != tokenIdent || !ok {
+		return nil, fmt.Errorf("expected a geometry but found %s at position %d", t, t.pos)
+	}
+
+	// optional dimension
+	if next := p.peek(); next.is("Z") || next.is("M") || next.is("ZM") {
+		p.next()
+	}
+
+	if geometryType == geojson.GeometryCollection {
+		if err := p.expect(tokenLeftParen, "("); err != nil {
+			return nil, err
+		}
+		geometries := []*geojson.Geometry{}
+		for {
+			g, err := p.geometry()
+			if err != nil {
+				return nil, err
+			}
+			geometries = append(geometries, g)
+			if p.peek().kind != tokenComma {
+				break
+			}
+			p.next()
+		}
+		if err := p.expect(tokenRightParen, ")"); err != nil {
+			return nil, err
+		}
+		return geojson.NewCollectionGeometry(geometries...), nil
+	}
+
+	switch geometryType {
+	case geojson.GeometryPoint:
+		points, err := p.positions()
+		if err != nil {
+			return nil, err
+		}
+		if len(points) != 1 {
+			return nil, fmt.Errorf("POINT at position %d must have one position", t.pos)
+		}
+		return geojson.NewPointGeometry(points[0]), nil
+
+	case geojson.GeometryLineString:
+		line, err := p.positions()
+		if err != nil {
+			return nil, err
+		}
+		return geojson.NewLineStringGeometry(line), nil
+
+	case geojson.GeometryMultiPoint:
+		points, err := p.multiPoint()
+		if err != nil {
+			return nil, err
+		}
+		return geojson.NewMultiPointGeometry(points...), nil
+
+	case geojson.GeometryPolygon, geojson.GeometryMultiLineString:
+		rings, err := p.list(p.positions)
+		if err != nil {
+			return nil, err
+		}
+		if geometryType == geojson.GeometryPolygon {
+			return geojson.NewPolygonGeometry(rings), nil
+		}
+		return geojson.NewMultiLineStringGeometry(rings...), nil
+	}
+
+	// MULTIPOLYGON
+	polygons := [][][][]float64{}
+	if err := p.expect(tokenLeftParen, "("); err != nil {
+		return nil, err
+	}
+	for {
+		polygon, err := p.list(p.positions)
+		if err != nil {
+			return nil, err
+		}
+		polygons = append(polygons, polygon)
+		if p.peek().kind != tokenComma {
+			break
+		}
+		p.next()
+	}
+	if err := p.expect(tokenRightParen, ")"); err != nil {
+		return nil, err
+	}
+	return geojson.NewMultiPolygonGeometry(polygons...), nil
+}
+
+// list parses a parenthesized, comma-delimited list of position lists
+func (p *parser) list(item func() ([][]float64, error)) ([][][]float64, error) {
+	if err := p.expect(tokenLeftParen, "("); err != nil {
+		return nil, err
+	}
+	items := [][][]float64{}
+	for {
+		positions, err := item()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, positions)
+		if p.peek().kind != tokenComma {
+			break
+		}
+		p.next()
+	}
+	if err := p.expect(tokenRightParen, ")"); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// multiPoint parses points with or without parentheses around each
+func (p *parser) multiPoint() ([][]float64, error) {
+	if p.peekAt(1).kind != tokenLeftParen {
+		return p.positions()
+	}
+	points, err := p.list(p.positions)
+	if err != nil {
+		return nil, err
+	}
+	positions := make([][]float64, len(points))
+	for i, point := range points {
+		if len(point) != 1 {
+			return nil, fmt.Errorf("MULTIPOINT members must have one position")
+		}
+		positions[i] = point[0]
+	}
+	return positions, nil
+}
+
+// positions parses a parenthesized, comma-delimited list of positions
+func (p *parser) positions() ([][]float64, error) {
+	if err := p.expect(tokenLeftParen, "("); err != nil {
+		return nil, err
+	}
+	positions := [][]float64{}
+	for {
+		position := []float64{}
+		for p.peek().kind == tokenNumber {
+			v, err := p.number()
+			if err != nil {
+				return nil, err
+			}
+			position = append(position, v)
+		}
+		if len(position) < 2 || len(position) > 4 {
+			t := p.peek()
+			return nil, fmt.Errorf("expected a position with 2 to 4 coordinates before position %d", t.pos)
+		}
+		positions = append(positions, position)
+		if p.peek().kind != tokenComma {
+			break
+		}
+		p.next()
+	}
+	if err := p.expect(tokenRightParen, ")"); err != nil {
+		return nil, err
+	}
+	return positions, nil
+}
+
+func (p *parser) number() (float64, error) {
+	t := p.next()
+	if t.kind != tokenNumber {
+		return 0, fmt.Errorf("expected a number but found %s at position %d", t, t.pos)
+	}
+	v, err := strconv.ParseFloat(t.value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse %s at position %d as a number", t, t.pos)
+	}
+	return v, nil
+}
+
+var keywords = map[string]bool{
+	"AND":     true,
+	"OR":      true,
+	"NOT":     true,
+	"LIKE":    true,
+	"BETWEEN": true,
+	"IN":      true,
+	"IS":      true,
+	"NULL":    true,
+}
+
+func isKeyword(t token) bool {
+	return t.kind == tokenIdent && keywords[strings.ToUpper(t.value)]
+}
@@ -0,0 +1,146 @@
+package cql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	assert := assert.New(t)
+	cases := []struct {
+		input    string
+		expected Expression
+	}{
+		{
+			"name = 'France'",
+			&Comparison{Op: "=", Left: &Property{Name: "name"}, Right: &String{Value: "France"}},
+		},
+		{
+			`"pop est" >= -1.5e3`,
+			&Comparison{Op: ">=", Left: &Property{Name: "pop est"}, Right: &Number{Value: -1500}},
+		},
+		{
+			"name = 'it''s'",
+			&Comparison{Op: "=", Left: &Property{Name: "name"}, Right: &String{Value: "it's"}},
+		},
+		{
+			"name like 'Fr%' and not (pop < 10 or pop > 20)",
+			&And{Args: []Expression{
+				&Like{Value: &Property{Name: "name"}, Pattern: &String{Value: "Fr%"}},
+				&Not{Arg: &Or{Args: []Expression{
+					&Comparison{Op: "<", Left: &Property{Name: "pop"}, Right: &Number{Value: 10}},
+					&Comparison{Op: ">", Left: &Property{Name: "pop"}, Right: &Number{Value: 20}},
+				}}},
+			}},
+		},
+		{
+			"pop NOT BETWEEN 1 AND 2",
+			&Not{Arg: &Between{Value: &Property{Name: "pop"}, Low: &Number{Value: 1}, High: &Number{Value: 2}}},
+		},
+		{
+			"name IN ('a', 'b')",
+			&In{Value: &Property{Name: "name"}, List: []Operand{&String{Value: "a"}, &String{Value: "b"}}},
+		},
+		{
+			"name IS NOT NULL",
+			&Not{Arg: &IsNull{Value: &Property{Name: "name"}}},
+		},
+		{
+			"TRUE",
+			&Boolean{Value: true},
+		},
+		{
+			"S_INTERSECTS(geometry, POINT(1 2))",
+			&Spatial{Op: "S_INTERSECTS", Left: &Property{Name: "geometry"}, Right: &Geometry{Value: pointGeometry(1, 2)}},
+		},
+		{
+			"s_within(geometry, BBOX(-10, -20, 10, 20))",
+			&Spatial{Op: "S_WITHIN", Left: &Property{Name: "geometry"}, Right: &BBox{Values: []float64{-10, -20, 10, 20}}},
+		},
+		{
+			"T_DURING(updated, INTERVAL('2020-01-01', '..'))",
+			&Temporal{Op: "T_DURING", Left: &Property{Name: "updated"}, Right: &Interval{
+				Start: &Date{Value: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+			}},
+		},
+		{
+			"updated > TIMESTAMP('2020-01-01T12:00:00Z')",
+			&Comparison{Op: ">", Left: &Property{Name: "updated"}, Right: &Timestamp{Value: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)}},
+		},
+	}
+
+	for i, c := range cases {
+		expr, err := Parse(c.input)
+		if assert.Nil(err, "expected no error for case %d", i) {
+			assert.Equal(c.expected, expr, "unexpected expression for case %d", i)
+		}
+	}
+}
+
+func TestParseGeometry(t *testing.T) {
+	assert := assert.New(t)
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"POINT(1 2)", `{"type":"Point","coordinates":[1,2]}`},
+		{"POINT Z (1 2 3)", `{"type":"Point","coordinates":[1,2,3]}`},
+		{"LINESTRING(1 2, 3 4)", `{"type":"LineString","coordinates":[[1,2],[3,4]]}`},
+		{"POLYGON((0 0, 1 0, 1 1, 0 0))", `{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]}`},
+		{"MULTIPOINT((1 2), (3 4))", `{"type":"MultiPoint","coordinates":[[1,2],[3,4]]}`},
+		{"MULTIPOINT(1 2, 3 4)", `{"type":"MultiPoint","coordinates":[[1,2],[3,4]]}`},
+		{"MULTILINESTRING((1 2, 3 4))", `{"type":"MultiLineString","coordinates":[[[1,2],[3,4]]]}`},
+		{"MULTIPOLYGON(((0 0, 1 0, 1 1, 0 0)))", `{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,0]]]]}`},
+		{"GEOMETRYCOLLECTION(POINT(1 2), LINESTRING(1 2, 3 4))", `{"type":"GeometryCollection","geometries":[{"type":"Point","coordinates":[1,2]},{"type":"LineString","coordinates":[[1,2],[3,4]]}]}`},
+	}
+
+	for i, c := range cases {
+		expr, err := Parse("S_INTERSECTS(geometry, " + c.input + ")")
+		if !assert.Nil(err, "expected no error for case %d", i) {
+			continue
+		}
+		data, _ := expr.(*Spatial).Right.(*Geometry).Value.MarshalJSON()
+		assert.Equal(c.expected, string(data), "unexpected geometry for case %d", i)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	assert := assert.New(t)
+	cases := []string{
+		"",
+		"name",
+		"name = ",
+		"name = 'unterminated",
+		"(name = 'a'",
+		"name = 'a' extra",
+		"name ~ 'a'",
+		"name IN ()",
+		"pop BETWEEN 1",
+		"name IS EMPTY",
+		"S_INTERSECTS(name, POINT(1 2))",
+		"S_INTERSECTS(geometry, 'a')",
+		"S_INTERSECTS(geometry, POINT(1))",
+		"geometry = 'a'",
+		"T_AFTER(updated, 10)",
+		"updated > TIMESTAMP('yesterday')",
+		"T_DURING(updated, INTERVAL('2020-01-01'))",
+		"T_AFTER(id, TIMESTAMP('2020-01-01T00:00:00Z'))",
+		"T_DURING(updated, INTERVAL(id, '..'))",
+		"id > DATE('2020-01-01')",
+		"id BETWEEN TIMESTAMP('2020-01-01T00:00:00Z') AND TIMESTAMP('2021-01-01T00:00:00Z')",
+		"id IN (DATE('2020-01-01'))",
+	}
+
+	for _, input := range cases {
+		_, err := Parse(input)
+		assert.NotNil(err, "expected an error for '%s'", input)
+	}
+}
+
+func TestProperties(t *testing.T) {
+	expr, err := Parse("a = 1 AND (b LIKE 'x' OR a > 2) AND T_DURING(c, INTERVAL(d, '..')) AND S_INTERSECTS(geometry, POINT(1 2))")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d", "geometry"}, Properties(expr))
+}
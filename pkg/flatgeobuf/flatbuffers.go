@@ -0,0 +1,223 @@
+package flatgeobuf
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// The FlatGeobuf header and features are FlatBuffers.  This is a minimal
+// builder for the tables used here.  Unlike the reference builder, it writes
+// front to back: each table is preceded by its vtable and followed by the
+// strings, vectors, and tables it refers to, so all offsets point forward.
+// Positions are aligned relative to the start of the size prefixed buffer.
+
+// object is something that can be written to a buffer and referenced by an offset
+type object interface {
+	write(b *builder) int
+}
+
+// builder holds a buffer being written
+type builder struct {
+	buf []byte
+}
+
+// pad adds zeros until the length plus extra is a multiple of align
+func (b *builder) pad(align int, extra int) {
+	for (len(b.buf)+extra)%align != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+func (b *builder) uint16(v uint16) {
+	b.buf = append(b.buf, 0, 0)
+	binary.LittleEndian.PutUint16(b.buf[len(b.buf)-2:], v)
+}
+
+func (b *builder) uint32(v uint32) {
+	b.buf = append(b.buf, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(b.buf[len(b.buf)-4:], v)
+}
+
+// patch sets the uoffset at a position to point to a target
+func (b *builder) patch(position int, target int) {
+	binary.LittleEndian.PutUint32(b.buf[position:], uint32(target-position))
+}
+
+// finish returns a size prefixed buffer with a root table
+func finish(root *table) []byte {
+	b := &builder{buf: make([]byte, 8, 1024)}
+	b.patch(4, root.write(b))
+	binary.LittleEndian.PutUint32(b.buf, uint32(len(b.buf)-4))
+	return b.buf
+}
+
+// field is a scalar value or an offset to an object
+type field struct {
+	scalar []byte
+	child  object
+}
+
+func (f *field) size() int {
+	if f.child != nil {
+		return 4
+	}
+	return len(f.scalar)
+}
+
+// table is a FlatBuffers table with fields indexed by id
+type table struct {
+	fields []*field
+}
+
+func (t *table) set(id int, f *field) {
+	for len(t.fields) <= id {
+		t.fields = append(t.fields, nil)
+	}
+	t.fields[id] = f
+}
+
+func (t *table) setUint8(id int, v uint8) {
+	t.set(id, &field{scalar: []byte{v}})
+}
+
+func (t *table) setBool(id int, v bool) {
+	if v {
+		t.setUint8(id, 1)
+	} else {
+		t.setUint8(id, 0)
+	}
+}
+
+func (t *table) setUint16(id int, v uint16) {
+	scalar := make([]byte, 2)
+	binary.LittleEndian.PutUint16(scalar, v)
+	t.set(id, &field{scalar: scalar})
+}
+
+func (t *table) setInt32(id int, v int32) {
+	scalar := make([]byte, 4)
+	binary.LittleEndian.PutUint32(scalar, uint32(v))
+	t.set(id, &field{scalar: scalar})
+}
+
+func (t *table) setUint64(id int, v uint64) {
+	scalar := make([]byte, 8)
+	binary.LittleEndian.PutUint64(scalar, v)
+	t.set(id, &field{scalar: scalar})
+}
+
+func (t *table) setString(id int, v string) {
+	t.set(id, &field{child: stringObject(v)})
+}
+
+func (t *table) setObject(id int, v object) {
+	t.set(id, &field{child: v})
+}
+
+// write adds the vtable, the table, and then the objects it refers to.
+// Fields are laid out largest first after the vtable offset so that each is
+// aligned to its size.
+func (t *table) write(b *builder) int {
+	b.pad(2, 0)
+	vtablePosition := len(b.buf)
+	b.uint16(uint16(4 + 2*len(t.fields)))
+	b.uint16(0) // table size, set below
+	for range t.fields {
+		b.uint16(0)
+	}
+
+	b.pad(8, 4)
+	tablePosition := len(b.buf)
+	b.uint32(uint32(tablePosition - vtablePosition))
+
+	positions := make([]int, len(t.fields))
+	for _, size := range []int{8, 4, 2, 1} {
+		for id, f := range t.fields {
+			if f == nil || f.size() != size {
+				continue
+			}
+			positions[id] = len(b.buf)
+			binary.LittleEndian.PutUint16(b.buf[vtablePosition+4+2*id:], uint16(len(b.buf)-tablePosition))
+			if f.child != nil {
+				b.uint32(0)
+			} else {
+				b.buf = append(b.buf, f.scalar...)
+			}
+		}
+	}
+	binary.LittleEndian.PutUint16(b.buf[vtablePosition+2:], uint16(len(b.buf)-tablePosition))
+
+	for id, f := range t.fields {
+		if f != nil && f.child != nil {
+			b.patch(positions[id], f.child.write(b))
+		}
+	}
+
+	return tablePosition
+}
+
+// stringObject is a string written with a length prefix and null terminator
+type stringObject string
+
+func (s stringObject) write(b *builder) int {
+	b.pad(4, 0)
+	position := len(b.buf)
+	b.uint32(uint32(len(s)))
+	b.buf = append(b.buf, s...)
+	b.buf = append(b.buf, 0)
+	return position
+}
+
+// bytesObject is a vector of bytes
+type bytesObject []byte
+
+func (v bytesObject) write(b *builder) int {
+	b.pad(4, 0)
+	position := len(b.buf)
+	b.uint32(uint32(len(v)))
+	b.buf = append(b.buf, v...)
+	return position
+}
+
+// uint32sObject is a vector of uint32 values
+type uint32sObject []uint32
+
+func (v uint32sObject) write(b *builder) int {
+	b.pad(4, 0)
+	position := len(b.buf)
+	b.uint32(uint32(len(v)))
+	for _, value := range v {
+		b.uint32(value)
+	}
+	return position
+}
+
+// float64sObject is a vector of float64 values
+type float64sObject []float64
+
+func (v float64sObject) write(b *builder) int {
+	b.pad(8, 4)
+	position := len(b.buf)
+	b.uint32(uint32(len(v)))
+	for _, value := range v {
+		b.buf = append(b.buf, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.LittleEndian.PutUint64(b.buf[len(b.buf)-8:], math.Float64bits(value))
+	}
+	return position
+}
+
+// tablesObject is a vector of tables
+type tablesObject []*table
+
+func (v tablesObject) write(b *builder) int {
+	b.pad(4, 0)
+	position := len(b.buf)
+	b.uint32(uint32(len(v)))
+	for range v {
+		b.uint32(0)
+	}
+	for i, t := range v {
+		b.patch(position+4+4*i, t.write(b))
+	}
+	return position
+}
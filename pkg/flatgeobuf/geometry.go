@@ -0,0 +1,181 @@
+package flatgeobuf
+
+import (
+	"math"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// GeometryType identifies the type of a geometry
+type GeometryType uint8
+
+// Geometry types used in FlatGeobuf
+const (
+	Unknown GeometryType = iota
+	Point
+	LineString
+	Polygon
+	MultiPoint
+	MultiLineString
+	MultiPolygon
+	GeometryCollection
+)
+
+var geometryTypes = map[geojson.GeometryType]GeometryType{
+	geojson.GeometryPoint:           Point,
+	geojson.GeometryLineString:      LineString,
+	geojson.GeometryPolygon:         Polygon,
+	geojson.GeometryMultiPoint:      MultiPoint,
+	geojson.GeometryMultiLineString: MultiLineString,
+	geojson.GeometryMultiPolygon:    MultiPolygon,
+	geojson.GeometryCollection:      GeometryCollection,
+}
+
+// GeometryTypeOf returns the FlatGeobuf type for a GeoJSON geometry type name
+// (or Unknown for an empty name)
+func GeometryTypeOf(name string) GeometryType {
+	return geometryTypes[geojson.GeometryType(name)]
+}
+
+// Geometry table field ids
+const (
+	geometryEnds  = 0
+	geometryXY    = 1
+	geometryZ     = 2
+	geometryType  = 6
+	geometryParts = 7
+)
+
+// coordinates collects the flat coordinates and part ends of a geometry
+type coordinates struct {
+	xy   []float64
+	z    []float64
+	ends []uint32
+	hasZ bool
+}
+
+func (c *coordinates) add(positions ...[]float64) {
+	for _, position := range positions {
+		c.xy = append(c.xy, position[0], position[1])
+		if c.hasZ {
+			z := 0.0
+			if len(position) > 2 {
+				z = position[2]
+			}
+			c.z = append(c.z, z)
+		}
+	}
+}
+
+// end marks the end of a ring or line
+func (c *coordinates) end() {
+	c.ends = append(c.ends, uint32(len(c.xy)/2))
+}
+
+func (c *coordinates) table(geometry GeometryType) *table {
+	t := &table{}
+	// ends are only needed for more than one ring or line
+	if len(c.ends) > 1 {
+		t.setObject(geometryEnds, uint32sObject(c.ends))
+	}
+	if len(c.xy) > 0 {
+		t.setObject(geometryXY, float64sObject(c.xy))
+	}
+	if len(c.z) > 0 {
+		t.setObject(geometryZ, float64sObject(c.z))
+	}
+	t.setUint8(geometryType, uint8(geometry))
+	return t
+}
+
+// geometryTable encodes a GeoJSON geometry.  Multipolygons and collections are
+// encoded as parts.
+func geometryTable(geometry *geojson.Geometry, hasZ bool) *table {
+	c := &coordinates{hasZ: hasZ}
+	switch geometry.Type {
+	case geojson.GeometryPoint:
+		c.add(geometry.Point)
+	case geojson.GeometryMultiPoint:
+		c.add(geometry.MultiPoint...)
+	case geojson.GeometryLineString:
+		c.add(geometry.LineString...)
+	case geojson.GeometryMultiLineString:
+		for _, line := range geometry.MultiLineString {
+			c.add(line...)
+			c.end()
+		}
+	case geojson.GeometryPolygon:
+		for _, ring := range geometry.Polygon {
+			c.add(ring...)
+			c.end()
+		}
+	case geojson.GeometryMultiPolygon:
+		parts := make(tablesObject, len(geometry.MultiPolygon))
+		for i, polygon := range geometry.MultiPolygon {
+			parts[i] = geometryTable(&geojson.Geometry{Type: geojson.GeometryPolygon, Polygon: polygon}, hasZ)
+		}
+		t := c.table(MultiPolygon)
+		t.setObject(geometryParts, parts)
+		return t
+	case geojson.GeometryCollection:
+		parts := make(tablesObject, len(geometry.Geometries))
+		for i, child := range geometry.Geometries {
+			parts[i] = geometryTable(child, hasZ)
+		}
+		t := c.table(GeometryCollection)
+		t.setObject(geometryParts, parts)
+		return t
+	}
+	return c.table(GeometryTypeOf(string(geometry.Type)))
+}
+
+// bounds is a bounding box
+type bounds struct {
+	minX, minY, maxX, maxY float64
+}
+
+func emptyBounds() bounds {
+	return bounds{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+}
+
+func (b *bounds) empty() bool {
+	return b.minX > b.maxX || b.minY > b.maxY
+}
+
+func (b *bounds) expand(other bounds) {
+	b.minX = math.Min(b.minX, other.minX)
+	b.minY = math.Min(b.minY, other.minY)
+	b.maxX = math.Max(b.maxX, other.maxX)
+	b.maxY = math.Max(b.maxY, other.maxY)
+}
+
+func (b *bounds) extend(positions ...[]float64) {
+	for _, position := range positions {
+		b.expand(bounds{position[0], position[1], position[0], position[1]})
+	}
+}
+
+// geometryBounds returns the bounding box of a geometry
+func geometryBounds(geometry *geojson.Geometry) bounds {
+	b := emptyBounds()
+	if geometry.Point != nil {
+		b.extend(geometry.Point)
+	}
+	b.extend(geometry.MultiPoint...)
+	b.extend(geometry.LineString...)
+	for _, line := range geometry.MultiLineString {
+		b.extend(line...)
+	}
+	for _, ring := range geometry.Polygon {
+		b.extend(ring...)
+	}
+	for _, polygon := range geometry.MultiPolygon {
+		for _, ring := range polygon {
+			b.extend(ring...)
+		}
+	}
+	for _, child := range geometry.Geometries {
+		b.expand(geometryBounds(child))
+	}
+	return b
+}
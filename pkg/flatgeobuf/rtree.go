@@ -0,0 +1,166 @@
+package flatgeobuf
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"sort"
+)
+
+// nodeSize is the number of children of each node in the spatial index
+const nodeSize = 16
+
+// nodeItemSize is the encoded size of a node (four float64 bounds and an offset)
+const nodeItemSize = 40
+
+// hilbertMax is the largest coordinate used to compute Hilbert values
+const hilbertMax = (1 << 16) - 1
+
+// node is an entry in the packed R-tree.  For leaves, the offset is the
+// position of a feature in the data section.  For other nodes, it is the
+// index of the first child node.
+type node struct {
+	bounds
+	offset uint64
+	// size is the encoded size of the feature for leaves (it is not written)
+	size uint64
+}
+
+// hilbert returns the position of (x, y) along a Hilbert curve with 16 bit
+// coordinates (see http://threadlocalmutex.com/?p=126)
+func hilbert(x, y uint32) uint32 {
+	a := x ^ y
+	b := 0xFFFF ^ a
+	c := 0xFFFF ^ (x | y)
+	d := x & (y ^ 0xFFFF)
+
+	A := a | (b >> 1)
+	B := (a >> 1) ^ a
+	C := ((c >> 1) ^ (b & (d >> 1))) ^ c
+	D := ((a & (c >> 1)) ^ (d >> 1)) ^ d
+
+	a, b, c, d = A, B, C, D
+	A = (a & (a >> 2)) ^ (b & (b >> 2))
+	B = (a & (b >> 2)) ^ (b & ((a ^ b) >> 2))
+	C ^= (a & (c >> 2)) ^ (b & (d >> 2))
+	D ^= (b & (c >> 2)) ^ ((a ^ b) & (d >> 2))
+
+	a, b, c, d = A, B, C, D
+	A = (a & (a >> 4)) ^ (b & (b >> 4))
+	B = (a & (b >> 4)) ^ (b & ((a ^ b) >> 4))
+	C ^= (a & (c >> 4)) ^ (b & (d >> 4))
+	D ^= (b & (c >> 4)) ^ ((a ^ b) & (d >> 4))
+
+	a, b, c, d = A, B, C, D
+	C ^= (a & (c >> 8)) ^ (b & (d >> 8))
+	D ^= (b & (c >> 8)) ^ ((a ^ b) & (d >> 8))
+
+	a = C ^ (C >> 1)
+	b = D ^ (D >> 1)
+
+	i0 := x ^ y
+	i1 := b | (0xFFFF ^ (i0 | a))
+
+	interleave := func(i uint32) uint32 {
+		i = (i | (i << 8)) & 0x00FF00FF
+		i = (i | (i << 4)) & 0x0F0F0F0F
+		i = (i | (i << 2)) & 0x33333333
+		i = (i | (i << 1)) & 0x55555555
+		return i
+	}
+
+	return (interleave(i1) << 1) | interleave(i0)
+}
+
+// hilbertSort orders nodes by the Hilbert value of their centers within an
+// extent (in descending order, like the reference implementation)
+func hilbertSort(nodes []*node, extent bounds) {
+	width := extent.maxX - extent.minX
+	height := extent.maxY - extent.minY
+	value := func(n *node) uint32 {
+		var x, y uint32
+		if width != 0 {
+			x = uint32(math.Floor(hilbertMax * ((n.minX+n.maxX)/2 - extent.minX) / width))
+		}
+		if height != 0 {
+			y = uint32(math.Floor(hilbertMax * ((n.minY+n.maxY)/2 - extent.minY) / height))
+		}
+		return hilbert(x, y)
+	}
+
+	values := make(map[*node]uint32, len(nodes))
+	for _, n := range nodes {
+		values[n] = value(n)
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return values[nodes[i]] > values[nodes[j]]
+	})
+}
+
+// levelBounds returns the start and end index of the nodes in each level of
+// a tree, from the leaves to the root.  The root is stored first.
+func levelBounds(numItems int) [][2]int {
+	n := numItems
+	numNodes := n
+	levelNumNodes := []int{n}
+	for {
+		n = (n + nodeSize - 1) / nodeSize
+		numNodes += n
+		levelNumNodes = append(levelNumNodes, n)
+		if n == 1 {
+			break
+		}
+	}
+
+	levels := make([][2]int, len(levelNumNodes))
+	n = numNodes
+	for i, size := range levelNumNodes {
+		levels[i] = [2]int{n - size, n}
+		n -= size
+	}
+	return levels
+}
+
+// packedTree builds the nodes of a packed R-tree from sorted leaves
+func packedTree(leaves []*node) []*node {
+	levels := levelBounds(len(leaves))
+	numNodes := levels[0][1]
+	nodes := make([]*node, numNodes)
+	copy(nodes[levels[0][0]:], leaves)
+
+	for i := 0; i < len(levels)-1; i++ {
+		position := levels[i][0]
+		end := levels[i][1]
+		parent := levels[i+1][0]
+		for position < end {
+			n := &node{bounds: emptyBounds(), offset: uint64(position)}
+			for j := 0; j < nodeSize && position < end; j++ {
+				n.expand(nodes[position].bounds)
+				position++
+			}
+			nodes[parent] = n
+			parent++
+		}
+	}
+
+	return nodes
+}
+
+// writeTree encodes the nodes of a tree
+func writeTree(w io.Writer, nodes []*node) (int64, error) {
+	var total int64
+	item := make([]byte, nodeItemSize)
+	for _, n := range nodes {
+		binary.LittleEndian.PutUint64(item[0:], math.Float64bits(n.minX))
+		binary.LittleEndian.PutUint64(item[8:], math.Float64bits(n.minY))
+		binary.LittleEndian.PutUint64(item[16:], math.Float64bits(n.maxX))
+		binary.LittleEndian.PutUint64(item[24:], math.Float64bits(n.maxY))
+		binary.LittleEndian.PutUint64(item[32:], n.offset)
+		written, err := w.Write(item)
+		total += int64(written)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
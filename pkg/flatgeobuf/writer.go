@@ -0,0 +1,317 @@
+// Package flatgeobuf writes features in the FlatGeobuf format
+// (https://flatgeobuf.org).
+package flatgeobuf
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"os"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// MediaType is the media type for FlatGeobuf
+const MediaType = "application/flatgeobuf"
+
+// magic identifies a FlatGeobuf file (version 3)
+var magic = []byte{0x66, 0x67, 0x62, 0x03, 0x66, 0x67, 0x62, 0x00}
+
+// ColumnType is the type of a property
+type ColumnType uint8
+
+// Column types used for properties
+const (
+	Bool   ColumnType = 2
+	Double ColumnType = 10
+	String ColumnType = 11
+	JSON   ColumnType = 12
+)
+
+// Column describes a feature property
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// Header describes the features in a file
+type Header struct {
+	Name         string
+	Title        string
+	Description  string
+	GeometryType GeometryType
+	HasZ         bool
+	Columns      []*Column
+	// SRID is the EPSG code of the coordinate reference system (0 if unknown)
+	SRID int
+}
+
+// Header, column, and CRS table field ids
+const (
+	headerName           = 0
+	headerEnvelope       = 1
+	headerGeometryType   = 2
+	headerHasZ           = 3
+	headerColumns        = 7
+	headerFeaturesCount  = 8
+	headerIndexNodeSize  = 9
+	headerCRS            = 10
+	headerTitle          = 11
+	headerDescription    = 12
+	columnName           = 0
+	columnType           = 1
+	crsOrg               = 0
+	crsCode              = 1
+	featureGeometry      = 0
+	featureProperties    = 1
+	sizePrefixLength     = 4
+	defaultIndexNodeSize = nodeSize
+)
+
+// Writer writes features to a FlatGeobuf file.  Because the header and the
+// spatial index come before the features, features are encoded to a
+// temporary file as they are added and copied to the output (in the order of
+// the index) when all have been added.
+type Writer struct {
+	header  *Header
+	columns map[string]int
+	temp    *os.File
+	buffer  *bufio.Writer
+	offset  uint64
+	items   []*node
+	extent  bounds
+	// indexed is false if any feature has no geometry
+	indexed bool
+}
+
+// NewWriter creates a writer for features described by a header.  The writer
+// must be closed to remove its temporary file.
+func NewWriter(header *Header) (*Writer, error) {
+	temp, err := os.CreateTemp("", "pgfs-*.fgb")
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{}
+	for i, column := range header.Columns {
+		columns[column.Name] = i
+	}
+
+	return &Writer{
+		header:  header,
+		columns: columns,
+		temp:    temp,
+		buffer:  bufio.NewWriter(temp),
+		extent:  emptyBounds(),
+		indexed: true,
+	}, nil
+}
+
+// Add encodes a feature.  Properties without a column and null values are
+// not written.
+func (w *Writer) Add(geometry *geojson.Geometry, properties map[string]interface{}) error {
+	feature := &table{}
+	item := &node{bounds: emptyBounds(), offset: w.offset}
+	if geometry != nil && geometry.Type != "" {
+		feature.setObject(featureGeometry, geometryTable(geometry, w.header.HasZ))
+		item.bounds = geometryBounds(geometry)
+	}
+	if item.empty() {
+		w.indexed = false
+	} else {
+		w.extent.expand(item.bounds)
+	}
+
+	encoded, err := w.properties(properties)
+	if err != nil {
+		return err
+	}
+	if len(encoded) > 0 {
+		feature.setObject(featureProperties, bytesObject(encoded))
+	}
+
+	data := finish(feature)
+	if _, err := w.buffer.Write(data); err != nil {
+		return err
+	}
+
+	item.size = uint64(len(data))
+	w.items = append(w.items, item)
+	w.offset += uint64(len(data))
+	return nil
+}
+
+// properties encodes property values as column indexes followed by values
+func (w *Writer) properties(properties map[string]interface{}) ([]byte, error) {
+	data := []byte{}
+	for i, column := range w.header.Columns {
+		value, ok := properties[column.Name]
+		if !ok || value == nil {
+			continue
+		}
+
+		var encoded []byte
+		switch column.Type {
+		case Bool:
+			if v, ok := value.(bool); ok {
+				encoded = []byte{0}
+				if v {
+					encoded[0] = 1
+				}
+			}
+		case Double:
+			if v, ok := value.(float64); ok {
+				encoded = make([]byte, 8)
+				binary.LittleEndian.PutUint64(encoded, math.Float64bits(v))
+			}
+		case String:
+			if v, ok := value.(string); ok {
+				encoded = lengthPrefixed([]byte(v))
+			}
+		default:
+			v, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			encoded = lengthPrefixed(v)
+		}
+		if encoded == nil {
+			// values that do not match the column type are skipped
+			continue
+		}
+
+		index := make([]byte, 2)
+		binary.LittleEndian.PutUint16(index, uint16(i))
+		data = append(data, index...)
+		data = append(data, encoded...)
+	}
+	return data, nil
+}
+
+func lengthPrefixed(value []byte) []byte {
+	data := make([]byte, 4, 4+len(value))
+	binary.LittleEndian.PutUint32(data, uint32(len(value)))
+	return append(data, value...)
+}
+
+// headerTable encodes the header
+func (w *Writer) headerTable() *table {
+	header := &table{}
+	header.setString(headerName, w.header.Name)
+	if !w.extent.empty() {
+		header.setObject(headerEnvelope, float64sObject{w.extent.minX, w.extent.minY, w.extent.maxX, w.extent.maxY})
+	}
+	header.setUint8(headerGeometryType, uint8(w.header.GeometryType))
+	header.setBool(headerHasZ, w.header.HasZ)
+
+	if len(w.header.Columns) > 0 {
+		columns := make(tablesObject, len(w.header.Columns))
+		for i, column := range w.header.Columns {
+			columns[i] = &table{}
+			columns[i].setString(columnName, column.Name)
+			columns[i].setUint8(columnType, uint8(column.Type))
+		}
+		header.setObject(headerColumns, columns)
+	}
+
+	header.setUint64(headerFeaturesCount, uint64(len(w.items)))
+	if w.index() {
+		header.setUint16(headerIndexNodeSize, defaultIndexNodeSize)
+	} else {
+		header.setUint16(headerIndexNodeSize, 0)
+	}
+
+	if w.header.SRID != 0 {
+		crs := &table{}
+		crs.setString(crsOrg, "EPSG")
+		crs.setInt32(crsCode, int32(w.header.SRID))
+		header.setObject(headerCRS, crs)
+	}
+	if w.header.Title != "" {
+		header.setString(headerTitle, w.header.Title)
+	}
+	if w.header.Description != "" {
+		header.setString(headerDescription, w.header.Description)
+	}
+	return header
+}
+
+// index is true if a spatial index will be written
+func (w *Writer) index() bool {
+	return w.indexed && len(w.items) > 0
+}
+
+// WriteTo writes the header, the spatial index, and the features
+func (w *Writer) WriteTo(out io.Writer) (int64, error) {
+	if err := w.buffer.Flush(); err != nil {
+		return 0, err
+	}
+
+	output := bufio.NewWriter(out)
+	var total int64
+	write := func(data []byte) error {
+		written, err := output.Write(data)
+		total += int64(written)
+		return err
+	}
+
+	if err := write(magic); err != nil {
+		return total, err
+	}
+	if err := write(finish(w.headerTable())); err != nil {
+		return total, err
+	}
+
+	if !w.index() {
+		if _, err := w.temp.Seek(0, io.SeekStart); err != nil {
+			return total, err
+		}
+		copied, err := io.Copy(output, w.temp)
+		total += copied
+		if err != nil {
+			return total, err
+		}
+		return total, output.Flush()
+	}
+
+	// features are written in the order of the index, so offsets change
+	leaves := make([]*node, len(w.items))
+	copy(leaves, w.items)
+	hilbertSort(leaves, w.extent)
+
+	original := make([]uint64, len(leaves))
+	var offset uint64
+	for i, leaf := range leaves {
+		original[i] = leaf.offset
+		leaf.offset = offset
+		offset += leaf.size
+	}
+
+	written, treeErr := writeTree(output, packedTree(leaves))
+	total += written
+	if treeErr != nil {
+		return total, treeErr
+	}
+
+	for i, leaf := range leaves {
+		copied, err := io.Copy(output, io.NewSectionReader(w.temp, int64(original[i]), int64(leaf.size)))
+		total += copied
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, output.Flush()
+}
+
+// Close removes the temporary file used by the writer
+func (w *Writer) Close() error {
+	closeErr := w.temp.Close()
+	removeErr := os.Remove(w.temp.Name())
+	if closeErr != nil {
+		return closeErr
+	}
+	return removeErr
+}
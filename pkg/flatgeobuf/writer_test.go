@@ -0,0 +1,308 @@
+package flatgeobuf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"testing"
+
+	geojson "github.com/paulmach/go.geojson"
+	"github.com/stretchr/testify/assert"
+)
+
+// reader decodes tables from a size prefixed FlatBuffer
+type reader struct {
+	t   *testing.T
+	buf []byte
+}
+
+func (r *reader) u16(p int) int { return int(binary.LittleEndian.Uint16(r.buf[p:])) }
+func (r *reader) u32(p int) int { return int(binary.LittleEndian.Uint32(r.buf[p:])) }
+
+func (r *reader) root() int {
+	return 4 + r.u32(4)
+}
+
+// field returns the position of a field in a table (or 0 if it is not set)
+func (r *reader) field(table int, id int) int {
+	vtable := table - int(int32(binary.LittleEndian.Uint32(r.buf[table:])))
+	if vtable%2 != 0 || table%4 != 0 {
+		r.t.Errorf("misaligned table at %d", table)
+	}
+	if 4+2*id >= r.u16(vtable) {
+		return 0
+	}
+	offset := r.u16(vtable + 4 + 2*id)
+	if offset == 0 {
+		return 0
+	}
+	if offset >= r.u16(vtable+2) {
+		r.t.Errorf("field %d outside of table", id)
+	}
+	return table + offset
+}
+
+func (r *reader) uint8(table int, id int) int {
+	if p := r.field(table, id); p != 0 {
+		return int(r.buf[p])
+	}
+	return 0
+}
+
+func (r *reader) uint64(table int, id int) uint64 {
+	if p := r.field(table, id); p != 0 {
+		if p%8 != 0 {
+			r.t.Errorf("misaligned uint64 at %d", p)
+		}
+		return binary.LittleEndian.Uint64(r.buf[p:])
+	}
+	return 0
+}
+
+// offset follows a uoffset field
+func (r *reader) offset(table int, id int) int {
+	p := r.field(table, id)
+	if p == 0 {
+		return 0
+	}
+	return p + r.u32(p)
+}
+
+func (r *reader) string(table int, id int) string {
+	p := r.offset(table, id)
+	if p == 0 {
+		return ""
+	}
+	return string(r.buf[p+4 : p+4+r.u32(p)])
+}
+
+func (r *reader) float64s(table int, id int) []float64 {
+	p := r.offset(table, id)
+	if p == 0 {
+		return nil
+	}
+	if (p+4)%8 != 0 {
+		r.t.Errorf("misaligned float64 vector at %d", p)
+	}
+	values := make([]float64, r.u32(p))
+	for i := range values {
+		values[i] = math.Float64frombits(binary.LittleEndian.Uint64(r.buf[p+4+8*i:]))
+	}
+	return values
+}
+
+func (r *reader) tables(table int, id int) []int {
+	p := r.offset(table, id)
+	if p == 0 {
+		return nil
+	}
+	tables := make([]int, r.u32(p))
+	for i := range tables {
+		element := p + 4 + 4*i
+		tables[i] = element + r.u32(element)
+	}
+	return tables
+}
+
+func (r *reader) bytes(table int, id int) []byte {
+	p := r.offset(table, id)
+	if p == 0 {
+		return nil
+	}
+	return r.buf[p+4 : p+4+r.u32(p)]
+}
+
+// readBuffer returns the size prefixed buffer at the start of data
+func readBuffer(t *testing.T, data []byte) *reader {
+	size := int(binary.LittleEndian.Uint32(data))
+	return &reader{t: t, buf: data[:4+size]}
+}
+
+func mustGeometry(t *testing.T, data string) *geojson.Geometry {
+	geometry := &geojson.Geometry{}
+	if err := json.Unmarshal([]byte(data), geometry); err != nil {
+		t.Fatal(err)
+	}
+	return geometry
+}
+
+func TestHilbert(t *testing.T) {
+	assert := assert.New(t)
+
+	// the first 16 positions along the curve fill the 4 by 4 corner
+	positions := map[uint32][2]int{}
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 4; y++ {
+			positions[hilbert(uint32(x), uint32(y))] = [2]int{x, y}
+		}
+	}
+	for h := uint32(0); h < 16; h++ {
+		position, ok := positions[h]
+		if !assert.True(ok, "missing %d", h) {
+			continue
+		}
+		if h > 0 {
+			previous := positions[h-1]
+			distance := math.Abs(float64(position[0]-previous[0])) + math.Abs(float64(position[1]-previous[1]))
+			assert.Equal(1.0, distance, "step to %d", h)
+		}
+	}
+}
+
+func TestLevelBounds(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([][2]int{{1, 2}, {0, 1}}, levelBounds(1))
+	assert.Equal([][2]int{{1, 17}, {0, 1}}, levelBounds(16))
+	assert.Equal([][2]int{{3, 23}, {1, 3}, {0, 1}}, levelBounds(20))
+}
+
+func TestPackedTree(t *testing.T) {
+	assert := assert.New(t)
+
+	leaves := []*node{}
+	for i := 0; i < 20; i++ {
+		leaves = append(leaves, &node{bounds: bounds{float64(i), 0, float64(i) + 1, 1}, offset: uint64(i * 100)})
+	}
+
+	nodes := packedTree(leaves)
+	if !assert.Len(nodes, 23) {
+		return
+	}
+	assert.Equal(bounds{0, 0, 20, 1}, nodes[0].bounds)
+	assert.Equal(uint64(1), nodes[0].offset)
+	assert.Equal(bounds{0, 0, 16, 1}, nodes[1].bounds)
+	assert.Equal(uint64(3), nodes[1].offset)
+	assert.Equal(bounds{16, 0, 20, 1}, nodes[2].bounds)
+	assert.Equal(uint64(19), nodes[2].offset)
+	assert.Equal(uint64(0), nodes[3].offset)
+}
+
+func TestWriter(t *testing.T) {
+	assert := assert.New(t)
+
+	writer, err := NewWriter(&Header{
+		Name:         "places",
+		GeometryType: Unknown,
+		Columns:      []*Column{{Name: "name", Type: String}, {Name: "rank", Type: Double}, {Name: "tags", Type: JSON}},
+		SRID:         4326,
+	})
+	if !assert.Nil(err) {
+		return
+	}
+	defer writer.Close()
+
+	assert.Nil(writer.Add(mustGeometry(t, `{"type":"Point","coordinates":[10,20]}`), map[string]interface{}{"name": "a", "rank": 1.0}))
+	assert.Nil(writer.Add(mustGeometry(t, `{"type":"Polygon","coordinates":[[[0,0],[4,0],[4,4],[0,0]],[[1,1],[2,1],[2,2],[1,1]]]}`), map[string]interface{}{"name": "b", "tags": []interface{}{"x"}}))
+	assert.Nil(writer.Add(mustGeometry(t, `{"type":"MultiPolygon","coordinates":[[[[30,30],[31,30],[31,31],[30,30]]]]}`), map[string]interface{}{"rank": nil}))
+
+	output := &bytes.Buffer{}
+	written, writeErr := writer.WriteTo(output)
+	if !assert.Nil(writeErr) {
+		return
+	}
+	data := output.Bytes()
+	assert.Equal(int64(len(data)), written)
+	assert.Equal(magic, data[:8])
+
+	header := readBuffer(t, data[8:])
+	root := header.root()
+	assert.Equal("places", header.string(root, headerName))
+	assert.Equal([]float64{0, 0, 31, 31}, header.float64s(root, headerEnvelope))
+	assert.Equal(uint64(3), header.uint64(root, headerFeaturesCount))
+	if p := header.field(root, headerIndexNodeSize); assert.NotZero(p) {
+		assert.Equal(nodeSize, header.u16(p))
+	}
+	columns := header.tables(root, headerColumns)
+	if assert.Len(columns, 3) {
+		assert.Equal("rank", header.string(columns[1], columnName))
+		assert.Equal(int(Double), header.uint8(columns[1], columnType))
+	}
+	crs := header.offset(root, headerCRS)
+	assert.Equal("EPSG", header.string(crs, crsOrg))
+
+	// the index has 4 nodes (3 leaves and the root)
+	index := data[8+len(header.buf):]
+	nodes := levelBounds(3)[0][1]
+	assert.Equal(4, nodes)
+	features := index[nodes*nodeItemSize:]
+	assert.Equal([]float64{0, 0, 31, 31}, []float64{
+		math.Float64frombits(binary.LittleEndian.Uint64(index[0:])),
+		math.Float64frombits(binary.LittleEndian.Uint64(index[8:])),
+		math.Float64frombits(binary.LittleEndian.Uint64(index[16:])),
+		math.Float64frombits(binary.LittleEndian.Uint64(index[24:])),
+	})
+
+	// each leaf points to a feature with a geometry in the leaf bounds
+	found := map[int]bool{}
+	for i := 1; i < nodes; i++ {
+		leaf := index[i*nodeItemSize:]
+		minX := math.Float64frombits(binary.LittleEndian.Uint64(leaf[0:]))
+		offset := binary.LittleEndian.Uint64(leaf[32:])
+
+		feature := readBuffer(t, features[offset:])
+		geometry := feature.offset(feature.root(), featureGeometry)
+		kind := GeometryType(feature.uint8(geometry, geometryType))
+		found[int(kind)] = true
+
+		switch kind {
+		case Point:
+			assert.Equal([]float64{10, 20}, feature.float64s(geometry, geometryXY))
+			assert.Equal(10.0, minX)
+			properties := feature.bytes(feature.root(), featureProperties)
+			// name (column 0) is a length prefixed string and rank (column 1) is a double
+			assert.Equal([]byte{0, 0, 1, 0, 0, 0, 'a', 1, 0}, properties[:9])
+			assert.Equal(1.0, math.Float64frombits(binary.LittleEndian.Uint64(properties[9:])))
+		case Polygon:
+			assert.Equal([]float64{0, 0, 4, 0, 4, 4, 0, 0, 1, 1, 2, 1, 2, 2, 1, 1}, feature.float64s(geometry, geometryXY))
+			ends := feature.offset(geometry, geometryEnds)
+			assert.Equal(2, feature.u32(ends))
+			assert.Equal(4, feature.u32(ends+4))
+			assert.Equal(8, feature.u32(ends+8))
+			properties := feature.bytes(feature.root(), featureProperties)
+			assert.Equal(`["x"]`, string(properties[2+4+1+2+4:]))
+		case MultiPolygon:
+			parts := feature.tables(geometry, geometryParts)
+			if assert.Len(parts, 1) {
+				assert.Equal(int(Polygon), feature.uint8(parts[0], geometryType))
+				assert.Equal([]float64{30, 30, 31, 30, 31, 31, 30, 30}, feature.float64s(parts[0], geometryXY))
+			}
+			assert.Nil(feature.bytes(feature.root(), featureProperties))
+		}
+	}
+	assert.Equal(map[int]bool{int(Point): true, int(Polygon): true, int(MultiPolygon): true}, found)
+}
+
+func TestWriterWithoutGeometry(t *testing.T) {
+	assert := assert.New(t)
+
+	writer, err := NewWriter(&Header{Name: "places"})
+	if !assert.Nil(err) {
+		return
+	}
+	defer writer.Close()
+
+	assert.Nil(writer.Add(mustGeometry(t, `{"type":"Point","coordinates":[10,20]}`), nil))
+	assert.Nil(writer.Add(nil, nil))
+
+	output := &bytes.Buffer{}
+	_, writeErr := writer.WriteTo(output)
+	if !assert.Nil(writeErr) {
+		return
+	}
+
+	header := readBuffer(t, output.Bytes()[8:])
+	root := header.root()
+	if p := header.field(root, headerIndexNodeSize); assert.NotZero(p) {
+		assert.Equal(0, header.u16(p))
+	}
+
+	// features follow the header in the order they were added
+	features := output.Bytes()[8+len(header.buf):]
+	first := readBuffer(t, features)
+	assert.NotZero(first.offset(first.root(), featureGeometry))
+	second := readBuffer(t, features[len(first.buf):])
+	assert.Zero(second.offset(second.root(), featureGeometry))
+	assert.Equal(len(features), len(first.buf)+len(second.buf))
+}
@@ -0,0 +1,106 @@
+package geo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// BBox is a two-dimensional bounding box
+type BBox struct {
+	MinX float64
+	MinY float64
+	MaxX float64
+	MaxY float64
+	CRS  CRS
+}
+
+// ParseBBox parses a comma-delimited list of four (or six) numbers.  For a
+// geographic CRS, a box with MinX greater than MaxX crosses the antimeridian.
+func ParseBBox(value string, crs CRS) (*BBox, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 && len(parts) != 6 {
+		return nil, errors.New("bbox must have 4 or 6 comma-separated numbers")
+	}
+
+	values := make([]float64, len(parts))
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("cannot parse '%s' in bbox as a number", part)
+		}
+		values[i] = v
+	}
+
+	// ignore the vertical extent
+	if len(values) == 6 {
+		values = []float64{values[0], values[1], values[3], values[4]}
+	}
+
+	if crs.LatLon {
+		values = []float64{values[1], values[0], values[3], values[2]}
+	}
+
+	bbox := &BBox{
+		MinX: values[0],
+		MinY: values[1],
+		MaxX: values[2],
+		MaxY: values[3],
+		CRS:  crs,
+	}
+
+	if bbox.MinY > bbox.MaxY {
+		return nil, errors.New("bbox minimum y must not be greater than maximum y")
+	}
+
+	if crs.Geographic() {
+		if bbox.MinY < -90 || bbox.MaxY > 90 {
+			return nil, errors.New("bbox latitude must be between -90 and 90")
+		}
+		if bbox.MinX < -180 || bbox.MinX > 180 || bbox.MaxX < -180 || bbox.MaxX > 180 {
+			return nil, errors.New("bbox longitude must be between -180 and 180")
+		}
+	} else if bbox.MinX > bbox.MaxX {
+		return nil, errors.New("bbox minimum x must not be greater than maximum x")
+	}
+
+	return bbox, nil
+}
+
+// CrossesAntimeridian is true for a geographic box that wraps past 180°
+func (bbox *BBox) CrossesAntimeridian() bool {
+	return bbox.CRS.Geographic() && bbox.MinX > bbox.MaxX
+}
+
+// Split returns a box on either side of the antimeridian for a box that
+// crosses it, or the box itself otherwise
+func (bbox *BBox) Split() []*BBox {
+	if !bbox.CrossesAntimeridian() {
+		return []*BBox{bbox}
+	}
+
+	return []*BBox{
+		{MinX: bbox.MinX, MinY: bbox.MinY, MaxX: 180, MaxY: bbox.MaxY, CRS: bbox.CRS},
+		{MinX: -180, MinY: bbox.MinY, MaxX: bbox.MaxX, MaxY: bbox.MaxY, CRS: bbox.CRS},
+	}
+}
+
+// Clamp returns a geographic box with latitudes limited to the area of use
+// of another CRS (see MaxLatitude) so that it can be transformed to that CRS.
+// Other boxes are returned as is.
+func (bbox *BBox) Clamp(crs CRS) *BBox {
+	if !bbox.CRS.Geographic() {
+		return bbox
+	}
+
+	limit := crs.MaxLatitude()
+	return &BBox{
+		MinX: bbox.MinX,
+		MinY: math.Max(math.Min(bbox.MinY, limit), -limit),
+		MaxX: bbox.MaxX,
+		MaxY: math.Max(math.Min(bbox.MaxY, limit), -limit),
+		CRS:  bbox.CRS,
+	}
+}
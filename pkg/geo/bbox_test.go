@@ -0,0 +1,81 @@
+package geo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBBox(t *testing.T) {
+	assert := assert.New(t)
+	cases := []struct {
+		value    string
+		crs      CRS
+		expected *BBox
+	}{
+		{"-10,-20,10,20", CRS84, &BBox{MinX: -10, MinY: -20, MaxX: 10, MaxY: 20, CRS: CRS84}},
+		{" -10, -20 , 10,20 ", CRS84, &BBox{MinX: -10, MinY: -20, MaxX: 10, MaxY: 20, CRS: CRS84}},
+		{"-10,-20,0,10,20,100", CRS84, &BBox{MinX: -10, MinY: -20, MaxX: 10, MaxY: 20, CRS: CRS84}},
+		{"170,-20,-170,20", CRS84, &BBox{MinX: 170, MinY: -20, MaxX: -170, MaxY: 20, CRS: CRS84}},
+		{"-20,-10,20,10", CRS{SRID: 4326, LatLon: true}, &BBox{MinX: -10, MinY: -20, MaxX: 10, MaxY: 20, CRS: CRS{SRID: 4326, LatLon: true}}},
+		{"-1e6,-2e6,1e6,2e6", CRS{SRID: 3857}, &BBox{MinX: -1e6, MinY: -2e6, MaxX: 1e6, MaxY: 2e6, CRS: CRS{SRID: 3857}}},
+	}
+
+	for i, c := range cases {
+		bbox, err := ParseBBox(c.value, c.crs)
+		assert.Nil(err, "expected no error for case %d", i)
+		assert.Equal(c.expected, bbox, "unexpected bbox for case %d", i)
+	}
+}
+
+func TestParseBBoxInvalid(t *testing.T) {
+	assert := assert.New(t)
+	cases := []struct {
+		value string
+		crs   CRS
+	}{
+		{"", CRS84},
+		{"1,2,3", CRS84},
+		{"1,2,3,4,5", CRS84},
+		{"a,2,3,4", CRS84},
+		{"1,2,3,NaN", CRS84},
+		{"0,20,10,10", CRS84},
+		{"0,-100,10,10", CRS84},
+		{"-190,0,10,10", CRS84},
+		{"10,0,0,10", CRS{SRID: 3857}},
+	}
+
+	for i, c := range cases {
+		_, err := ParseBBox(c.value, c.crs)
+		assert.NotNil(err, "expected an error for case %d", i)
+	}
+}
+
+func TestBBoxSplit(t *testing.T) {
+	assert := assert.New(t)
+
+	bbox := &BBox{MinX: -10, MinY: -20, MaxX: 10, MaxY: 20, CRS: CRS84}
+	assert.False(bbox.CrossesAntimeridian())
+	assert.Equal([]*BBox{bbox}, bbox.Split())
+
+	crossing := &BBox{MinX: 170, MinY: -20, MaxX: -170, MaxY: 20, CRS: CRS84}
+	assert.True(crossing.CrossesAntimeridian())
+	assert.Equal([]*BBox{
+		{MinX: 170, MinY: -20, MaxX: 180, MaxY: 20, CRS: CRS84},
+		{MinX: -180, MinY: -20, MaxX: -170, MaxY: 20, CRS: CRS84},
+	}, crossing.Split())
+}
+
+func TestBBoxClamp(t *testing.T) {
+	assert := assert.New(t)
+
+	world := &BBox{MinX: -180, MinY: -90, MaxX: 180, MaxY: 90, CRS: CRS84}
+	assert.Equal(world, world.Clamp(CRS84))
+	assert.Equal(&BBox{MinX: -180, MinY: -85.06, MaxX: 180, MaxY: 85.06, CRS: CRS84}, world.Clamp(CRS{SRID: 3857}))
+
+	north := &BBox{MinX: 0, MinY: 86, MaxX: 10, MaxY: 90, CRS: CRS84}
+	assert.Equal(&BBox{MinX: 0, MinY: 85.06, MaxX: 10, MaxY: 85.06, CRS: CRS84}, north.Clamp(CRS{SRID: 3857}))
+
+	projected := &BBox{MinX: -1e7, MinY: -1e8, MaxX: 1e7, MaxY: 1e8, CRS: CRS{SRID: 3857}}
+	assert.Equal(projected, projected.Clamp(CRS84))
+}
@@ -0,0 +1,74 @@
+package geo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CRS84URI identifies WGS 84 with longitude, latitude axis order
+const CRS84URI = "http://www.opengis.net/def/crs/OGC/1.3/CRS84"
+
+const epsgPrefix = "http://www.opengis.net/def/crs/EPSG/0/"
+
+// CRS is a coordinate reference system
+type CRS struct {
+	// SRID is the spatial reference identifier used in the database
+	SRID int
+	// LatLon is true when coordinates are given in latitude, longitude order
+	LatLon bool
+}
+
+// CRS84 is the default coordinate reference system
+var CRS84 = CRS{SRID: 4326}
+
+// ParseCRS parses a CRS URI (or an EPSG:<code> shorthand)
+func ParseCRS(value string) (CRS, error) {
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	if value == CRS84URI {
+		return CRS84, nil
+	}
+
+	var code string
+	switch {
+	case strings.HasPrefix(value, epsgPrefix):
+		code = strings.TrimPrefix(value, epsgPrefix)
+	case strings.HasPrefix(strings.ToUpper(value), "EPSG:"):
+		code = value[len("EPSG:"):]
+	default:
+		return CRS{}, fmt.Errorf("unsupported CRS '%s'", value)
+	}
+
+	srid, err := strconv.Atoi(code)
+	if err != nil || srid <= 0 {
+		return CRS{}, fmt.Errorf("invalid EPSG code in CRS '%s'", value)
+	}
+
+	return CRS{SRID: srid, LatLon: srid == 4326}, nil
+}
+
+// URI returns the identifier for the CRS
+func (crs CRS) URI() string {
+	if crs == CRS84 {
+		return CRS84URI
+	}
+	return fmt.Sprintf("%s%d", epsgPrefix, crs.SRID)
+}
+
+// Geographic is true for coordinate reference systems known to use degrees
+func (crs CRS) Geographic() bool {
+	return crs.SRID == 4326
+}
+
+// webMercatorMaxLatitude is the latitude where Web Mercator (EPSG:3857) is
+// cut off to make the world square
+const webMercatorMaxLatitude = 85.06
+
+// MaxLatitude is the largest latitude (north or south) in the area of use of
+// the CRS.  Geographic coordinates beyond it cannot be transformed to the CRS.
+func (crs CRS) MaxLatitude() float64 {
+	if crs.SRID == 3857 {
+		return webMercatorMaxLatitude
+	}
+	return 90
+}
@@ -0,0 +1,38 @@
+package geo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCRS(t *testing.T) {
+	assert := assert.New(t)
+	cases := []struct {
+		value    string
+		expected CRS
+	}{
+		{CRS84URI, CRS84},
+		{"[http://www.opengis.net/def/crs/OGC/1.3/CRS84]", CRS84},
+		{"http://www.opengis.net/def/crs/EPSG/0/4326", CRS{SRID: 4326, LatLon: true}},
+		{"http://www.opengis.net/def/crs/EPSG/0/3857", CRS{SRID: 3857}},
+		{"EPSG:27700", CRS{SRID: 27700}},
+	}
+
+	for i, c := range cases {
+		crs, err := ParseCRS(c.value)
+		assert.Nil(err, "expected no error for case %d", i)
+		assert.Equal(c.expected, crs, "unexpected CRS for case %d", i)
+	}
+
+	for _, uri := range []string{CRS84URI, "http://www.opengis.net/def/crs/EPSG/0/4326", "http://www.opengis.net/def/crs/EPSG/0/3857"} {
+		crs, err := ParseCRS(uri)
+		assert.Nil(err, "expected no error for '%s'", uri)
+		assert.Equal(uri, crs.URI(), "expected round trip for '%s'", uri)
+	}
+
+	for _, value := range []string{"", "foo", "EPSG:abc", "http://www.opengis.net/def/crs/EPSG/0/-1"} {
+		_, err := ParseCRS(value)
+		assert.NotNil(err, "expected an error for '%s'", value)
+	}
+}
@@ -3,6 +3,8 @@ package geo
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"errors"
+	"fmt"
 
 	geojson "github.com/paulmach/go.geojson"
 )
@@ -30,13 +32,26 @@ func (g *Geometry) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-// MarshalJSON encodes the geometry into GeoJSON
+// MarshalJSON encodes the geometry into GeoJSON.  An empty geometry is
+// encoded as null.
 func (g *Geometry) MarshalJSON() ([]byte, error) {
+	if g.Empty() {
+		return []byte("null"), nil
+	}
 	return json.Marshal(g.geometry)
 }
 
+// Empty is true for a geometry that has not been set (or was scanned from NULL)
+func (g *Geometry) Empty() bool {
+	return g.geometry.Type == ""
+}
+
 // Scan implements the sql.Scanner interface
 func (g *Geometry) Scan(value interface{}) error {
+	if value == nil {
+		g.geometry = geojson.Geometry{}
+		return nil
+	}
 	return g.geometry.Scan(value)
 }
 
@@ -48,3 +63,138 @@ func (g Geometry) Value() (driver.Value, error) {
 	}
 	return string(data), nil
 }
+
+// GeoJSON returns the decoded geometry (or nil if it is empty)
+func (g *Geometry) GeoJSON() *geojson.Geometry {
+	if g.Empty() {
+		return nil
+	}
+	return &g.geometry
+}
+
+// Type returns the GeoJSON geometry type (e.g. Point or MultiPolygon)
+func (g *Geometry) Type() string {
+	return string(g.geometry.Type)
+}
+
+// Dimension returns the number of values in each position of the geometry,
+// or 0 if the geometry is empty or mixes positions of different dimensions
+func (g *Geometry) Dimension() int {
+	positions := [][]float64{}
+	var collect func(*geojson.Geometry)
+	collect = func(geometry *geojson.Geometry) {
+		if geometry.Point != nil {
+			positions = append(positions, geometry.Point)
+		}
+		positions = append(positions, geometry.MultiPoint...)
+		positions = append(positions, geometry.LineString...)
+		for _, line := range geometry.MultiLineString {
+			positions = append(positions, line...)
+		}
+		for _, ring := range geometry.Polygon {
+			positions = append(positions, ring...)
+		}
+		for _, polygon := range geometry.MultiPolygon {
+			for _, ring := range polygon {
+				positions = append(positions, ring...)
+			}
+		}
+		for _, child := range geometry.Geometries {
+			collect(child)
+		}
+	}
+	collect(&g.geometry)
+
+	dimension := 0
+	for _, position := range positions {
+		if dimension == 0 {
+			dimension = len(position)
+		} else if len(position) != dimension {
+			return 0
+		}
+	}
+	return dimension
+}
+
+// Validate checks that the geometry has enough positions and that polygon
+// rings are closed.  Topological problems like self-intersections are not
+// detected.
+func (g *Geometry) Validate() error {
+	return validate(&g.geometry)
+}
+
+func validate(geometry *geojson.Geometry) error {
+	switch geometry.Type {
+	case geojson.GeometryPoint:
+		return validatePosition(geometry.Point)
+	case geojson.GeometryMultiPoint:
+		return validatePositions(geometry.MultiPoint, 1)
+	case geojson.GeometryLineString:
+		return validatePositions(geometry.LineString, 2)
+	case geojson.GeometryMultiLineString:
+		for _, line := range geometry.MultiLineString {
+			if err := validatePositions(line, 2); err != nil {
+				return err
+			}
+		}
+		return nil
+	case geojson.GeometryPolygon:
+		return validatePolygon(geometry.Polygon)
+	case geojson.GeometryMultiPolygon:
+		for _, polygon := range geometry.MultiPolygon {
+			if err := validatePolygon(polygon); err != nil {
+				return err
+			}
+		}
+		return nil
+	case geojson.GeometryCollection:
+		for _, child := range geometry.Geometries {
+			if err := validate(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unsupported geometry type '%s'", geometry.Type)
+}
+
+func validatePosition(position []float64) error {
+	if len(position) < 2 {
+		return errors.New("positions must have at least two values")
+	}
+	return nil
+}
+
+func validatePositions(positions [][]float64, min int) error {
+	if len(positions) < min {
+		return fmt.Errorf("expected at least %d positions, got %d", min, len(positions))
+	}
+	for _, position := range positions {
+		if err := validatePosition(position); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validatePolygon(rings [][][]float64) error {
+	if len(rings) == 0 {
+		return errors.New("polygons must have at least one ring")
+	}
+	for _, ring := range rings {
+		if err := validatePositions(ring, 4); err != nil {
+			return fmt.Errorf("bad polygon ring: %s", err)
+		}
+		first, last := ring[0], ring[len(ring)-1]
+		if len(first) != len(last) {
+			return errors.New("polygon rings must be closed")
+		}
+		for i := range first {
+			if first[i] != last[i] {
+				return errors.New("polygon rings must be closed")
+			}
+		}
+	}
+	return nil
+}
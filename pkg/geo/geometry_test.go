@@ -26,3 +26,70 @@ func TestRoundTripJSON(t *testing.T) {
 		assert.Equal(v, string(data), "expected round trip for case %d", i)
 	}
 }
+
+func TestTypeAndDimension(t *testing.T) {
+	assert := assert.New(t)
+	cases := []struct {
+		data      string
+		geomType  string
+		dimension int
+	}{
+		{`{"type":"Point","coordinates":[1,2]}`, "Point", 2},
+		{`{"type":"Point","coordinates":[1,2,3]}`, "Point", 3},
+		{`{"type":"LineString","coordinates":[[1,2,3],[3,4,5]]}`, "LineString", 3},
+		{`{"type":"LineString","coordinates":[[1,2],[3,4,5]]}`, "LineString", 0},
+		{`{"type":"MultiPolygon","coordinates":[[[[1,2],[3,4],[5,6],[1,2]]]]}`, "MultiPolygon", 2},
+		{`{"type":"GeometryCollection","geometries":[{"type":"Point","coordinates":[1,2,3]}]}`, "GeometryCollection", 3},
+		{`{"type":"MultiPoint","coordinates":[]}`, "MultiPoint", 0},
+	}
+
+	for _, c := range cases {
+		var g Geometry
+		if assert.Nil(g.UnmarshalJSON([]byte(c.data)), c.data) {
+			assert.Equal(c.geomType, g.Type(), c.data)
+			assert.Equal(c.dimension, g.Dimension(), c.data)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	assert := assert.New(t)
+	cases := []struct {
+		data string
+		ok   bool
+	}{
+		{`{"type":"Point","coordinates":[1,2]}`, true},
+		{`{"type":"Point","coordinates":[1]}`, false},
+		{`{"type":"LineString","coordinates":[[1,2],[3,4]]}`, true},
+		{`{"type":"LineString","coordinates":[[1,2]]}`, false},
+		{`{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]}`, true},
+		{`{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1]]]}`, false},
+		{`{"type":"Polygon","coordinates":[[[0,0],[1,1],[0,0]]]}`, false},
+		{`{"type":"Polygon","coordinates":[]}`, false},
+		{`{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,1]]]]}`, false},
+		{`{"type":"GeometryCollection","geometries":[{"type":"LineString","coordinates":[[1,2]]}]}`, false},
+	}
+
+	for _, c := range cases {
+		var g Geometry
+		if assert.Nil(g.UnmarshalJSON([]byte(c.data)), c.data) {
+			if c.ok {
+				assert.Nil(g.Validate(), c.data)
+			} else {
+				assert.NotNil(g.Validate(), c.data)
+			}
+		}
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	assert := assert.New(t)
+
+	var g Geometry
+	assert.Nil(g.Scan(nil))
+	assert.True(g.Empty())
+
+	data, err := g.MarshalJSON()
+	assert.Nil(err)
+	assert.Equal("null", string(data))
+}
@@ -0,0 +1,20 @@
+package geo
+
+import "math"
+
+// tileSize is the width of a tile in pixels
+const tileSize = 256
+
+// earthCircumference is the circumference of the Web Mercator sphere in meters
+const earthCircumference = 2 * math.Pi * 6378137
+
+// Resolution returns the size of a pixel at a zoom level of a Web Mercator
+// tile pyramid.  The size is in degrees for geographic coordinate reference
+// systems and in meters otherwise.
+func Resolution(zoom float64, crs CRS) float64 {
+	extent := earthCircumference
+	if crs.Geographic() {
+		extent = 360
+	}
+	return extent / (tileSize * math.Pow(2, zoom))
+}
@@ -0,0 +1,16 @@
+package geo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolution(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.InDelta(156543.03392804097, Resolution(0, CRS{SRID: 3857}), 1e-6)
+	assert.InDelta(0.5971642834779395, Resolution(18, CRS{SRID: 3857}), 1e-9)
+	assert.InDelta(1.40625, Resolution(0, CRS84), 1e-12)
+	assert.InDelta(0.703125, Resolution(1, CRS84), 1e-12)
+}
@@ -0,0 +1,85 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+)
+
+// TileMatrixSet is a tile pyramid where each zoom level has twice as many
+// rows and columns as the one before
+type TileMatrixSet struct {
+	// ID is the identifier of the set (e.g. WebMercatorQuad)
+	ID string
+	// URI is the identifier of the set definition
+	URI string
+	// CRS of the tile bounds
+	CRS CRS
+	// Bounds of the tile matrix at zoom 0 (minX, minY, maxX, maxY)
+	Bounds [4]float64
+	// Columns and Rows at zoom 0
+	Columns int
+	Rows    int
+	// MaxZoom is the largest zoom level
+	MaxZoom int
+}
+
+// webMercatorExtent is the half width of the Web Mercator projection in meters
+const webMercatorExtent = earthCircumference / 2
+
+// TileMatrixSets are the supported tile matrix sets by ID
+var TileMatrixSets = map[string]*TileMatrixSet{
+	"WebMercatorQuad": {
+		ID:      "WebMercatorQuad",
+		URI:     "http://www.opengis.net/def/tilematrixset/OGC/1.0/WebMercatorQuad",
+		CRS:     CRS{SRID: 3857},
+		Bounds:  [4]float64{-webMercatorExtent, -webMercatorExtent, webMercatorExtent, webMercatorExtent},
+		Columns: 1,
+		Rows:    1,
+		MaxZoom: 24,
+	},
+	"WorldCRS84Quad": {
+		ID:      "WorldCRS84Quad",
+		URI:     "http://www.opengis.net/def/tilematrixset/OGC/1.0/WorldCRS84Quad",
+		CRS:     CRS84,
+		Bounds:  [4]float64{-180, -90, 180, 90},
+		Columns: 2,
+		Rows:    1,
+		MaxZoom: 23,
+	},
+}
+
+// Tile is a tile in a tile matrix set
+type Tile struct {
+	Set  *TileMatrixSet
+	Zoom int
+	Col  int
+	Row  int
+}
+
+// NewTile returns a tile after making sure it is within the tile matrix set
+func (set *TileMatrixSet) NewTile(zoom, col, row int) (*Tile, error) {
+	if zoom < 0 || zoom > set.MaxZoom {
+		return nil, fmt.Errorf("zoom must be between 0 and %d", set.MaxZoom)
+	}
+	scale := 1 << uint(zoom)
+	if col < 0 || col >= set.Columns*scale {
+		return nil, fmt.Errorf("column must be between 0 and %d at zoom %d", set.Columns*scale-1, zoom)
+	}
+	if row < 0 || row >= set.Rows*scale {
+		return nil, fmt.Errorf("row must be between 0 and %d at zoom %d", set.Rows*scale-1, zoom)
+	}
+	return &Tile{Set: set, Zoom: zoom, Col: col, Row: row}, nil
+}
+
+// Size returns the width (and height) of the tile in CRS units
+func (tile *Tile) Size() float64 {
+	return (tile.Set.Bounds[2] - tile.Set.Bounds[0]) / float64(tile.Set.Columns) / math.Pow(2, float64(tile.Zoom))
+}
+
+// Bounds returns the bounding box of the tile (rows are counted from the top)
+func (tile *Tile) Bounds() *BBox {
+	size := tile.Size()
+	minX := tile.Set.Bounds[0] + float64(tile.Col)*size
+	maxY := tile.Set.Bounds[3] - float64(tile.Row)*size
+	return &BBox{MinX: minX, MinY: maxY - size, MaxX: minX + size, MaxY: maxY, CRS: tile.Set.CRS}
+}
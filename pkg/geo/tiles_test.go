@@ -0,0 +1,62 @@
+package geo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTile(t *testing.T) {
+	assert := assert.New(t)
+
+	webMercator := TileMatrixSets["WebMercatorQuad"]
+	crs84 := TileMatrixSets["WorldCRS84Quad"]
+
+	cases := []struct {
+		set  *TileMatrixSet
+		zoom int
+		col  int
+		row  int
+		err  bool
+	}{
+		{set: webMercator, zoom: 0, col: 0, row: 0},
+		{set: webMercator, zoom: 0, col: 1, row: 0, err: true},
+		{set: webMercator, zoom: 2, col: 3, row: 3},
+		{set: webMercator, zoom: 2, col: 3, row: 4, err: true},
+		{set: webMercator, zoom: -1, col: 0, row: 0, err: true},
+		{set: webMercator, zoom: 25, col: 0, row: 0, err: true},
+		{set: crs84, zoom: 0, col: 1, row: 0},
+		{set: crs84, zoom: 0, col: 0, row: 1, err: true},
+	}
+
+	for _, c := range cases {
+		tile, err := c.set.NewTile(c.zoom, c.col, c.row)
+		if c.err {
+			assert.NotNil(err, "%s %d/%d/%d", c.set.ID, c.zoom, c.col, c.row)
+			continue
+		}
+		if assert.Nil(err, "%s %d/%d/%d", c.set.ID, c.zoom, c.col, c.row) {
+			assert.Equal(c.zoom, tile.Zoom)
+		}
+	}
+}
+
+func TestTileBounds(t *testing.T) {
+	assert := assert.New(t)
+
+	tile, err := TileMatrixSets["WebMercatorQuad"].NewTile(1, 1, 0)
+	if assert.Nil(err) {
+		bounds := tile.Bounds()
+		assert.InDelta(0, bounds.MinX, 1e-6)
+		assert.InDelta(0, bounds.MinY, 1e-6)
+		assert.InDelta(webMercatorExtent, bounds.MaxX, 1e-6)
+		assert.InDelta(webMercatorExtent, bounds.MaxY, 1e-6)
+		assert.Equal(3857, bounds.CRS.SRID)
+		assert.InDelta(Resolution(1, CRS{SRID: 3857})*tileSize, tile.Size(), 1e-6)
+	}
+
+	tile, err = TileMatrixSets["WorldCRS84Quad"].NewTile(1, 3, 1)
+	if assert.Nil(err) {
+		assert.Equal(&BBox{MinX: 90, MinY: -90, MaxX: 180, MaxY: 0, CRS: CRS84}, tile.Bounds())
+	}
+}
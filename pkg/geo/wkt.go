@@ -0,0 +1,123 @@
+package geo
+
+import (
+	"strconv"
+	"strings"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// WKT returns the geometry as well-known text (or an empty string if it is
+// empty).  Positions with a third value are written with a Z.
+func (g *Geometry) WKT() string {
+	if g.Empty() {
+		return ""
+	}
+	dimension := g.Dimension()
+	if dimension != 3 {
+		dimension = 2
+	}
+	builder := &strings.Builder{}
+	writeWKT(builder, &g.geometry, dimension)
+	return builder.String()
+}
+
+var wktTypes = map[geojson.GeometryType]string{
+	geojson.GeometryPoint:           "POINT",
+	geojson.GeometryMultiPoint:      "MULTIPOINT",
+	geojson.GeometryLineString:      "LINESTRING",
+	geojson.GeometryMultiLineString: "MULTILINESTRING",
+	geojson.GeometryPolygon:         "POLYGON",
+	geojson.GeometryMultiPolygon:    "MULTIPOLYGON",
+	geojson.GeometryCollection:      "GEOMETRYCOLLECTION",
+}
+
+func writeWKT(builder *strings.Builder, geometry *geojson.Geometry, dimension int) {
+	builder.WriteString(wktTypes[geometry.Type])
+	if dimension == 3 {
+		builder.WriteString(" Z")
+	}
+	builder.WriteString(" ")
+
+	switch geometry.Type {
+	case geojson.GeometryPoint:
+		writeWKTPositions(builder, [][]float64{geometry.Point}, dimension)
+	case geojson.GeometryMultiPoint:
+		points := make([][][]float64, len(geometry.MultiPoint))
+		for i, point := range geometry.MultiPoint {
+			points[i] = [][]float64{point}
+		}
+		writeWKTRings(builder, points, dimension)
+	case geojson.GeometryLineString:
+		writeWKTPositions(builder, geometry.LineString, dimension)
+	case geojson.GeometryMultiLineString:
+		writeWKTRings(builder, geometry.MultiLineString, dimension)
+	case geojson.GeometryPolygon:
+		writeWKTRings(builder, geometry.Polygon, dimension)
+	case geojson.GeometryMultiPolygon:
+		if len(geometry.MultiPolygon) == 0 {
+			builder.WriteString("EMPTY")
+			return
+		}
+		builder.WriteString("(")
+		for i, polygon := range geometry.MultiPolygon {
+			if i > 0 {
+				builder.WriteString(", ")
+			}
+			writeWKTRings(builder, polygon, dimension)
+		}
+		builder.WriteString(")")
+	case geojson.GeometryCollection:
+		if len(geometry.Geometries) == 0 {
+			builder.WriteString("EMPTY")
+			return
+		}
+		builder.WriteString("(")
+		for i, child := range geometry.Geometries {
+			if i > 0 {
+				builder.WriteString(", ")
+			}
+			writeWKT(builder, child, dimension)
+		}
+		builder.WriteString(")")
+	}
+}
+
+func writeWKTRings(builder *strings.Builder, rings [][][]float64, dimension int) {
+	if len(rings) == 0 {
+		builder.WriteString("EMPTY")
+		return
+	}
+	builder.WriteString("(")
+	for i, ring := range rings {
+		if i > 0 {
+			builder.WriteString(", ")
+		}
+		writeWKTPositions(builder, ring, dimension)
+	}
+	builder.WriteString(")")
+}
+
+func writeWKTPositions(builder *strings.Builder, positions [][]float64, dimension int) {
+	if len(positions) == 0 {
+		builder.WriteString("EMPTY")
+		return
+	}
+	builder.WriteString("(")
+	for i, position := range positions {
+		if i > 0 {
+			builder.WriteString(", ")
+		}
+		for j := 0; j < dimension; j++ {
+			if j > 0 {
+				builder.WriteString(" ")
+			}
+			value := 0.0
+			if j < len(position) {
+				value = position[j]
+			}
+			builder.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+		}
+	}
+	builder.WriteString(")")
+}
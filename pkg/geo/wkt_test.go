@@ -0,0 +1,34 @@
+package geo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWKT(t *testing.T) {
+	assert := assert.New(t)
+	cases := []struct {
+		data string
+		wkt  string
+	}{
+		{`{"type":"Point","coordinates":[1.5,-2]}`, "POINT (1.5 -2)"},
+		{`{"type":"Point","coordinates":[1,2,3]}`, "POINT Z (1 2 3)"},
+		{`{"type":"MultiPoint","coordinates":[[1,2],[3,4]]}`, "MULTIPOINT ((1 2), (3 4))"},
+		{`{"type":"MultiPoint","coordinates":[]}`, "MULTIPOINT EMPTY"},
+		{`{"type":"LineString","coordinates":[[1,2],[3,4]]}`, "LINESTRING (1 2, 3 4)"},
+		{`{"type":"MultiLineString","coordinates":[[[1,2],[3,4]],[[5,6],[7,8]]]}`, "MULTILINESTRING ((1 2, 3 4), (5 6, 7 8))"},
+		{`{"type":"Polygon","coordinates":[[[0,0],[4,0],[4,4],[0,0]],[[1,1],[2,1],[2,2],[1,1]]]}`, "POLYGON ((0 0, 4 0, 4 4, 0 0), (1 1, 2 1, 2 2, 1 1))"},
+		{`{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,0]]]]}`, "MULTIPOLYGON (((0 0, 1 0, 1 1, 0 0)))"},
+		{`{"type":"GeometryCollection","geometries":[{"type":"Point","coordinates":[1,2]},{"type":"LineString","coordinates":[[1,2],[3,4]]}]}`, "GEOMETRYCOLLECTION (POINT (1 2), LINESTRING (1 2, 3 4))"},
+	}
+
+	for _, c := range cases {
+		var g Geometry
+		if assert.Nil(g.UnmarshalJSON([]byte(c.data)), c.data) {
+			assert.Equal(c.wkt, g.WKT(), c.data)
+		}
+	}
+
+	assert.Equal("", (&Geometry{}).WKT())
+}
@@ -0,0 +1,180 @@
+package gpkg
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// WKB geometry type codes (ISO codes add 1000 for positions with Z)
+var wkbTypes = map[geojson.GeometryType]uint32{
+	geojson.GeometryPoint:           1,
+	geojson.GeometryLineString:      2,
+	geojson.GeometryPolygon:         3,
+	geojson.GeometryMultiPoint:      4,
+	geojson.GeometryMultiLineString: 5,
+	geojson.GeometryMultiPolygon:    6,
+	geojson.GeometryCollection:      7,
+}
+
+// GeometryTypeName returns the GeoPackage geometry type name for a GeoJSON
+// geometry type name (or GEOMETRY for an empty name)
+func GeometryTypeName(name string) string {
+	if name == "" {
+		return "GEOMETRY"
+	}
+	return strings.ToUpper(name)
+}
+
+// envelope is a bounding box
+type envelope struct {
+	minX, maxX, minY, maxY float64
+}
+
+func emptyEnvelope() envelope {
+	return envelope{math.Inf(1), math.Inf(-1), math.Inf(1), math.Inf(-1)}
+}
+
+func (e envelope) empty() bool {
+	return e.minX > e.maxX
+}
+
+func (e *envelope) add(x, y float64) {
+	e.minX = math.Min(e.minX, x)
+	e.maxX = math.Max(e.maxX, x)
+	e.minY = math.Min(e.minY, y)
+	e.maxY = math.Max(e.maxY, y)
+}
+
+func (e *envelope) expand(other envelope) {
+	if other.empty() {
+		return
+	}
+	e.add(other.minX, other.minY)
+	e.add(other.maxX, other.maxY)
+}
+
+// wkb encodes geometries as little-endian ISO WKB
+type wkb struct {
+	data []byte
+	hasZ bool
+	// swap is true if positions are in y, x order
+	swap   bool
+	bounds envelope
+}
+
+func (w *wkb) uint32(value uint32) {
+	w.data = binary.LittleEndian.AppendUint32(w.data, value)
+}
+
+func (w *wkb) float64(value float64) {
+	w.data = binary.LittleEndian.AppendUint64(w.data, math.Float64bits(value))
+}
+
+func (w *wkb) header(geometryType geojson.GeometryType) {
+	code := wkbTypes[geometryType]
+	if w.hasZ {
+		code += 1000
+	}
+	w.data = append(w.data, 1)
+	w.uint32(code)
+}
+
+func (w *wkb) position(position []float64) {
+	x, y := position[0], position[1]
+	if w.swap {
+		x, y = y, x
+	}
+	w.bounds.add(x, y)
+	w.float64(x)
+	w.float64(y)
+	if w.hasZ {
+		z := 0.0
+		if len(position) > 2 {
+			z = position[2]
+		}
+		w.float64(z)
+	}
+}
+
+func (w *wkb) positions(positions [][]float64) {
+	w.uint32(uint32(len(positions)))
+	for _, position := range positions {
+		w.position(position)
+	}
+}
+
+func (w *wkb) rings(rings [][][]float64) {
+	w.uint32(uint32(len(rings)))
+	for _, ring := range rings {
+		w.positions(ring)
+	}
+}
+
+func (w *wkb) geometry(geometry *geojson.Geometry) {
+	w.header(geometry.Type)
+	switch geometry.Type {
+	case geojson.GeometryPoint:
+		w.position(geometry.Point)
+	case geojson.GeometryLineString:
+		w.positions(geometry.LineString)
+	case geojson.GeometryPolygon:
+		w.rings(geometry.Polygon)
+	case geojson.GeometryMultiPoint:
+		w.uint32(uint32(len(geometry.MultiPoint)))
+		for _, point := range geometry.MultiPoint {
+			w.header(geojson.GeometryPoint)
+			w.position(point)
+		}
+	case geojson.GeometryMultiLineString:
+		w.uint32(uint32(len(geometry.MultiLineString)))
+		for _, line := range geometry.MultiLineString {
+			w.header(geojson.GeometryLineString)
+			w.positions(line)
+		}
+	case geojson.GeometryMultiPolygon:
+		w.uint32(uint32(len(geometry.MultiPolygon)))
+		for _, polygon := range geometry.MultiPolygon {
+			w.header(geojson.GeometryPolygon)
+			w.rings(polygon)
+		}
+	case geojson.GeometryCollection:
+		w.uint32(uint32(len(geometry.Geometries)))
+		for _, child := range geometry.Geometries {
+			w.geometry(child)
+		}
+	}
+}
+
+// Flags in the GeoPackage geometry header
+const (
+	flagLittleEndian = 0x01
+	flagEnvelopeXY   = 0x02
+	flagEmpty        = 0x10
+)
+
+// encodeGeometry returns a GeoPackage geometry blob (a header with the
+// SRS id and envelope followed by WKB) and the envelope of the geometry.
+// Points are written without an envelope.
+func encodeGeometry(geometry *geojson.Geometry, srsID int32, hasZ bool, swap bool) ([]byte, envelope) {
+	w := &wkb{hasZ: hasZ, swap: swap, bounds: emptyEnvelope()}
+	w.geometry(geometry)
+
+	flags := byte(flagLittleEndian)
+	if w.bounds.empty() {
+		flags |= flagEmpty
+	} else if geometry.Type != geojson.GeometryPoint {
+		flags |= flagEnvelopeXY
+	}
+
+	data := []byte{'G', 'P', 0, flags}
+	data = binary.LittleEndian.AppendUint32(data, uint32(srsID))
+	if flags&flagEnvelopeXY != 0 {
+		for _, value := range []float64{w.bounds.minX, w.bounds.maxX, w.bounds.minY, w.bounds.maxY} {
+			data = binary.LittleEndian.AppendUint64(data, math.Float64bits(value))
+		}
+	}
+	return append(data, w.data...), w.bounds
+}
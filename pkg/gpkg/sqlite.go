@@ -0,0 +1,389 @@
+package gpkg
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+)
+
+// A GeoPackage is a SQLite database.  This is a minimal writer for the
+// database file format (https://www.sqlite.org/fileformat.html).  Tables are
+// written as b-trees with rows in rowid order, so a tree can be built one leaf
+// page at a time.  Page 1 holds the database header and the schema table and
+// is written last.
+
+// pageSize is the size of database pages
+const pageSize = 4096
+
+// headerSize is the size of the database header at the start of page 1
+const headerSize = 100
+
+// B-tree page types
+const (
+	interiorTable = 0x05
+	leafIndex     = 0x0a
+	leafTable     = 0x0d
+)
+
+// database allocates and writes pages in a file
+type database struct {
+	file  *os.File
+	pages uint32
+}
+
+// newDatabase creates a database in a temporary file with page 1 reserved
+func newDatabase() (*database, error) {
+	file, err := os.CreateTemp("", "pgfs-*.gpkg")
+	if err != nil {
+		return nil, err
+	}
+	return &database{file: file, pages: 1}, nil
+}
+
+// allocate returns the number of a new page
+func (db *database) allocate() uint32 {
+	db.pages++
+	return db.pages
+}
+
+func (db *database) writePage(number uint32, page []byte) error {
+	_, err := db.file.WriteAt(page, int64(number-1)*pageSize)
+	return err
+}
+
+// writeBtreePage writes cells to a b-tree page.  Interior pages have a
+// pointer to the right-most child.  The page header of page 1 follows the
+// database header.
+func (db *database) writeBtreePage(number uint32, pageType byte, cells [][]byte, rightmost uint32) error {
+	page := make([]byte, pageSize)
+	offset := 0
+	if number == 1 {
+		offset = headerSize
+	}
+
+	pointers := offset + 8
+	if pageType == interiorTable {
+		pointers = offset + 12
+		binary.BigEndian.PutUint32(page[offset+8:], rightmost)
+	}
+
+	page[offset] = pageType
+	binary.BigEndian.PutUint16(page[offset+3:], uint16(len(cells)))
+	content := pageSize
+	for i, cell := range cells {
+		content -= len(cell)
+		if content < pointers+2*len(cells) {
+			return fmt.Errorf("cells do not fit on page %d", number)
+		}
+		copy(page[content:], cell)
+		binary.BigEndian.PutUint16(page[pointers+2*i:], uint16(content))
+	}
+	binary.BigEndian.PutUint16(page[offset+5:], uint16(content))
+
+	return db.writePage(number, page)
+}
+
+// writeOverflow writes the part of a payload that does not fit in a cell to a
+// chain of overflow pages and returns the first page
+func (db *database) writeOverflow(payload []byte) (uint32, error) {
+	chunk := pageSize - 4
+	numbers := make([]uint32, (len(payload)+chunk-1)/chunk)
+	for i := range numbers {
+		numbers[i] = db.allocate()
+	}
+	for i, number := range numbers {
+		page := make([]byte, pageSize)
+		if i < len(numbers)-1 {
+			binary.BigEndian.PutUint32(page, numbers[i+1])
+		}
+		end := (i + 1) * chunk
+		if end > len(payload) {
+			end = len(payload)
+		}
+		copy(page[4:], payload[i*chunk:end])
+		if err := db.writePage(number, page); err != nil {
+			return 0, err
+		}
+	}
+	return numbers[0], nil
+}
+
+// localPayload returns the number of payload bytes stored in a cell given
+// the largest payload that fits in a cell
+func localPayload(size int, max int) int {
+	if size <= max {
+		return size
+	}
+	min := (pageSize-12)*32/255 - 23
+	local := min + (size-min)%(pageSize-4)
+	if local > max {
+		return min
+	}
+	return local
+}
+
+// cell returns a cell with a prefix (payload size and rowid) and a payload,
+// writing overflow pages if needed
+func (db *database) cell(prefix []byte, payload []byte, max int) ([]byte, error) {
+	local := localPayload(len(payload), max)
+	cell := append(prefix, payload[:local]...)
+	if local < len(payload) {
+		overflow, err := db.writeOverflow(payload[local:])
+		if err != nil {
+			return nil, err
+		}
+		cell = binary.BigEndian.AppendUint32(cell, overflow)
+	}
+	return cell, nil
+}
+
+// header returns the database header
+func (db *database) header(userVersion uint32, applicationID uint32) []byte {
+	header := make([]byte, headerSize)
+	copy(header, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(header[16:], pageSize)
+	header[18] = 1 // legacy write version
+	header[19] = 1 // legacy read version
+	header[21] = 64
+	header[22] = 32
+	header[23] = 32
+	binary.BigEndian.PutUint32(header[24:], 1) // change counter
+	binary.BigEndian.PutUint32(header[28:], db.pages)
+	binary.BigEndian.PutUint32(header[40:], 1) // schema cookie
+	binary.BigEndian.PutUint32(header[44:], 4) // schema format
+	binary.BigEndian.PutUint32(header[56:], 1) // UTF-8
+	binary.BigEndian.PutUint32(header[60:], userVersion)
+	binary.BigEndian.PutUint32(header[68:], applicationID)
+	binary.BigEndian.PutUint32(header[92:], 1) // version valid for
+	binary.BigEndian.PutUint32(header[96:], 3045000)
+	return header
+}
+
+// finish writes the database header and returns the size of the file
+func (db *database) finish(userVersion uint32, applicationID uint32) (int64, error) {
+	if _, err := db.file.WriteAt(db.header(userVersion, applicationID), 0); err != nil {
+		return 0, err
+	}
+	return int64(db.pages) * pageSize, nil
+}
+
+// close removes the database file
+func (db *database) close() error {
+	closeErr := db.file.Close()
+	if err := os.Remove(db.file.Name()); err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// putVarint encodes an integer as a SQLite varint (big-endian with seven
+// bits per byte and all eight bits in a ninth byte)
+func putVarint(value uint64) []byte {
+	if value > 0x00ffffffffffffff {
+		data := make([]byte, 9)
+		data[8] = byte(value)
+		value >>= 8
+		for i := 7; i >= 0; i-- {
+			data[i] = byte(value&0x7f) | 0x80
+			value >>= 7
+		}
+		return data
+	}
+
+	data := []byte{byte(value & 0x7f)}
+	value >>= 7
+	for value > 0 {
+		data = append([]byte{byte(value&0x7f) | 0x80}, data...)
+		value >>= 7
+	}
+	return data
+}
+
+// record encodes values in the SQLite record format.  Values are nil, bool,
+// int64, float64, string, or []byte.
+func record(values ...interface{}) []byte {
+	types := []byte{}
+	body := []byte{}
+	for _, value := range values {
+		switch v := value.(type) {
+		case nil:
+			types = append(types, 0)
+		case bool:
+			if v {
+				types = append(types, 9)
+			} else {
+				types = append(types, 8)
+			}
+		case int64:
+			serialType, data := integer(v)
+			types = append(types, putVarint(serialType)...)
+			body = append(body, data...)
+		case float64:
+			types = append(types, 7)
+			body = binary.BigEndian.AppendUint64(body, math.Float64bits(v))
+		case string:
+			types = append(types, putVarint(uint64(13+2*len(v)))...)
+			body = append(body, v...)
+		case []byte:
+			types = append(types, putVarint(uint64(12+2*len(v)))...)
+			body = append(body, v...)
+		default:
+			panic(fmt.Sprintf("unsupported record value %T", value))
+		}
+	}
+
+	// the header size includes the size of its own varint
+	size := len(types) + 1
+	for len(putVarint(uint64(size)))+len(types) != size {
+		size = len(putVarint(uint64(size))) + len(types)
+	}
+
+	data := putVarint(uint64(size))
+	data = append(data, types...)
+	return append(data, body...)
+}
+
+// integer returns the serial type and big-endian bytes of an integer
+func integer(v int64) (uint64, []byte) {
+	switch {
+	case v == 0:
+		return 8, nil
+	case v == 1:
+		return 9, nil
+	case v >= math.MinInt8 && v <= math.MaxInt8:
+		return 1, []byte{byte(v)}
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		return 2, binary.BigEndian.AppendUint16(nil, uint16(v))
+	case v >= -1<<23 && v < 1<<23:
+		return 3, binary.BigEndian.AppendUint32(nil, uint32(v))[1:]
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		return 4, binary.BigEndian.AppendUint32(nil, uint32(v))
+	case v >= -1<<47 && v < 1<<47:
+		return 5, binary.BigEndian.AppendUint64(nil, uint64(v))[2:]
+	default:
+		return 6, binary.BigEndian.AppendUint64(nil, uint64(v))
+	}
+}
+
+// child is a page in a b-tree with the largest rowid in its subtree
+type child struct {
+	page uint32
+	key  int64
+}
+
+// tableTree writes a table b-tree.  Rows must be added in rowid order.
+// Leaf pages are written as they fill.  The root is written when the tree is
+// finished (to page 1 for the schema table).
+type tableTree struct {
+	db *database
+	// root is the fixed page number of the root (or 0 to allocate one)
+	root uint32
+	// capacity is the space for cells and pointers on each page
+	capacity int
+	cells    [][]byte
+	used     int
+	lastKey  int64
+	leaves   []*child
+}
+
+func newTableTree(db *database, root uint32) *tableTree {
+	capacity := pageSize - 12
+	if root == 1 {
+		capacity -= headerSize
+	}
+	return &tableTree{db: db, root: root, capacity: capacity}
+}
+
+// maxTableLocal is the largest payload stored in a table leaf cell
+const maxTableLocal = pageSize - 35
+
+// add appends a row to the tree
+func (tree *tableTree) add(rowid int64, payload []byte) error {
+	prefix := append(putVarint(uint64(len(payload))), putVarint(uint64(rowid))...)
+	cell, err := tree.db.cell(prefix, payload, maxTableLocal)
+	if err != nil {
+		return err
+	}
+
+	if tree.used+2+len(cell) > tree.capacity {
+		if err := tree.flush(); err != nil {
+			return err
+		}
+	}
+
+	tree.cells = append(tree.cells, cell)
+	tree.used += 2 + len(cell)
+	tree.lastKey = rowid
+	return nil
+}
+
+// flush writes the current leaf page
+func (tree *tableTree) flush() error {
+	number := tree.db.allocate()
+	if err := tree.db.writeBtreePage(number, leafTable, tree.cells, 0); err != nil {
+		return err
+	}
+	tree.leaves = append(tree.leaves, &child{page: number, key: tree.lastKey})
+	tree.cells = nil
+	tree.used = 0
+	return nil
+}
+
+// finish writes the remaining pages and returns the root page
+func (tree *tableTree) finish() (uint32, error) {
+	if len(tree.leaves) == 0 {
+		root := tree.root
+		if root == 0 {
+			root = tree.db.allocate()
+		}
+		return root, tree.db.writeBtreePage(root, leafTable, tree.cells, 0)
+	}
+
+	if len(tree.cells) > 0 {
+		if err := tree.flush(); err != nil {
+			return 0, err
+		}
+	}
+
+	// interior cells have a child page number and a varint key (at most 13 bytes)
+	fanout := (tree.capacity-2*9)/(2+13) + 1
+	level := tree.leaves
+	for {
+		groups := (len(level) + fanout - 1) / fanout
+		top := groups == 1
+		parents := make([]*child, 0, groups)
+		for i := 0; i < groups; i++ {
+			group := level[i*len(level)/groups : (i+1)*len(level)/groups]
+			cells := make([][]byte, len(group)-1)
+			for j, c := range group[:len(group)-1] {
+				cells[j] = append(binary.BigEndian.AppendUint32(nil, c.page), putVarint(uint64(c.key))...)
+			}
+			right := group[len(group)-1]
+
+			number := tree.root
+			if !top || number == 0 {
+				number = tree.db.allocate()
+			}
+			if err := tree.db.writeBtreePage(number, interiorTable, cells, right.page); err != nil {
+				return 0, err
+			}
+			parents = append(parents, &child{page: number, key: right.key})
+		}
+		if top {
+			return parents[0].page, nil
+		}
+		level = parents
+	}
+}
+
+// writeIndex writes an index b-tree with records that fit on one leaf page
+// (records must be sorted) and returns the page
+func (db *database) writeIndex(records ...[]byte) (uint32, error) {
+	cells := make([][]byte, len(records))
+	for i, r := range records {
+		cells[i] = append(putVarint(uint64(len(r))), r...)
+	}
+	number := db.allocate()
+	return number, db.writeBtreePage(number, leafIndex, cells, 0)
+}
@@ -0,0 +1,77 @@
+package gpkg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPutVarint(t *testing.T) {
+	assert := assert.New(t)
+	cases := []struct {
+		value uint64
+		data  []byte
+	}{
+		{0, []byte{0x00}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x81, 0x00}},
+		{300, []byte{0x82, 0x2c}},
+		{1<<56 - 1, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}},
+		{1 << 56, []byte{0x80, 0xc0, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x00}},
+		{1<<64 - 1, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+	}
+
+	for _, c := range cases {
+		assert.Equal(c.data, putVarint(c.value), "%d", c.value)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	assert := assert.New(t)
+
+	// header size, serial types (null, true, one byte int, two byte int, float, text, blob), body
+	data := record(nil, true, int64(-2), int64(300), 1.5, "ab", []byte{7})
+	assert.Equal([]byte{8, 0, 9, 1, 2, 7, 17, 14, 0xfe, 0x01, 0x2c, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0, 'a', 'b', 7}, data)
+
+	// the header size varint counts itself
+	values := make([]interface{}, 126)
+	data = record(values...)
+	assert.Equal(127, int(data[0]))
+	data = record(append(values, nil)...)
+	assert.Equal([]byte{0x81, 0x01}, data[:2])
+	assert.Len(data, 129)
+}
+
+func TestInteger(t *testing.T) {
+	assert := assert.New(t)
+	cases := []struct {
+		value      int64
+		serialType uint64
+		size       int
+	}{
+		{0, 8, 0},
+		{1, 9, 0},
+		{-1, 1, 1},
+		{1000, 2, 2},
+		{-1 << 23, 3, 3},
+		{1 << 23, 4, 4},
+		{1 << 40, 5, 6},
+		{1 << 50, 6, 8},
+	}
+
+	for _, c := range cases {
+		serialType, data := integer(c.value)
+		assert.Equal(c.serialType, serialType, "%d", c.value)
+		assert.Len(data, c.size, "%d", c.value)
+	}
+}
+
+func TestLocalPayload(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(100, localPayload(100, maxTableLocal))
+	assert.Equal(maxTableLocal, localPayload(maxTableLocal, maxTableLocal))
+	// the overflow fills whole pages (4092 bytes) and the remainder stays local
+	assert.Equal(489+(5000-489)%4092, localPayload(5000, maxTableLocal))
+	assert.Equal(489, localPayload(489+4092+4000, maxTableLocal))
+}
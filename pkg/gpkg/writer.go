@@ -0,0 +1,368 @@
+// Package gpkg writes features to a GeoPackage (https://www.geopackage.org)
+// without depending on SQLite.
+package gpkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// MediaType is the media type for GeoPackage
+const MediaType = "application/geopackage+sqlite3"
+
+// Values of the application_id and user_version pragmas (version 1.3)
+const (
+	applicationID = 0x47504b47
+	userVersion   = 10300
+)
+
+// ColumnType is the type of a property
+type ColumnType string
+
+// Column types used for properties.  JSON values are written as text.
+const (
+	Boolean ColumnType = "BOOLEAN"
+	Real    ColumnType = "REAL"
+	Text    ColumnType = "TEXT"
+	JSON    ColumnType = "JSON"
+)
+
+// Column describes a feature property
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// Header describes the features in a file
+type Header struct {
+	// Name is the name of the feature table
+	Name         string
+	Title        string
+	Description  string
+	GeometryType string
+	HasZ         bool
+	Columns      []*Column
+	// SRID is the EPSG code of the coordinate reference system (0 if unknown)
+	SRID int
+	// Definition is the WKT definition of the coordinate reference system
+	// (not needed for EPSG:4326)
+	Definition string
+	// LatLon is true if positions are in latitude, longitude order.  They are
+	// written in longitude, latitude order.
+	LatLon bool
+}
+
+// Names of the row id, geometry, and feature id columns
+const (
+	fidColumn      = "fid"
+	geometryColumn = "geom"
+	idColumn       = "id"
+)
+
+// wgs84 is the definition of EPSG:4326 from the GeoPackage specification
+const wgs84 = `GEOGCS["WGS 84",DATUM["WGS_1984",SPHEROID["WGS 84",6378137,298.257223563,AUTHORITY["EPSG","7030"]],AUTHORITY["EPSG","6326"]],PRIMEM["Greenwich",0,AUTHORITY["EPSG","8901"]],UNIT["degree",0.0174532925199433,AUTHORITY["EPSG","9122"]],AUTHORITY["EPSG","4326"]]`
+
+// Writer writes features to a GeoPackage.  Features are written to the
+// pages of a temporary database file as they are added.  The other tables
+// and the schema are written when all features have been added.
+type Writer struct {
+	header *Header
+	// names are the column names in the feature table (which may differ
+	// from property names that clash with other columns)
+	names    []string
+	db       *database
+	features *tableTree
+	count    int64
+	extent   envelope
+}
+
+// NewWriter creates a writer for features described by a header.  The writer
+// must be closed to remove its temporary file.
+func NewWriter(header *Header) (*Writer, error) {
+	lower := strings.ToLower(header.Name)
+	if strings.HasPrefix(lower, "sqlite_") || strings.HasPrefix(lower, "gpkg_") {
+		return nil, fmt.Errorf("table name '%s' is reserved", header.Name)
+	}
+
+	db, err := newDatabase()
+	if err != nil {
+		return nil, err
+	}
+
+	used := map[string]bool{fidColumn: true, geometryColumn: true, idColumn: true}
+	names := make([]string, len(header.Columns))
+	for i, column := range header.Columns {
+		name := column.Name
+		for suffix := 2; used[strings.ToLower(name)]; suffix++ {
+			name = fmt.Sprintf("%s_%d", column.Name, suffix)
+		}
+		used[strings.ToLower(name)] = true
+		names[i] = name
+	}
+
+	return &Writer{
+		header:   header,
+		names:    names,
+		db:       db,
+		features: newTableTree(db, 0),
+		extent:   emptyEnvelope(),
+	}, nil
+}
+
+// srsID is the spatial reference system id of the geometries
+func (w *Writer) srsID() int32 {
+	return int32(w.header.SRID)
+}
+
+// Add writes a feature with an id.  Property values that do not match the
+// column type are written as null.
+func (w *Writer) Add(id string, geometry *geojson.Geometry, properties map[string]interface{}) error {
+	values := make([]interface{}, 0, 3+len(w.header.Columns))
+
+	// the fid column is an alias for the rowid and is stored as null
+	values = append(values, nil)
+
+	if geometry != nil && geometry.Type != "" {
+		blob, bounds := encodeGeometry(geometry, w.srsID(), w.header.HasZ, w.header.LatLon)
+		w.extent.expand(bounds)
+		values = append(values, blob)
+	} else {
+		values = append(values, nil)
+	}
+
+	values = append(values, id)
+
+	for _, column := range w.header.Columns {
+		value, err := columnValue(column.Type, properties[column.Name])
+		if err != nil {
+			return err
+		}
+		values = append(values, value)
+	}
+
+	w.count++
+	return w.features.add(w.count, record(values...))
+}
+
+func columnValue(columnType ColumnType, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	switch columnType {
+	case Boolean:
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+	case Real:
+		if v, ok := value.(float64); ok {
+			return v, nil
+		}
+	case Text:
+		if v, ok := value.(string); ok {
+			return v, nil
+		}
+	default:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	}
+	return nil, nil
+}
+
+// quote returns a quoted SQL identifier
+func quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// Statements that create the tables required by the specification
+const (
+	createSpatialRefSys = `CREATE TABLE gpkg_spatial_ref_sys (srs_name TEXT NOT NULL, srs_id INTEGER PRIMARY KEY, organization TEXT NOT NULL, organization_coordsys_id INTEGER NOT NULL, definition TEXT NOT NULL, description TEXT)`
+
+	createContents = `CREATE TABLE gpkg_contents (table_name TEXT NOT NULL PRIMARY KEY, data_type TEXT NOT NULL, identifier TEXT UNIQUE, description TEXT DEFAULT '', last_change DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')), min_x DOUBLE, min_y DOUBLE, max_x DOUBLE, max_y DOUBLE, srs_id INTEGER, CONSTRAINT fk_gc_r_srs_id FOREIGN KEY (srs_id) REFERENCES gpkg_spatial_ref_sys(srs_id))`
+
+	createGeometryColumns = `CREATE TABLE gpkg_geometry_columns (table_name TEXT NOT NULL, column_name TEXT NOT NULL, geometry_type_name TEXT NOT NULL, srs_id INTEGER NOT NULL, z TINYINT NOT NULL, m TINYINT NOT NULL, CONSTRAINT pk_geom_cols PRIMARY KEY (table_name, column_name), CONSTRAINT uk_gc_table_name UNIQUE (table_name), CONSTRAINT fk_gc_tn FOREIGN KEY (table_name) REFERENCES gpkg_contents(table_name), CONSTRAINT fk_gc_srs FOREIGN KEY (srs_id) REFERENCES gpkg_spatial_ref_sys (srs_id))`
+)
+
+// createFeatures returns the statement that creates the feature table
+func (w *Writer) createFeatures() string {
+	definitions := []string{
+		fmt.Sprintf("%s INTEGER PRIMARY KEY NOT NULL", fidColumn),
+		fmt.Sprintf("%s %s", geometryColumn, GeometryTypeName(w.header.GeometryType)),
+		fmt.Sprintf("%s TEXT", idColumn),
+	}
+	for i, column := range w.header.Columns {
+		sqlType := column.Type
+		if sqlType == JSON {
+			sqlType = Text
+		}
+		definitions = append(definitions, fmt.Sprintf("%s %s", quote(w.names[i]), sqlType))
+	}
+	return fmt.Sprintf("CREATE TABLE %s (%s)", quote(w.header.Name), strings.Join(definitions, ", "))
+}
+
+// spatialRefSys returns the rows of the gpkg_spatial_ref_sys table (sorted
+// by srs_id)
+func (w *Writer) spatialRefSys() [][]interface{} {
+	rows := map[int][]interface{}{
+		-1:   {"Undefined cartesian SRS", int64(-1), "NONE", int64(-1), "undefined", "undefined cartesian coordinate reference system"},
+		0:    {"Undefined geographic SRS", int64(0), "NONE", int64(0), "undefined", "undefined geographic coordinate reference system"},
+		4326: {"WGS 84 geodetic", int64(4326), "EPSG", int64(4326), wgs84, "longitude/latitude coordinates in decimal degrees on the WGS 84 spheroid"},
+	}
+	srid := w.header.SRID
+	if _, ok := rows[srid]; !ok {
+		definition := w.header.Definition
+		if definition == "" {
+			definition = "undefined"
+		}
+		rows[srid] = []interface{}{"EPSG:" + strconv.Itoa(srid), int64(srid), "EPSG", int64(srid), definition, nil}
+	}
+
+	ids := make([]int, 0, len(rows))
+	for id := range rows {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	sorted := make([][]interface{}, len(ids))
+	for i, id := range ids {
+		sorted[i] = rows[id]
+	}
+	return sorted
+}
+
+// schemaEntry is a row in the sqlite_master table
+type schemaEntry struct {
+	kind  string
+	name  string
+	table string
+	root  uint32
+	sql   interface{}
+}
+
+// writeTable writes a table with rows that have the rowid in a column (as
+// for an INTEGER PRIMARY KEY) or that are numbered from 1 (if column is -1)
+func (w *Writer) writeTable(rows [][]interface{}, column int) (uint32, error) {
+	tree := newTableTree(w.db, 0)
+	for i, row := range rows {
+		rowid := int64(i + 1)
+		if column >= 0 {
+			values := make([]interface{}, len(row))
+			copy(values, row)
+			rowid = values[column].(int64)
+			values[column] = nil
+			row = values
+		}
+		if err := tree.add(rowid, record(row...)); err != nil {
+			return 0, err
+		}
+	}
+	return tree.finish()
+}
+
+// writeTables writes everything but the features and returns the entries of
+// the schema table
+func (w *Writer) writeTables() ([]*schemaEntry, error) {
+	entries := []*schemaEntry{}
+
+	featuresRoot, err := w.features.finish()
+	if err != nil {
+		return nil, err
+	}
+
+	srsRoot, err := w.writeTable(w.spatialRefSys(), 1)
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, &schemaEntry{"table", "gpkg_spatial_ref_sys", "gpkg_spatial_ref_sys", srsRoot, createSpatialRefSys})
+
+	var minX, minY, maxX, maxY interface{}
+	if !w.extent.empty() {
+		minX, minY, maxX, maxY = w.extent.minX, w.extent.minY, w.extent.maxX, w.extent.maxY
+	}
+	title := w.header.Title
+	if title == "" {
+		title = w.header.Name
+	}
+	lastChange := time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+	contents := []interface{}{w.header.Name, "features", title, w.header.Description, lastChange, minX, minY, maxX, maxY, int64(w.srsID())}
+	contentsRoot, err := w.writeTable([][]interface{}{contents}, -1)
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, &schemaEntry{"table", "gpkg_contents", "gpkg_contents", contentsRoot, createContents})
+
+	// indexes for the primary key and unique constraints
+	contentsIndex1, err := w.db.writeIndex(record(w.header.Name, int64(1)))
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, &schemaEntry{"index", "sqlite_autoindex_gpkg_contents_1", "gpkg_contents", contentsIndex1, nil})
+	contentsIndex2, err := w.db.writeIndex(record(title, int64(1)))
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, &schemaEntry{"index", "sqlite_autoindex_gpkg_contents_2", "gpkg_contents", contentsIndex2, nil})
+
+	z := int64(0)
+	if w.header.HasZ {
+		z = 1
+	}
+	geometryColumns := []interface{}{w.header.Name, geometryColumn, GeometryTypeName(w.header.GeometryType), int64(w.srsID()), z, int64(0)}
+	geometryColumnsRoot, err := w.writeTable([][]interface{}{geometryColumns}, -1)
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, &schemaEntry{"table", "gpkg_geometry_columns", "gpkg_geometry_columns", geometryColumnsRoot, createGeometryColumns})
+
+	geometryColumnsIndex1, err := w.db.writeIndex(record(w.header.Name, geometryColumn, int64(1)))
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, &schemaEntry{"index", "sqlite_autoindex_gpkg_geometry_columns_1", "gpkg_geometry_columns", geometryColumnsIndex1, nil})
+	geometryColumnsIndex2, err := w.db.writeIndex(record(w.header.Name, int64(1)))
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, &schemaEntry{"index", "sqlite_autoindex_gpkg_geometry_columns_2", "gpkg_geometry_columns", geometryColumnsIndex2, nil})
+
+	entries = append(entries, &schemaEntry{"table", w.header.Name, w.header.Name, featuresRoot, w.createFeatures()})
+	return entries, nil
+}
+
+// WriteTo writes the GeoPackage.  It can only be called once.
+func (w *Writer) WriteTo(out io.Writer) (int64, error) {
+	entries, err := w.writeTables()
+	if err != nil {
+		return 0, err
+	}
+
+	schema := newTableTree(w.db, 1)
+	for i, entry := range entries {
+		if err := schema.add(int64(i+1), record(entry.kind, entry.name, entry.table, int64(entry.root), entry.sql)); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := schema.finish(); err != nil {
+		return 0, err
+	}
+
+	size, err := w.db.finish(userVersion, applicationID)
+	if err != nil {
+		return 0, err
+	}
+	return io.Copy(out, io.NewSectionReader(w.db.file, 0, size))
+}
+
+// Close removes the temporary file used by the writer
+func (w *Writer) Close() error {
+	return w.db.close()
+}
@@ -0,0 +1,144 @@
+package gpkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	geojson "github.com/paulmach/go.geojson"
+	"github.com/stretchr/testify/assert"
+)
+
+func mustGeometry(t *testing.T, data string) *geojson.Geometry {
+	geometry := &geojson.Geometry{}
+	if err := json.Unmarshal([]byte(data), geometry); err != nil {
+		t.Fatal(err)
+	}
+	return geometry
+}
+
+func TestEncodeGeometry(t *testing.T) {
+	assert := assert.New(t)
+
+	// points have no envelope
+	data, bounds := encodeGeometry(mustGeometry(t, `{"type":"Point","coordinates":[1,2]}`), 4326, false, false)
+	assert.Equal([]byte{'G', 'P', 0, flagLittleEndian, 0xe6, 0x10, 0, 0, 1, 1, 0, 0, 0}, data[:13])
+	assert.Equal(1.0, math.Float64frombits(binary.LittleEndian.Uint64(data[13:])))
+	assert.Equal(2.0, math.Float64frombits(binary.LittleEndian.Uint64(data[21:])))
+	assert.Len(data, 29)
+	assert.Equal(envelope{1, 1, 2, 2}, bounds)
+
+	// positions in latitude, longitude order are swapped
+	data, bounds = encodeGeometry(mustGeometry(t, `{"type":"LineString","coordinates":[[10,1,5],[20,3,6]]}`), 4326, true, true)
+	assert.Equal(byte(flagLittleEndian|flagEnvelopeXY), data[3])
+	assert.Equal(envelope{1, 3, 10, 20}, bounds)
+	envelopeValues := make([]float64, 4)
+	for i := range envelopeValues {
+		envelopeValues[i] = math.Float64frombits(binary.LittleEndian.Uint64(data[8+8*i:]))
+	}
+	assert.Equal([]float64{1, 3, 10, 20}, envelopeValues)
+	wkb := data[40:]
+	assert.Equal(uint32(1002), binary.LittleEndian.Uint32(wkb[1:]))
+	assert.Equal(uint32(2), binary.LittleEndian.Uint32(wkb[5:]))
+	assert.Equal(1.0, math.Float64frombits(binary.LittleEndian.Uint64(wkb[9:])))
+	assert.Equal(5.0, math.Float64frombits(binary.LittleEndian.Uint64(wkb[25:])))
+	assert.Len(wkb, 9+2*24)
+
+	// parts of multi-geometries have their own headers
+	data, _ = encodeGeometry(mustGeometry(t, `{"type":"MultiPoint","coordinates":[[1,2],[3,4]]}`), 0, false, false)
+	wkb = data[40:]
+	assert.Equal(uint32(4), binary.LittleEndian.Uint32(wkb[1:]))
+	assert.Equal(uint32(2), binary.LittleEndian.Uint32(wkb[5:]))
+	assert.Equal(uint32(1), binary.LittleEndian.Uint32(wkb[10:]))
+	assert.Len(wkb, 9+2*21)
+
+	// empty geometries have the empty flag and no envelope
+	data, bounds = encodeGeometry(mustGeometry(t, `{"type":"GeometryCollection","geometries":[]}`), 0, false, false)
+	assert.Equal(byte(flagLittleEndian|flagEmpty), data[3])
+	assert.True(bounds.empty())
+	assert.Len(data, 8+9)
+}
+
+// readVarint returns a varint (of at most 8 bytes) and its length
+func readVarint(data []byte) (uint64, int) {
+	var value uint64
+	for i, b := range data[:8] {
+		value = value<<7 | uint64(b&0x7f)
+		if b < 0x80 {
+			return value, i + 1
+		}
+	}
+	return value, 8
+}
+
+// schema reads the cells of a schema table that fits on page 1
+func schema(t *testing.T, data []byte) []string {
+	page := data[headerSize:pageSize]
+	if page[0] != leafTable {
+		t.Fatalf("expected a leaf page, got %d", page[0])
+	}
+	cells := int(binary.BigEndian.Uint16(page[3:]))
+	entries := make([]string, cells)
+	for i := range entries {
+		offset := int(binary.BigEndian.Uint16(page[8+2*i:]))
+		size, n := readVarint(data[offset:])
+		_, m := readVarint(data[offset+n:])
+		entries[i] = string(data[offset+n+m : offset+n+m+int(size)])
+	}
+	return entries
+}
+
+func TestWriter(t *testing.T) {
+	assert := assert.New(t)
+
+	writer, err := NewWriter(&Header{
+		Name:         "places",
+		Title:        "Places",
+		GeometryType: "Point",
+		Columns:      []*Column{{Name: "name", Type: Text}, {Name: "Name", Type: Real}, {Name: "fid", Type: Boolean}, {Name: "ID", Type: JSON}},
+		SRID:         3857,
+		Definition:   `PROJCS["WGS 84 / Pseudo-Mercator"]`,
+	})
+	if !assert.Nil(err) {
+		return
+	}
+	defer writer.Close()
+
+	for i := 0; i < 500; i++ {
+		assert.Nil(writer.Add(strconv.Itoa(i), mustGeometry(t, `{"type":"Point","coordinates":[10,20]}`), map[string]interface{}{"name": strings.Repeat("a", 100), "Name": 1.0, "ID": []interface{}{"x"}}))
+	}
+	assert.Nil(writer.Add("last", nil, map[string]interface{}{"name": 1.0}))
+
+	output := &bytes.Buffer{}
+	written, writeErr := writer.WriteTo(output)
+	if !assert.Nil(writeErr) {
+		return
+	}
+	data := output.Bytes()
+	assert.Equal(int64(len(data)), written)
+	assert.Zero(len(data) % pageSize)
+
+	assert.Equal("SQLite format 3\x00", string(data[:16]))
+	assert.Equal(uint16(pageSize), binary.BigEndian.Uint16(data[16:]))
+	assert.Equal(uint32(len(data)/pageSize), binary.BigEndian.Uint32(data[28:]))
+	assert.Equal(uint32(userVersion), binary.BigEndian.Uint32(data[60:]))
+	assert.Equal("GPKG", string(data[68:72]))
+
+	entries := schema(t, data)
+	if assert.Len(entries, 8) {
+		assert.Contains(entries[0], "gpkg_spatial_ref_sys")
+		assert.Contains(entries[3], "sqlite_autoindex_gpkg_contents_2")
+		assert.Contains(entries[7], `CREATE TABLE "places" (fid INTEGER PRIMARY KEY NOT NULL, geom POINT, id TEXT, "name" TEXT, "Name_2" REAL, "fid_2" BOOLEAN, "ID_2" TEXT)`)
+	}
+}
+
+func TestWriterReservedName(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := NewWriter(&Header{Name: "gpkg_contents"})
+	assert.NotNil(err)
+}
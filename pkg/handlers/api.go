@@ -0,0 +1,480 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+	"github.com/tschaub/pgfs/pkg/models"
+)
+
+// OpenAPIDocument is an OpenAPI 3 description of the service
+type OpenAPIDocument struct {
+	OpenAPI string                                  `json:"openapi"`
+	Info    *OpenAPIInfo                            `json:"info"`
+	Servers []*OpenAPIServer                        `json:"servers"`
+	Paths   map[string]map[string]*OpenAPIOperation `json:"paths"`
+}
+
+// OpenAPIInfo provides metadata about the API
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+}
+
+// OpenAPIServer is the location of the API
+type OpenAPIServer struct {
+	URL string `json:"url"`
+}
+
+// OpenAPIOperation describes a single operation on a path
+type OpenAPIOperation struct {
+	Summary     string                      `json:"summary"`
+	OperationID string                      `json:"operationId"`
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+}
+
+// OpenAPIParameter describes a path or query parameter
+type OpenAPIParameter struct {
+	Name        string                 `json:"name"`
+	In          string                 `json:"in"`
+	Description string                 `json:"description,omitempty"`
+	Required    bool                   `json:"required,omitempty"`
+	Style       string                 `json:"style,omitempty"`
+	Explode     *bool                  `json:"explode,omitempty"`
+	Schema      map[string]interface{} `json:"schema"`
+}
+
+// OpenAPIRequestBody describes the body of a request
+type OpenAPIRequestBody struct {
+	Required bool                         `json:"required"`
+	Content  map[string]map[string]string `json:"content"`
+}
+
+// OpenAPIResponse describes a response
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]map[string]string `json:"content,omitempty"`
+}
+
+// routeDoc documents a route registered in New
+type routeDoc struct {
+	summary      string
+	query        []*OpenAPIParameter
+	requestTypes []string
+	status       string
+	contentTypes []string
+	// resource is the type of resource for responses with negotiated formats
+	resource string
+}
+
+var noExplode = false
+
+var (
+	countParam = &OpenAPIParameter{
+		Name:        "count",
+		In:          "query",
+		Description: "The maximum number of features to return",
+		Schema:      map[string]interface{}{"type": "integer", "minimum": 1},
+	}
+	limitParam = &OpenAPIParameter{
+		Name:        "limit",
+		In:          "query",
+		Description: "The maximum number of features to return (an alias for count)",
+		Schema:      map[string]interface{}{"type": "integer", "minimum": 1},
+	}
+	afterParam = &OpenAPIParameter{
+		Name:        "after",
+		In:          "query",
+		Description: "Return features after the one with this identifier",
+		Schema:      map[string]interface{}{"type": "string", "format": "uuid"},
+	}
+	beforeParam = &OpenAPIParameter{
+		Name:        "before",
+		In:          "query",
+		Description: "Return features before the one with this identifier",
+		Schema:      map[string]interface{}{"type": "string", "format": "uuid"},
+	}
+	formatParam = &OpenAPIParameter{
+		Name:        "f",
+		In:          "query",
+		Description: "The format of the response (overrides the Accept header)",
+		Schema:      map[string]interface{}{"type": "string"},
+	}
+	cursorParam = &OpenAPIParameter{
+		Name:        "cursor",
+		In:          "query",
+		Description: "Return features from a position given by a next or prev link",
+		Schema:      map[string]interface{}{"type": "string"},
+	}
+	bboxParam = &OpenAPIParameter{
+		Name:        "bbox",
+		In:          "query",
+		Description: "Only return features that intersect a bounding box (minx,miny,maxx,maxy)",
+		Style:       "form",
+		Explode:     &noExplode,
+		Schema: map[string]interface{}{
+			"type":     "array",
+			"minItems": 4,
+			"maxItems": 6,
+			"items":    map[string]interface{}{"type": "number"},
+		},
+	}
+	bboxCRSParam = &OpenAPIParameter{
+		Name:        "bbox-crs",
+		In:          "query",
+		Description: "The coordinate reference system of the bbox parameter",
+		Schema:      map[string]interface{}{"type": "string", "format": "uri"},
+	}
+	datetimeParam = &OpenAPIParameter{
+		Name:        "datetime",
+		In:          "query",
+		Description: "Only return features with a time that intersects an instant or interval",
+		Schema:      map[string]interface{}{"type": "string"},
+	}
+	filterParam = &OpenAPIParameter{
+		Name:        "filter",
+		In:          "query",
+		Description: "A CQL2 filter expression",
+		Schema:      map[string]interface{}{"type": "string"},
+	}
+	filterLangParam = &OpenAPIParameter{
+		Name:        "filter-lang",
+		In:          "query",
+		Description: "The encoding of the filter parameter",
+		Schema:      map[string]interface{}{"type": "string", "enum": []string{"cql2-text", "cql2-json"}, "default": "cql2-text"},
+	}
+	filterCRSParam = &OpenAPIParameter{
+		Name:        "filter-crs",
+		In:          "query",
+		Description: "The coordinate reference system of geometries in the filter parameter",
+		Schema:      map[string]interface{}{"type": "string", "format": "uri"},
+	}
+	crsParam = &OpenAPIParameter{
+		Name:        "crs",
+		In:          "query",
+		Description: "The coordinate reference system of geometries in the response",
+		Schema:      map[string]interface{}{"type": "string", "format": "uri"},
+	}
+	maxDecimalDigitsParam = &OpenAPIParameter{
+		Name:        "maxdecimaldigits",
+		In:          "query",
+		Description: "The maximum number of decimal digits in coordinates",
+		Schema:      map[string]interface{}{"type": "integer", "minimum": 0, "maximum": maxDecimalDigits},
+	}
+	simplifyParam = &OpenAPIParameter{
+		Name:        "simplify",
+		In:          "query",
+		Description: "Simplify geometries with a tolerance in units of the response CRS",
+		Schema:      map[string]interface{}{"type": "number", "minimum": 0},
+	}
+	zoomLevelParam = &OpenAPIParameter{
+		Name:        "zoom-level",
+		In:          "query",
+		Description: "Simplify geometries for display at a Web Mercator zoom level",
+		Schema:      map[string]interface{}{"type": "number", "minimum": 0, "maximum": maxZoomLevel},
+	}
+	propertiesParam = &OpenAPIParameter{
+		Name:        "properties",
+		In:          "query",
+		Description: "Only include these properties in features",
+		Style:       "form",
+		Explode:     &noExplode,
+		Schema:      map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+	}
+	skipGeometryParam = &OpenAPIParameter{
+		Name:        "skipGeometry",
+		In:          "query",
+		Description: "Leave out feature geometries",
+		Schema:      map[string]interface{}{"type": "boolean", "default": false},
+	}
+	sortByParam = &OpenAPIParameter{
+		Name:        "sortby",
+		In:          "query",
+		Description: "Order features by sortable properties (+name for ascending, -name for descending)",
+		Style:       "form",
+		Explode:     &noExplode,
+		Schema:      map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+	}
+	csvGeometryParam = &OpenAPIParameter{
+		Name:        "csv-geometry",
+		In:          "query",
+		Description: "Write geometries in CSV as WKT or as lon and lat columns (for points)",
+		Schema:      map[string]interface{}{"type": "string", "enum": []string{csvGeometryWKT, csvGeometryLonLat}, "default": csvGeometryWKT},
+	}
+	forceParam = &OpenAPIParameter{
+		Name:        "force",
+		In:          "query",
+		Description: "Delete a collection even if it has features",
+		Schema:      map[string]interface{}{"type": "boolean", "default": false},
+	}
+)
+
+var featureListQueryParams = []*OpenAPIParameter{
+	countParam,
+	limitParam,
+	afterParam,
+	beforeParam,
+	cursorParam,
+	bboxParam,
+	bboxCRSParam,
+	datetimeParam,
+	filterParam,
+	filterLangParam,
+	filterCRSParam,
+	crsParam,
+	maxDecimalDigitsParam,
+	simplifyParam,
+	zoomLevelParam,
+	propertiesParam,
+	skipGeometryParam,
+	sortByParam,
+	csvGeometryParam,
+}
+
+var routeDocs = map[string]*routeDoc{
+	"GET /": {
+		summary:  "Landing page",
+		resource: ResourceLanding,
+	},
+	"GET /conformance": {
+		summary:  "Conformance classes implemented by the service",
+		resource: ResourceConformance,
+	},
+	"GET /api": {
+		summary:  "This API definition",
+		resource: ResourceAPI,
+	},
+	"GET /collections": {
+		summary:  "List collections",
+		resource: ResourceCollections,
+	},
+	"POST /collections": {
+		summary:      "Create a collection",
+		requestTypes: []string{echo.MIMEApplicationJSON},
+		status:       "201",
+		contentTypes: []string{echo.MIMEApplicationJSON},
+	},
+	"GET /collections/:name": {
+		summary:  "Get a collection",
+		resource: ResourceCollection,
+	},
+	"PUT /collections/:name": {
+		summary:      "Update a collection",
+		requestTypes: []string{echo.MIMEApplicationJSON},
+		contentTypes: []string{echo.MIMEApplicationJSON},
+	},
+	"DELETE /collections/:name": {
+		summary: "Delete a collection",
+		query:   []*OpenAPIParameter{forceParam},
+		status:  "204",
+	},
+	"GET /collections/:name/queryables": {
+		summary:  "Properties that can be used to filter features",
+		resource: ResourceQueryables,
+	},
+	"GET /collections/:name/sortables": {
+		summary:  "Properties that can be used to order features",
+		resource: ResourceSortables,
+	},
+	"GET /collections/:collectionName/items": {
+		summary:  "List features in a collection",
+		query:    featureListQueryParams,
+		resource: ResourceItems,
+	},
+	"POST /collections/:collectionName/items": {
+		summary:      "Add features to a collection",
+		requestTypes: []string{echo.MIMEApplicationJSON, mimeGeoJSONSeq, mimeNDJSON},
+		contentTypes: []string{echo.MIMEApplicationJSON},
+	},
+	"GET /collections/:collectionName/items/:featureId": {
+		summary:  "Get a feature",
+		query:    []*OpenAPIParameter{crsParam, propertiesParam, skipGeometryParam},
+		resource: ResourceItem,
+	},
+	"PUT /collections/:collectionName/items/:featureId": {
+		summary:      "Replace a feature",
+		requestTypes: []string{echo.MIMEApplicationJSON},
+		contentTypes: []string{echo.MIMEApplicationJSON},
+	},
+	"PATCH /collections/:collectionName/items/:featureId": {
+		summary:      "Update the properties or geometry of a feature",
+		requestTypes: []string{mimeMergePatch},
+		contentTypes: []string{echo.MIMEApplicationJSON},
+	},
+	"DELETE /collections/:collectionName/items/:featureId": {
+		summary: "Delete a feature",
+		status:  "204",
+	},
+	"GET /collections/:collectionName/tiles/:tileMatrixSetId/:z/:x/:y": {
+		summary:      "Get a vector tile of features in a collection",
+		contentTypes: []string{mimeMVT},
+	},
+	"GET /tiles": {
+		summary:      "Get the layers of the dataset vector tiles",
+		contentTypes: []string{echo.MIMEApplicationJSON},
+	},
+	"PUT /tiles": {
+		summary:      "Replace the layers of the dataset vector tiles",
+		requestTypes: []string{echo.MIMEApplicationJSON},
+		contentTypes: []string{echo.MIMEApplicationJSON},
+	},
+	"GET /tiles/:tileMatrixSetId": {
+		summary:      "TileJSON describing the dataset vector tiles",
+		contentTypes: []string{echo.MIMEApplicationJSON},
+	},
+	"GET /tiles/:tileMatrixSetId/:z/:x/:y": {
+		summary:      "Get a vector tile with a layer for each collection in the tileset",
+		contentTypes: []string{mimeMVT},
+	},
+}
+
+// operationID derives an identifier from a handler name like
+// github.com/tschaub/pgfs/pkg/handlers.ListFeatures.func1
+func operationID(handlerName string) string {
+	name := handlerName[strings.LastIndex(handlerName, "/")+1:]
+	parts := strings.Split(name, ".")
+	if len(parts) > 1 {
+		return parts[1]
+	}
+	return name
+}
+
+// openAPIPath converts an echo path (/items/:id) to an OpenAPI path (/items/{id})
+func openAPIPath(path string) (string, []*OpenAPIParameter) {
+	params := []*OpenAPIParameter{}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			name := segment[1:]
+			segments[i] = "{" + name + "}"
+			params = append(params, &OpenAPIParameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   map[string]interface{}{"type": "string"},
+			})
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// mediaTypes returns the content map for a list of media types
+func mediaTypes(types []string) map[string]map[string]string {
+	content := map[string]map[string]string{}
+	for _, t := range types {
+		content[t] = map[string]string{}
+	}
+	return content
+}
+
+func newOperation(route *echo.Route, params []*OpenAPIParameter, formats Formats) *OpenAPIOperation {
+	id := operationID(route.Name)
+	doc, ok := routeDocs[route.Method+" "+route.Path]
+	if !ok {
+		doc = &routeDoc{summary: id}
+	}
+
+	status := doc.status
+	if status == "" {
+		status = "200"
+	}
+
+	contentTypes := append([]string{}, doc.contentTypes...)
+	for _, format := range formats[doc.resource] {
+		contentTypes = append(contentTypes, format.MediaType)
+	}
+
+	success := &OpenAPIResponse{Description: doc.summary}
+	if len(contentTypes) > 0 {
+		success.Content = mediaTypes(contentTypes)
+	}
+
+	params = append(params, doc.query...)
+	if route.Method == echo.GET && doc.resource != "" {
+		params = append(params, formatParam)
+	}
+
+	operation := &OpenAPIOperation{
+		Summary:     doc.summary,
+		OperationID: id,
+		Parameters:  params,
+		Responses: map[string]*OpenAPIResponse{
+			status:    success,
+			"default": {Description: "An error"},
+		},
+	}
+
+	if len(doc.requestTypes) > 0 {
+		operation.RequestBody = &OpenAPIRequestBody{
+			Required: true,
+			Content:  mediaTypes(doc.requestTypes),
+		}
+	}
+
+	return operation
+}
+
+// GetAPI responds with an OpenAPI document describing the registered routes.
+// The items path for each collection is described with its queryable properties.
+func GetAPI(db *sql.DB, formats Formats) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		format, formatErr := formats.negotiate(c, ResourceAPI)
+		if formatErr != nil {
+			return formatErr
+		}
+
+		document := &OpenAPIDocument{
+			OpenAPI: "3.0.2",
+			Info: &OpenAPIInfo{
+				Title:       "pgfs",
+				Description: "Postgres backed WFS 3",
+				Version:     "1.0.0",
+			},
+			Servers: []*OpenAPIServer{{URL: baseURL(c)}},
+			Paths:   map[string]map[string]*OpenAPIOperation{},
+		}
+
+		var listFeatures *echo.Route
+		for _, route := range c.Echo().Routes() {
+			path, params := openAPIPath(route.Path)
+			if document.Paths[path] == nil {
+				document.Paths[path] = map[string]*OpenAPIOperation{}
+			}
+			document.Paths[path][strings.ToLower(route.Method)] = newOperation(route, params, formats)
+			if route.Method == echo.GET && route.Path == "/collections/:collectionName/items" {
+				listFeatures = route
+			}
+		}
+
+		collections := models.Collections{}
+		if listFeatures != nil {
+			if _, listErr := models.Query(db, &collections, nil); listErr != nil {
+				return listErr
+			}
+		}
+
+		for _, collection := range collections {
+			operation := newOperation(listFeatures, nil, formats)
+			operation.OperationID = fmt.Sprintf("%s.%s", operation.OperationID, collection.Name)
+			operation.Summary = fmt.Sprintf("List features in %s", collection.Title)
+			for _, queryable := range collection.Queryables {
+				operation.Parameters = append(operation.Parameters, &OpenAPIParameter{
+					Name:        queryable,
+					In:          "query",
+					Description: fmt.Sprintf("Only return features where %s is equal to this value", queryable),
+					Schema:      map[string]interface{}{"type": "string"},
+				})
+			}
+			path := fmt.Sprintf("/collections/%s/items", collection.Name)
+			document.Paths[path] = map[string]*OpenAPIOperation{"get": operation}
+		}
+
+		return format.Encode(c, format, http.StatusOK, document)
+	}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPIPath(t *testing.T) {
+	assert := assert.New(t)
+
+	path, params := openAPIPath("/collections/:collectionName/items/:featureId")
+	assert.Equal("/collections/{collectionName}/items/{featureId}", path)
+	if assert.Len(params, 2) {
+		assert.Equal("collectionName", params[0].Name)
+		assert.Equal("path", params[0].In)
+		assert.True(params[0].Required)
+		assert.Equal("featureId", params[1].Name)
+	}
+
+	path, params = openAPIPath("/conformance")
+	assert.Equal("/conformance", path)
+	assert.Len(params, 0)
+}
+
+func TestOperationID(t *testing.T) {
+	assert.Equal(t, "ListFeatures", operationID("github.com/tschaub/pgfs/pkg/handlers.ListFeatures.func1"))
+}
+
+func TestGetAPI(t *testing.T) {
+	assert := assert.New(t)
+
+	router := echo.New()
+	router.GET("/", GetLandingPage(DefaultFormats()))
+	router.GET("/conformance", GetConformance(DefaultFormats()))
+	router.GET("/api", GetAPI(nil, DefaultFormats()))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(echo.GET, "/api", nil))
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.Equal(mimeOpenAPI, rec.Header().Get(echo.HeaderContentType))
+
+	document := &OpenAPIDocument{}
+	assert.Nil(json.Unmarshal(rec.Body.Bytes(), document))
+	assert.Len(document.Paths, 3)
+	if assert.NotNil(document.Paths["/conformance"]["get"]) {
+		assert.Equal("GetConformance", document.Paths["/conformance"]["get"].OperationID)
+	}
+}
+
+func TestGetConformanceFormats(t *testing.T) {
+	assert := assert.New(t)
+
+	router := echo.New()
+	router.GET("/conformance", GetConformance(DefaultFormats()))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(echo.GET, "/conformance", nil)
+	req.Header.Set(echo.HeaderAccept, "text/html")
+	router.ServeHTTP(rec, req)
+	assert.Equal(http.StatusNotAcceptable, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(echo.GET, "/conformance?f=json", nil)
+	req.Header.Set(echo.HeaderAccept, "text/html")
+	router.ServeHTTP(rec, req)
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.Equal(echo.MIMEApplicationJSON, rec.Header().Get(echo.HeaderContentType))
+}
+
+func TestConformance(t *testing.T) {
+	assert := assert.New(t)
+
+	classes := conformance(DefaultFormats())
+	assert.Equal(conformanceClasses, classes[:len(conformanceClasses)])
+	assert.Contains(classes, conformanceGeoJSON)
+
+	// representation classes are only declared for registered formats
+	formats := Formats{}
+	formats.Register(ResourceItems, &Format{Name: "csv", MediaType: mimeCSV, Encode: encodeCSV, Export: true})
+	assert.Equal(conformanceClasses, conformance(formats))
+}
@@ -4,26 +4,184 @@ import (
 	"database/sql"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/labstack/echo"
 	"github.com/lib/pq"
+	"github.com/tschaub/pgfs/pkg/cql"
+	"github.com/tschaub/pgfs/pkg/geo"
 	"github.com/tschaub/pgfs/pkg/models"
 )
 
-// CollectionInfo encodes collection information
+// CollectionInfo encodes collection information.  The ID, links, extent,
+// and item type are only included in responses.  The storage CRS, geometry
+// type, and dimension can only be set when a collection is created.
+// Responses list all supported CRSs, including CRS84 and the storage CRS.
 type CollectionInfo struct {
-	Name        string `json:"name" validate:"required"`
-	Title       string `json:"title" validate:"required"`
-	Description string `json:"description" validate:"required"`
+	ID              string      `json:"id,omitempty"`
+	Name            string      `json:"name" validate:"required"`
+	Title           string      `json:"title" validate:"required"`
+	Description     string      `json:"description" validate:"required"`
+	TimeProperty    string      `json:"timeProperty,omitempty"`
+	TimeEndProperty string      `json:"timeEndProperty,omitempty"`
+	Queryables      []string    `json:"queryables,omitempty" validate:"unique"`
+	Links           []*Link     `json:"links,omitempty"`
+	Extent          *ExtentInfo `json:"extent,omitempty"`
+	ItemType        string      `json:"itemType,omitempty"`
+	CRS             []string    `json:"crs,omitempty"`
+	StorageCRS      string      `json:"storageCrs,omitempty"`
+	GeometryType    string      `json:"geometryType,omitempty" validate:"omitempty,oneof=Point LineString Polygon MultiPoint MultiLineString MultiPolygon GeometryCollection"`
+	Dimension       int         `json:"dimension,omitempty" validate:"omitempty,oneof=2 3"`
+	Validation      string      `json:"validation,omitempty" validate:"omitempty,oneof=reject warn repair"`
+	Sortables       []string    `json:"sortables,omitempty" validate:"unique"`
+	TileProperties  []string    `json:"tileProperties,omitempty" validate:"unique"`
+}
+
+// ExtentInfo is the spatial and temporal extent of the features in a collection
+type ExtentInfo struct {
+	Spatial  *SpatialExtentInfo  `json:"spatial,omitempty"`
+	Temporal *TemporalExtentInfo `json:"temporal,omitempty"`
+}
+
+// SpatialExtentInfo is the bounding box of the features in a collection
+type SpatialExtentInfo struct {
+	BBox [][]float64 `json:"bbox"`
+	CRS  string      `json:"crs"`
+}
+
+// TemporalExtentInfo is the time interval of the features in a collection.
+// Open ends are null.
+type TemporalExtentInfo struct {
+	Interval [][]*time.Time `json:"interval"`
+	TRS      string         `json:"trs"`
+}
+
+const (
+	itemTypeFeature = "feature"
+	trsGregorian    = "http://www.opengis.net/def/uom/ISO-8601/0/Gregorian"
+	relQueryables   = "http://www.opengis.net/def/rel/ogc/1.0/queryables"
+	relSortables    = "http://www.opengis.net/def/rel/ogc/1.0/sortables"
+)
+
+// CollectionDeleteQuery holds options for deleting a collection
+type CollectionDeleteQuery struct {
+	Force bool `query:"force"`
+}
+
+// QueryablesInfo is a JSON Schema describing the queryable properties of a collection
+type QueryablesInfo struct {
+	Schema     string                            `json:"$schema"`
+	ID         string                            `json:"$id"`
+	Type       string                            `json:"type"`
+	Title      string                            `json:"title"`
+	Properties map[string]map[string]interface{} `json:"properties"`
 }
 
 // CollectionList encodes a list of collections
 type CollectionList struct {
+	Links       []*Link           `json:"links"`
 	Collections []*CollectionInfo `json:"collections"`
 }
 
+// extentFromCollection returns the cached extent of a collection or nil if
+// the collection has no features
+func extentFromCollection(c *models.Collection) *ExtentInfo {
+	extent := &ExtentInfo{}
+	e := c.Extent
+	if e.MinX != nil && e.MinY != nil && e.MaxX != nil && e.MaxY != nil {
+		extent.Spatial = &SpatialExtentInfo{
+			BBox: [][]float64{{*e.MinX, *e.MinY, *e.MaxX, *e.MaxY}},
+			CRS:  geo.CRS84URI,
+		}
+	}
+	if c.TimeProperty != "" && (e.Start != nil || e.End != nil) {
+		extent.Temporal = &TemporalExtentInfo{
+			Interval: [][]*time.Time{{e.Start, e.End}},
+			TRS:      trsGregorian,
+		}
+	}
+	if extent.Spatial == nil && extent.Temporal == nil {
+		return nil
+	}
+	return extent
+}
+
+// infoFromCollection returns the info for a collection with links to the
+// collection in the named format and to its resources in their default formats
+func infoFromCollection(c *models.Collection, base string, formats Formats, name string) *CollectionInfo {
+	href := fmt.Sprintf("%s/collections/%s", base, c.Name)
+	return &CollectionInfo{
+		ID:              c.Name,
+		Name:            c.Name,
+		Title:           c.Title,
+		Description:     c.Description,
+		TimeProperty:    c.TimeProperty,
+		TimeEndProperty: c.TimeEndProperty,
+		Queryables:      c.Queryables,
+		Links: append(
+			formats.selfLinks(href, ResourceCollection, name, "This collection"),
+			formats.link(href+"/items", "items", ResourceItems, "Features in this collection"),
+			formats.link(href+"/queryables", relQueryables, ResourceQueryables, "Queryable properties"),
+			formats.link(href+"/sortables", relSortables, ResourceSortables, "Sortable properties"),
+		),
+		Extent:         extentFromCollection(c),
+		ItemType:       itemTypeFeature,
+		CRS:            supportedCRS(c),
+		StorageCRS:     c.StorageCRS().URI(),
+		GeometryType:   c.GeometryType,
+		Dimension:      c.Dimension,
+		Validation:     c.Validation,
+		Sortables:      c.Sortables,
+		TileProperties: c.TileProperties,
+	}
+}
+
+// collectionFromInfo validates the editable fields of a collection
+func collectionFromInfo(info *CollectionInfo) (*models.Collection, error) {
+	if info.TimeEndProperty != "" && info.TimeProperty == "" {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "'timeEndProperty' requires 'timeProperty'")
+	}
+
+	storage := geo.CRS84
+	if info.StorageCRS != "" {
+		var err error
+		storage, err = geo.ParseCRS(info.StorageCRS)
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bad 'storageCrs': %s", err))
+		}
+	}
+
+	var uris []string
+	for _, value := range info.CRS {
+		crs, err := geo.ParseCRS(value)
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bad 'crs': %s", err))
+		}
+		if crs.SRID == geo.CRS84.SRID || crs.SRID == storage.SRID {
+			continue
+		}
+		uris = append(uris, crs.URI())
+	}
+
+	return &models.Collection{
+		Name:            info.Name,
+		Title:           info.Title,
+		Description:     info.Description,
+		TimeProperty:    info.TimeProperty,
+		TimeEndProperty: info.TimeEndProperty,
+		Queryables:      info.Queryables,
+		SRID:            storage.SRID,
+		CRS:             uris,
+		GeometryType:    info.GeometryType,
+		Dimension:       info.Dimension,
+		Validation:      info.Validation,
+		Sortables:       info.Sortables,
+		TileProperties:  info.TileProperties,
+	}, nil
+}
+
 // CreateCollection saves a new collection
-func CreateCollection(db *sql.DB) echo.HandlerFunc {
+func CreateCollection(db *sql.DB, formats Formats) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		info := &CollectionInfo{}
 		if bindErr := c.Bind(info); bindErr != nil {
@@ -34,10 +192,9 @@ func CreateCollection(db *sql.DB) echo.HandlerFunc {
 			return validateErr
 		}
 
-		collection := &models.Collection{
-			Name:        info.Name,
-			Title:       info.Title,
-			Description: info.Description,
+		collection, infoErr := collectionFromInfo(info)
+		if infoErr != nil {
+			return infoErr
 		}
 
 		createErr := models.Insert(db, collection)
@@ -50,13 +207,18 @@ func CreateCollection(db *sql.DB) echo.HandlerFunc {
 			return createErr
 		}
 
-		return c.JSON(http.StatusCreated, info)
+		return c.JSON(http.StatusCreated, infoFromCollection(collection, baseURL(c), formats, "json"))
 	}
 }
 
 // GetCollection responds with a single collection by name
-func GetCollection(db *sql.DB) echo.HandlerFunc {
+func GetCollection(db *sql.DB, formats Formats) echo.HandlerFunc {
 	return func(c echo.Context) error {
+		format, formatErr := formats.negotiate(c, ResourceCollection)
+		if formatErr != nil {
+			return formatErr
+		}
+
 		name := c.Param("name")
 
 		collection := &models.Collection{Name: name}
@@ -68,34 +230,189 @@ func GetCollection(db *sql.DB) echo.HandlerFunc {
 			return getErr
 		}
 
-		info := &CollectionInfo{
-			Name:        collection.Name,
-			Title:       collection.Title,
-			Description: collection.Description,
-		}
-
-		return c.JSON(http.StatusOK, info)
+		return format.Encode(c, format, http.StatusOK, infoFromCollection(collection, baseURL(c), formats, format.Name))
 	}
 }
 
 // ListCollections responds with a list of all the collections
-func ListCollections(db *sql.DB) echo.HandlerFunc {
+func ListCollections(db *sql.DB, formats Formats) echo.HandlerFunc {
 	return func(c echo.Context) error {
+		format, formatErr := formats.negotiate(c, ResourceCollections)
+		if formatErr != nil {
+			return formatErr
+		}
+
 		collections := models.Collections{}
 		_, listErr := models.Query(db, &collections, nil)
 		if listErr != nil {
 			return listErr
 		}
 
+		base := baseURL(c)
 		list := make([]*CollectionInfo, len(collections))
 		for i, collection := range collections {
-			list[i] = &CollectionInfo{
-				Name:        collection.Name,
-				Title:       collection.Title,
-				Description: collection.Description,
+			list[i] = infoFromCollection(collection, base, formats, format.Name)
+		}
+
+		links := formats.selfLinks(base+"/collections", ResourceCollections, format.Name, "Feature collections")
+
+		return format.Encode(c, format, http.StatusOK, &CollectionList{Links: links, Collections: list})
+	}
+}
+
+// UpdateCollection replaces the editable fields of a collection
+func UpdateCollection(db *sql.DB, formats Formats) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		name := c.Param("name")
+
+		info := &CollectionInfo{}
+		if bindErr := c.Bind(info); bindErr != nil {
+			return bindErr
+		}
+
+		if info.Name == "" {
+			info.Name = name
+		}
+		if info.Name != name {
+			return echo.NewHTTPError(http.StatusBadRequest, "collections cannot be renamed")
+		}
+
+		if validateErr := c.Validate(info); validateErr != nil {
+			return validateErr
+		}
+
+		collection, infoErr := collectionFromInfo(info)
+		if infoErr != nil {
+			return infoErr
+		}
+
+		existing := &models.Collection{Name: name}
+		if getErr := models.Get(db, existing); getErr != nil {
+			if getErr == sql.ErrNoRows {
+				return echo.NewHTTPError(http.StatusNotFound)
 			}
+			return getErr
+		}
+
+		if info.StorageCRS == "" {
+			collection.SRID = existing.SRID
+		}
+		if collection.StorageCRS() != existing.StorageCRS() {
+			return echo.NewHTTPError(http.StatusBadRequest, "the storage CRS of a collection cannot be changed")
+		}
+
+		if info.GeometryType == "" {
+			collection.GeometryType = existing.GeometryType
+		}
+		if info.Dimension == 0 {
+			collection.Dimension = existing.Dimension
+		}
+		if collection.GeometryType != existing.GeometryType || collection.Dimension != existing.Dimension {
+			return echo.NewHTTPError(http.StatusBadRequest, "the geometry type and dimension of a collection cannot be changed")
+		}
+
+		updateErr := models.Update(db, collection)
+		if updateErr != nil {
+			if updateErr == sql.ErrNoRows {
+				return echo.NewHTTPError(http.StatusNotFound)
+			}
+			return updateErr
+		}
+
+		if getErr := models.Get(db, collection); getErr != nil {
+			return getErr
+		}
+
+		return c.JSON(http.StatusOK, infoFromCollection(collection, baseURL(c), formats, "json"))
+	}
+}
+
+// DeleteCollection removes a collection.  Collections with features are only
+// deleted (along with their features) if the force parameter is true.
+func DeleteCollection(db *sql.DB) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		query := &CollectionDeleteQuery{}
+		if bindErr := c.Bind(query); bindErr != nil {
+			return bindErr
+		}
+
+		name := c.Param("name")
+		collection := &models.Collection{Name: name}
+		deleteErr := models.DeleteCollection(db, collection, query.Force)
+		if deleteErr != nil {
+			if deleteErr == sql.ErrNoRows {
+				return echo.NewHTTPError(http.StatusNotFound)
+			}
+			if deleteErr == models.ErrCollectionNotEmpty {
+				return echo.NewHTTPError(http.StatusConflict, fmt.Sprintf("Collection '%s' has features, use force=true to delete them", name))
+			}
+			return deleteErr
 		}
 
-		return c.JSON(http.StatusOK, &CollectionList{Collections: list})
+		return c.NoContent(http.StatusNoContent)
+	}
+}
+
+// GetQueryables responds with the properties that can be used to filter features
+func GetQueryables(db *sql.DB, formats Formats) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		format, formatErr := formats.negotiate(c, ResourceQueryables)
+		if formatErr != nil {
+			return formatErr
+		}
+
+		name := c.Param("name")
+
+		collection := &models.Collection{Name: name}
+		getErr := models.Get(db, collection)
+		if getErr != nil {
+			if getErr == sql.ErrNoRows {
+				return echo.NewHTTPError(http.StatusNotFound)
+			}
+			return getErr
+		}
+
+		info := propertiesSchema(c, collection, "queryables")
+		info.Properties[cql.GeometryProperty] = map[string]interface{}{"$ref": "https://geojson.org/schema/Geometry.json"}
+		for _, queryable := range collection.Queryables {
+			info.Properties[queryable] = map[string]interface{}{"title": queryable}
+		}
+
+		return format.Encode(c, format, http.StatusOK, info)
+	}
+}
+
+// GetSortables responds with the properties that can be used to order features
+func GetSortables(db *sql.DB, formats Formats) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		format, formatErr := formats.negotiate(c, ResourceSortables)
+		if formatErr != nil {
+			return formatErr
+		}
+
+		collection, getErr := getCollection(db, c.Param("name"))
+		if getErr != nil {
+			return getErr
+		}
+
+		info := propertiesSchema(c, collection, "sortables")
+		info.Properties[models.IDProperty] = map[string]interface{}{"title": models.IDProperty}
+		for _, sortable := range collection.Sortables {
+			info.Properties[sortable] = map[string]interface{}{"title": sortable}
+		}
+
+		return format.Encode(c, format, http.StatusOK, info)
+	}
+}
+
+// propertiesSchema returns an empty JSON Schema for a collection resource
+// that describes properties (like queryables)
+func propertiesSchema(c echo.Context, collection *models.Collection, resource string) *QueryablesInfo {
+	return &QueryablesInfo{
+		Schema:     "https://json-schema.org/draft/2019-09/schema",
+		ID:         fmt.Sprintf("%s/collections/%s/%s", baseURL(c), collection.Name, resource),
+		Type:       "object",
+		Title:      collection.Title,
+		Properties: map[string]map[string]interface{}{},
 	}
 }
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tschaub/pgfs/pkg/models"
+)
+
+func TestDeleteCollection(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	router := testRouter(t, db)
+
+	res := serve(router, http.MethodDelete, "/collections/missing", "")
+	assert.Equal(http.StatusNotFound, res.Code)
+
+	res = serve(router, http.MethodPost, "/collections", `{"name":"places","title":"places","description":"places"}`)
+	if !assert.Equal(http.StatusCreated, res.Code, res.Body.String()) {
+		return
+	}
+	res = serve(router, http.MethodPost, "/collections/places/items", `{"type":"FeatureCollection","features":[{"type":"Feature","geometry":{"type":"Point","coordinates":[1,2]},"properties":{}}]}`)
+	if !assert.Equal(http.StatusOK, res.Code, res.Body.String()) {
+		return
+	}
+
+	res = serve(router, http.MethodDelete, "/collections/places", "")
+	assert.Equal(http.StatusConflict, res.Code)
+
+	res = serve(router, http.MethodDelete, "/collections/places?force=true", "")
+	assert.Equal(http.StatusNoContent, res.Code)
+	assert.Equal(sql.ErrNoRows, models.Get(db, &models.Collection{Name: "places"}))
+}
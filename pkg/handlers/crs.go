@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+	"github.com/tschaub/pgfs/pkg/geo"
+	"github.com/tschaub/pgfs/pkg/models"
+)
+
+// headerContentCRS identifies the CRS of geometries in a request or response body
+const headerContentCRS = "Content-Crs"
+
+// collectionCRS parses a CRS URI and makes sure it is supported by a collection.
+// The name is used in error messages.
+func collectionCRS(value string, name string, collection *models.Collection) (geo.CRS, error) {
+	if value == "" {
+		return geo.CRS84, nil
+	}
+
+	crs, err := geo.ParseCRS(value)
+	if err != nil {
+		return crs, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bad '%s': %s", name, err))
+	}
+
+	if !collection.SupportsCRS(crs) {
+		return crs, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("'%s' %s is not supported by collection '%s'", name, value, collection.Name))
+	}
+
+	return crs, nil
+}
+
+// requestCRS returns the CRS of geometries in the request body, given by the
+// Content-Crs header (e.g. <http://www.opengis.net/def/crs/EPSG/0/3857>)
+func requestCRS(c echo.Context, collection *models.Collection) (geo.CRS, error) {
+	value := strings.TrimSpace(c.Request().Header.Get(headerContentCRS))
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "<"), ">")
+	return collectionCRS(value, headerContentCRS, collection)
+}
+
+// setResponseCRS sets the Content-Crs header for geometries in the response body
+func setResponseCRS(c echo.Context, crs geo.CRS) {
+	c.Response().Header().Set(headerContentCRS, "<"+crs.URI()+">")
+}
+
+// supportedCRS lists the URIs of all the coordinate reference systems
+// supported by a collection
+func supportedCRS(collection *models.Collection) []string {
+	uris := []string{geo.CRS84URI}
+	seen := map[string]bool{geo.CRS84URI: true}
+	add := func(uri string) {
+		if !seen[uri] {
+			seen[uri] = true
+			uris = append(uris, uri)
+		}
+	}
+
+	add(collection.StorageCRS().URI())
+	for _, uri := range collection.CRS {
+		add(uri)
+	}
+
+	return uris
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/tschaub/pgfs/pkg/geo"
+	"github.com/tschaub/pgfs/pkg/models"
+)
+
+func TestCollectionCRS(t *testing.T) {
+	assert := assert.New(t)
+
+	collection := &models.Collection{
+		Name: "parcels",
+		SRID: 2056,
+		CRS:  []string{"http://www.opengis.net/def/crs/EPSG/0/3857"},
+	}
+
+	cases := []struct {
+		value string
+		crs   geo.CRS
+		err   bool
+	}{
+		{value: "", crs: geo.CRS84},
+		{value: geo.CRS84URI, crs: geo.CRS84},
+		{value: "http://www.opengis.net/def/crs/EPSG/0/4326", crs: geo.CRS{SRID: 4326, LatLon: true}},
+		{value: "http://www.opengis.net/def/crs/EPSG/0/2056", crs: geo.CRS{SRID: 2056}},
+		{value: "http://www.opengis.net/def/crs/EPSG/0/3857", crs: geo.CRS{SRID: 3857}},
+		{value: "http://www.opengis.net/def/crs/EPSG/0/32632", err: true},
+		{value: "bogus", err: true},
+	}
+
+	for _, c := range cases {
+		crs, err := collectionCRS(c.value, "crs", collection)
+		if c.err {
+			assert.NotNil(err, c.value)
+			continue
+		}
+		if assert.Nil(err, c.value) {
+			assert.Equal(c.crs, crs, c.value)
+		}
+	}
+}
+
+func TestRequestCRS(t *testing.T) {
+	assert := assert.New(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/collections/parcels/items", nil)
+	req.Header.Set(headerContentCRS, "<http://www.opengis.net/def/crs/EPSG/0/2056>")
+	c := echo.New().NewContext(req, httptest.NewRecorder())
+
+	crs, err := requestCRS(c, &models.Collection{Name: "parcels", SRID: 2056})
+	assert.Nil(err)
+	assert.Equal(geo.CRS{SRID: 2056}, crs)
+}
+
+func TestSupportedCRS(t *testing.T) {
+	assert.Equal(t, []string{
+		geo.CRS84URI,
+		"http://www.opengis.net/def/crs/EPSG/0/2056",
+		"http://www.opengis.net/def/crs/EPSG/0/3857",
+	}, supportedCRS(&models.Collection{
+		SRID: 2056,
+		CRS:  []string{"http://www.opengis.net/def/crs/EPSG/0/3857", "http://www.opengis.net/def/crs/EPSG/0/2056"},
+	}))
+
+	assert.Equal(t, []string{geo.CRS84URI}, supportedCRS(&models.Collection{}))
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/labstack/echo"
+	"github.com/tschaub/pgfs/pkg/geo"
+	"github.com/tschaub/pgfs/pkg/models"
+)
+
+const mimeCSV = "text/csv"
+
+// Values of the csv-geometry parameter
+const (
+	csvGeometryWKT    = "wkt"
+	csvGeometryLonLat = "lonlat"
+)
+
+// csvColumns returns the header row for features with properties of the
+// given types.  The feature id comes first, then the geometry (as a WKT
+// column or as two coordinate columns), then the properties sorted by name.
+func csvColumns(types map[string]string, geometry string, crs geo.CRS) []string {
+	columns := []string{models.IDProperty}
+	switch geometry {
+	case csvGeometryWKT:
+		columns = append(columns, "geometry")
+	case csvGeometryLonLat:
+		if crs.Geographic() {
+			columns = append(columns, "lon", "lat")
+		} else {
+			columns = append(columns, "x", "y")
+		}
+	}
+
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append(columns, names...)
+}
+
+// csvCoordinates returns the x (or longitude) and y (or latitude) of a point
+// geometry.  Other geometries have empty coordinates.
+func csvCoordinates(geometry *geo.Geometry, crs geo.CRS) (string, string) {
+	g := geometry.GeoJSON()
+	if g == nil || g.Point == nil {
+		return "", ""
+	}
+	x, y := g.Point[0], g.Point[1]
+	if crs.LatLon {
+		x, y = y, x
+	}
+	return strconv.FormatFloat(x, 'f', -1, 64), strconv.FormatFloat(y, 'f', -1, 64)
+}
+
+// csvValue formats a property value.  Null values are empty and objects and
+// arrays are written as JSON.
+func csvValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	data, err := json.Marshal(value)
+	return string(data), err
+}
+
+// encodeCSV streams features as CSV with a column for each property of the
+// matching features.  The csv-geometry parameter selects WKT (the default)
+// or coordinate columns (for points).  There are no geometry columns when
+// geometries are skipped.
+func encodeCSV(c echo.Context, format *Format, code int, value interface{}) error {
+	page, ok := value.(*FeaturePage)
+	if !ok {
+		return fmt.Errorf("cannot encode %T as CSV", value)
+	}
+
+	geometry := c.QueryParam("csv-geometry")
+	switch geometry {
+	case "":
+		geometry = csvGeometryWKT
+	case csvGeometryWKT, csvGeometryLonLat:
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bad 'csv-geometry': expected '%s' or '%s'", csvGeometryWKT, csvGeometryLonLat))
+	}
+	if skip, _ := strconv.ParseBool(c.QueryParam("skipGeometry")); skip {
+		geometry = ""
+	}
+
+	types, typesErr := page.Stream.PropertyTypes()
+	if typesErr != nil {
+		return typesErr
+	}
+	columns := csvColumns(types, geometry, page.CRS)
+
+	if err := page.Open(); err != nil {
+		return err
+	}
+
+	response := c.Response()
+	response.Header().Set(echo.HeaderContentType, format.MediaType)
+	response.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(response)
+	if err := writer.Write(columns); err != nil {
+		return err
+	}
+
+	properties := columns[len(columns)-len(types):]
+	for page.Stream.Next() {
+		feature := &models.Feature{}
+		if err := page.Stream.Scan(feature); err != nil {
+			return err
+		}
+
+		row := []string{feature.ID.String()}
+		switch geometry {
+		case csvGeometryWKT:
+			row = append(row, feature.Geometry.WKT())
+		case csvGeometryLonLat:
+			x, y := csvCoordinates(&feature.Geometry, page.CRS)
+			row = append(row, x, y)
+		}
+		for _, name := range properties {
+			value, err := csvValue(feature.Properties[name])
+			if err != nil {
+				return err
+			}
+			row = append(row, value)
+		}
+
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	if err := page.Stream.Err(); err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tschaub/pgfs/pkg/geo"
+)
+
+func TestCSVColumns(t *testing.T) {
+	assert := assert.New(t)
+
+	types := map[string]string{"name": "string", "rank": "number", "id": "string"}
+	assert.Equal([]string{"id", "geometry", "id", "name", "rank"}, csvColumns(types, csvGeometryWKT, geo.CRS84))
+	assert.Equal([]string{"id", "lon", "lat", "id", "name", "rank"}, csvColumns(types, csvGeometryLonLat, geo.CRS{SRID: 4326, LatLon: true}))
+	assert.Equal([]string{"id", "x", "y"}, csvColumns(map[string]string{}, csvGeometryLonLat, geo.CRS{SRID: 3857}))
+	assert.Equal([]string{"id", "name"}, csvColumns(map[string]string{"name": "string"}, "", geo.CRS84))
+}
+
+func TestCSVCoordinates(t *testing.T) {
+	assert := assert.New(t)
+
+	point := &geo.Geometry{}
+	assert.Nil(json.Unmarshal([]byte(`{"type":"Point","coordinates":[1.5,2]}`), point))
+
+	x, y := csvCoordinates(point, geo.CRS84)
+	assert.Equal([]string{"1.5", "2"}, []string{x, y})
+
+	// coordinates in latitude, longitude order are swapped
+	x, y = csvCoordinates(point, geo.CRS{SRID: 4326, LatLon: true})
+	assert.Equal([]string{"2", "1.5"}, []string{x, y})
+
+	line := &geo.Geometry{}
+	assert.Nil(json.Unmarshal([]byte(`{"type":"LineString","coordinates":[[1,2],[3,4]]}`), line))
+	x, y = csvCoordinates(line, geo.CRS84)
+	assert.Equal([]string{"", ""}, []string{x, y})
+
+	x, y = csvCoordinates(&geo.Geometry{}, geo.CRS84)
+	assert.Equal([]string{"", ""}, []string{x, y})
+}
+
+func TestCSVValue(t *testing.T) {
+	assert := assert.New(t)
+	cases := []struct {
+		value    interface{}
+		expected string
+	}{
+		{nil, ""},
+		{"a,b", "a,b"},
+		{1e21, "1000000000000000000000"},
+		{0.1, "0.1"},
+		{true, "true"},
+		{[]interface{}{"a", 1.0}, `["a",1]`},
+		{map[string]interface{}{"a": nil}, `{"a":null}`},
+	}
+
+	for _, c := range cases {
+		value, err := csvValue(c.value)
+		if assert.Nil(err) {
+			assert.Equal(c.expected, value)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/tschaub/pgfs/pkg/models"
+)
+
+// cursorToken is the signed content of a paging cursor.  The collection and
+// sort order are included so a cursor cannot be used with a different list.
+type cursorToken struct {
+	Collection string            `json:"c"`
+	Sort       string            `json:"s"`
+	Values     models.SortValues `json:"v"`
+	Backward   bool              `json:"b,omitempty"`
+}
+
+var errBadCursor = errors.New("invalid cursor")
+
+// newSecret returns a random secret for signing cursors
+func newSecret() ([]byte, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// sortSpec returns a string representing the sort order of a query
+func sortSpec(keys []models.SortKey) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		if key.Descending {
+			parts[i] = "-" + key.Property
+		} else {
+			parts[i] = "+" + key.Property
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// signature returns the HMAC of the payload
+func signature(secret []byte, payload []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// encodeCursor returns an opaque token for a position in a list of features
+func encodeCursor(secret []byte, query *models.FeatureQuery, values models.SortValues, backward bool) (string, error) {
+	payload, err := json.Marshal(&cursorToken{
+		Collection: query.Collection.Name,
+		Sort:       sortSpec(query.SortBy),
+		Values:     values,
+		Backward:   backward,
+	})
+	if err != nil {
+		return "", err
+	}
+	encoding := base64.RawURLEncoding
+	return encoding.EncodeToString(payload) + "." + encoding.EncodeToString(signature(secret, payload)), nil
+}
+
+// decodeCursor verifies a token and returns the cursor it represents.  An
+// error is returned if the token was not signed with the secret or if it was
+// created for a different collection or sort order.
+func decodeCursor(secret []byte, token string, query *models.FeatureQuery) (*models.Cursor, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, errBadCursor
+	}
+
+	encoding := base64.RawURLEncoding
+	payload, payloadErr := encoding.DecodeString(parts[0])
+	if payloadErr != nil {
+		return nil, errBadCursor
+	}
+	sig, sigErr := encoding.DecodeString(parts[1])
+	if sigErr != nil {
+		return nil, errBadCursor
+	}
+	if !hmac.Equal(sig, signature(secret, payload)) {
+		return nil, errBadCursor
+	}
+
+	decoded := &cursorToken{}
+	if err := json.Unmarshal(payload, decoded); err != nil {
+		return nil, errBadCursor
+	}
+	if decoded.Collection != query.Collection.Name {
+		return nil, errors.New("cursor is for a different collection")
+	}
+	if decoded.Sort != sortSpec(query.SortBy) {
+		return nil, errors.New("cursor is for a different sort order")
+	}
+
+	return &models.Cursor{Values: decoded.Values, Backward: decoded.Backward}, nil
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tschaub/pgfs/pkg/models"
+)
+
+func TestCursor(t *testing.T) {
+	assert := assert.New(t)
+
+	secret := []byte("secret")
+	query := &models.FeatureQuery{
+		Collection: models.Collection{Name: "cities"},
+		SortBy:     []models.SortKey{{Property: "population", Descending: true}},
+	}
+	values := models.SortValues{json.RawMessage(`1000`), json.RawMessage(`"5f4e0cbb-4c1e-4d3c-9c55-6c0b5b8f2b1e"`)}
+
+	token, err := encodeCursor(secret, query, values, true)
+	if !assert.Nil(err) {
+		return
+	}
+
+	cursor, err := decodeCursor(secret, token, query)
+	if assert.Nil(err) {
+		assert.Equal(&models.Cursor{Values: values, Backward: true}, cursor)
+	}
+
+	_, err = decodeCursor([]byte("other"), token, query)
+	assert.Equal(errBadCursor, err)
+
+	parts := strings.Split(token, ".")
+	tampered, _ := encodeCursor([]byte("other"), query, models.SortValues{json.RawMessage(`0`)}, true)
+	_, err = decodeCursor(secret, strings.Split(tampered, ".")[0]+"."+parts[1], query)
+	assert.Equal(errBadCursor, err)
+
+	_, err = decodeCursor(secret, "not a cursor", query)
+	assert.Equal(errBadCursor, err)
+
+	_, err = decodeCursor(secret, token, &models.FeatureQuery{Collection: models.Collection{Name: "other"}, SortBy: query.SortBy})
+	assert.NotNil(err)
+
+	_, err = decodeCursor(secret, token, &models.FeatureQuery{Collection: query.Collection})
+	assert.NotNil(err)
+}
+
+func TestSortSpec(t *testing.T) {
+	assert.Equal(t, "", sortSpec(nil))
+	assert.Equal(t, "-population,+name", sortSpec([]models.SortKey{{Property: "population", Descending: true}, {Property: "name"}}))
+}
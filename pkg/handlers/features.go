@@ -2,12 +2,20 @@ package handlers
 
 import (
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo"
+	"github.com/tschaub/pgfs/pkg/cql"
 	"github.com/tschaub/pgfs/pkg/geo"
 	"github.com/tschaub/pgfs/pkg/models"
+	"github.com/tschaub/pgfs/pkg/temporal"
 )
 
 // NewFeatureInfo represents a GeoJSON Feature
@@ -23,37 +31,247 @@ type NewFeatureList struct {
 	More     bool              `json:"more"`
 }
 
+// FeaturePatch is a partial update to a feature.  Properties are applied as
+// a JSON merge patch.
+type FeaturePatch struct {
+	Geometry   *geo.Geometry   `json:"geometry"`
+	Properties json.RawMessage `json:"properties"`
+}
+
 // FeatureInfo represents a GeoJSON Feature
 type FeatureInfo struct {
+	Type       string                 `json:"type"`
 	ID         uuid.UUID              `json:"id"`
 	Geometry   geo.Geometry           `json:"geometry" validate:"required"`
 	Properties map[string]interface{} `json:"properties" validate:"required"`
 }
 
-// FeatureList is a GeoJSON FeatureCollection
+// FeatureList is a GeoJSON FeatureCollection.  Links to the next and
+// previous pages are included when there are more features.
 type FeatureList struct {
-	Type     string         `json:"type" validate:"required"`
-	Features []*FeatureInfo `json:"features" validate:"required"`
-	More     bool           `json:"more"`
+	Type           string         `json:"type" validate:"required"`
+	Features       []*FeatureInfo `json:"features" validate:"required"`
+	More           bool           `json:"more"`
+	Links          []*Link        `json:"links"`
+	NumberMatched  uint64         `json:"numberMatched"`
+	NumberReturned int            `json:"numberReturned"`
 }
 
 // FeatureListQuery allows features to be queried
 type FeatureListQuery struct {
-	Count uint64 `query:"count"`
-	After string `query:"after"`
+	Count            uint64 `query:"count"`
+	Limit            uint64 `query:"limit"`
+	After            string `query:"after"`
+	Before           string `query:"before"`
+	BBox             string `query:"bbox"`
+	BBoxCRS          string `query:"bbox-crs"`
+	Datetime         string `query:"datetime"`
+	Filter           string `query:"filter"`
+	FilterLang       string `query:"filter-lang"`
+	FilterCRS        string `query:"filter-crs"`
+	CRS              string `query:"crs"`
+	MaxDecimalDigits string `query:"maxdecimaldigits"`
+	Simplify         string `query:"simplify"`
+	ZoomLevel        string `query:"zoom-level"`
+	Properties       string `query:"properties"`
+	SkipGeometry     bool   `query:"skipGeometry"`
+	SortBy           string `query:"sortby"`
+	Cursor           string `query:"cursor"`
+	CSVGeometry      string `query:"csv-geometry"`
+	Format           string `query:"f"`
+}
+
+// FeatureGetQuery holds options for reading a single feature
+type FeatureGetQuery struct {
+	CRS          string `query:"crs"`
+	Properties   string `query:"properties"`
+	SkipGeometry bool   `query:"skipGeometry"`
+	Format       string `query:"f"`
+}
+
+// FeatureResult reports the identifier of an added feature and whether its
+// geometry was valid or repaired
+type FeatureResult struct {
+	Index    int       `json:"index"`
+	ID       uuid.UUID `json:"id"`
+	Valid    bool      `json:"valid"`
+	Reason   string    `json:"reason,omitempty"`
+	Repaired bool      `json:"repaired,omitempty"`
+}
+
+// FeatureResultList reports the results of adding features
+type FeatureResultList struct {
+	Features []*FeatureResult `json:"features"`
+}
+
+// FeatureSeqResult summarizes the features added from a sequence.  Only
+// features with invalid or repaired geometries are listed.
+type FeatureSeqResult struct {
+	Count    int              `json:"count"`
+	Features []*FeatureResult `json:"features"`
+}
+
+// FeatureErrorList lists features that could not be saved
+type FeatureErrorList struct {
+	Message  string                 `json:"message"`
+	Features []*models.FeatureError `json:"features"`
 }
 
+const mimeMergePatch = "application/merge-patch+json"
+
+var featureListParams = queryNames(&FeatureListQuery{})
+
 func infoFromFeature(f *models.Feature) *FeatureInfo {
 	return &FeatureInfo{
+		Type:       "Feature",
 		ID:         f.ID,
 		Geometry:   f.Geometry,
 		Properties: f.Properties,
 	}
 }
 
-// ListFeatures responds with a list of features
-func ListFeatures(db *sql.DB) echo.HandlerFunc {
+// propertyNames parses the comma separated properties parameter.  The list
+// is nil if the parameter is not given and empty if it has no names.
+func propertyNames(c echo.Context) []string {
+	values, ok := c.QueryParams()["properties"]
+	if !ok {
+		return nil
+	}
+
+	names := []string{}
+	for _, value := range values {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// parseSortBy parses a sortby parameter like +name,-population and makes sure
+// the properties are sortable.  A space before a name is treated as a plus
+// sign that was not encoded.
+func parseSortBy(value string, collection *models.Collection) ([]models.SortKey, error) {
+	keys := []models.SortKey{}
+	for _, part := range strings.Split(value, ",") {
+		key := models.SortKey{}
+		switch {
+		case strings.HasPrefix(part, "-"):
+			key.Descending = true
+			part = part[1:]
+		case strings.HasPrefix(part, "+"):
+			part = part[1:]
+		}
+
+		key.Property = strings.TrimSpace(part)
+		if key.Property == "" {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bad 'sortby': %s", value))
+		}
+		if !collection.Sortable(key.Property) {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("property '%s' is not sortable", key.Property))
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// maxDecimalDigits is the largest allowed value for the maxdecimaldigits parameter
+const maxDecimalDigits = 15
+
+// maxZoomLevel is the largest allowed value for the zoom-level parameter
+const maxZoomLevel = 30
+
+// simplifyTolerance returns the tolerance for simplifying geometries given
+// in CRS units with the simplify parameter or derived from the zoom-level
+// parameter as the size of a pixel in a Web Mercator tile at that zoom level
+func simplifyTolerance(query *FeatureListQuery, crs geo.CRS) (float64, error) {
+	if query.Simplify != "" && query.ZoomLevel != "" {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "only one of 'simplify' or 'zoom-level' can be used")
+	}
+
+	if query.Simplify != "" {
+		tolerance, err := strconv.ParseFloat(query.Simplify, 64)
+		if err != nil || tolerance < 0 || math.IsInf(tolerance, 0) || math.IsNaN(tolerance) {
+			return 0, echo.NewHTTPError(http.StatusBadRequest, "'simplify' must be a non-negative number")
+		}
+		return tolerance, nil
+	}
+
+	if query.ZoomLevel != "" {
+		zoom, err := strconv.ParseFloat(query.ZoomLevel, 64)
+		if err != nil || zoom < 0 || zoom > maxZoomLevel {
+			return 0, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("'zoom-level' must be a number between 0 and %d", maxZoomLevel))
+		}
+		return geo.Resolution(zoom, crs), nil
+	}
+
+	return 0, nil
+}
+
+// parseBBox parses the bbox of a query in the bbox-crs (CRS84 by default).
+// The bbox is nil if the query has none.
+func parseBBox(query *FeatureListQuery) (*geo.BBox, error) {
+	if query.BBox == "" {
+		if query.BBoxCRS != "" {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "'bbox-crs' requires 'bbox'")
+		}
+		return nil, nil
+	}
+
+	crs := geo.CRS84
+	if query.BBoxCRS != "" {
+		var crsErr error
+		crs, crsErr = geo.ParseCRS(query.BBoxCRS)
+		if crsErr != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bad 'bbox-crs': %s", crsErr))
+		}
+	}
+
+	bbox, bboxErr := geo.ParseBBox(query.BBox, crs)
+	if bboxErr != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bad 'bbox': %s", bboxErr))
+	}
+	return bbox, nil
+}
+
+// parseFilter parses a CQL2 filter and makes sure it only uses queryable properties
+func parseFilter(query *FeatureListQuery, collection *models.Collection) (cql.Expression, error) {
+	var filter cql.Expression
+	var parseErr error
+	switch query.FilterLang {
+	case "", "cql2-text":
+		filter, parseErr = cql.Parse(query.Filter)
+	case "cql2-json":
+		filter, parseErr = cql.ParseJSON([]byte(query.Filter))
+	default:
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("unsupported 'filter-lang' '%s'", query.FilterLang))
+	}
+	if parseErr != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bad 'filter': %s", parseErr))
+	}
+
+	for _, name := range cql.Properties(filter) {
+		if name == cql.GeometryProperty || name == cql.IDProperty {
+			continue
+		}
+		if !collection.Queryable(name) {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("property '%s' is not queryable", name))
+		}
+	}
+
+	return filter, nil
+}
+
+// ListFeatures responds with a list of features.  Features are streamed to
+// the response as they are read from the database.
+func ListFeatures(db *sql.DB, config *Config) echo.HandlerFunc {
 	return func(c echo.Context) error {
+		format, formatErr := config.Formats.negotiate(c, ResourceItems)
+		if formatErr != nil {
+			return formatErr
+		}
+
 		query := &FeatureListQuery{}
 		bindErr := c.Bind(query)
 		if bindErr != nil {
@@ -63,12 +281,75 @@ func ListFeatures(db *sql.DB) echo.HandlerFunc {
 		name := c.Param("collectionName")
 		collection := &models.Collection{Name: name}
 		if getErr := models.Get(db, collection); getErr != nil {
-			return echo.NewHTTPError(http.StatusNotFound)
+			if getErr == sql.ErrNoRows {
+				return echo.NewHTTPError(http.StatusNotFound)
+			}
+			return getErr
+		}
+
+		crs, crsErr := collectionCRS(query.CRS, "crs", collection)
+		if crsErr != nil {
+			return crsErr
+		}
+
+		if query.Count != 0 && query.Limit != 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "only one of 'count' or 'limit' can be used")
+		}
+		count := query.Count
+		if count == 0 {
+			// limit is the OGC API - Features name for count
+			count = query.Limit
 		}
 
 		featureQuery := &models.FeatureQuery{
 			Collection: *collection,
-			Limit:      query.Count,
+			Limit:      pageLimit(count, config),
+			CRS:        crs,
+		}
+
+		if query.MaxDecimalDigits != "" {
+			digits, err := strconv.Atoi(query.MaxDecimalDigits)
+			if err != nil || digits < 0 || digits > maxDecimalDigits {
+				return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("'maxdecimaldigits' must be an integer between 0 and %d", maxDecimalDigits))
+			}
+			featureQuery.MaxDecimalDigits = &digits
+		}
+
+		tolerance, toleranceErr := simplifyTolerance(query, crs)
+		if toleranceErr != nil {
+			return toleranceErr
+		}
+		featureQuery.Tolerance = tolerance
+		featureQuery.PropertyNames = propertyNames(c)
+		featureQuery.SkipGeometry = query.SkipGeometry
+
+		if query.SortBy != "" {
+			sortBy, sortErr := parseSortBy(query.SortBy, collection)
+			if sortErr != nil {
+				return sortErr
+			}
+			featureQuery.SortBy = sortBy
+		}
+
+		positions := 0
+		for _, value := range []string{query.After, query.Before, query.Cursor} {
+			if value != "" {
+				positions++
+			}
+		}
+		if positions > 1 {
+			return echo.NewHTTPError(http.StatusBadRequest, "only one of 'after', 'before', or 'cursor' can be used")
+		}
+
+		// export formats include every feature unless a page is requested
+		featureQuery.All = format.Export && count == 0 && positions == 0
+
+		if query.Cursor != "" {
+			cursor, err := decodeCursor(config.CursorSecret, query.Cursor, featureQuery)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bad 'cursor': %s", err))
+			}
+			featureQuery.Cursor = cursor
 		}
 
 		if query.After != "" {
@@ -79,30 +360,177 @@ func ListFeatures(db *sql.DB) echo.HandlerFunc {
 			feature := &models.Feature{ID: id}
 			getErr := models.Get(db, feature)
 			if getErr != nil {
-				return echo.NewHTTPError(http.StatusBadRequest, "bad 'after' id")
+				if getErr == sql.ErrNoRows {
+					return echo.NewHTTPError(http.StatusBadRequest, "bad 'after' id")
+				}
+				return getErr
 			}
 			featureQuery.After = feature
 		}
 
-		features := models.Features{}
-		more, listErr := models.Query(db, &features, featureQuery)
-		if listErr != nil {
-			return listErr
+		if query.Before != "" {
+			id, err := uuid.Parse(query.Before)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "cannot parse 'before' as a UUID")
+			}
+			feature := &models.Feature{ID: id}
+			getErr := models.Get(db, feature)
+			if getErr != nil {
+				if getErr == sql.ErrNoRows {
+					return echo.NewHTTPError(http.StatusBadRequest, "bad 'before' id")
+				}
+				return getErr
+			}
+			featureQuery.Before = feature
+		}
+
+		bbox, bboxErr := parseBBox(query)
+		if bboxErr != nil {
+			return bboxErr
+		}
+		featureQuery.BBox = bbox
+
+		if query.Datetime != "" {
+			if collection.TimeProperty == "" {
+				return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("collection '%s' has no time property", name))
+			}
+			interval, intervalErr := temporal.ParseInterval(query.Datetime)
+			if intervalErr != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bad 'datetime': %s", intervalErr))
+			}
+			featureQuery.Datetime = interval
+		}
+
+		if query.Filter != "" {
+			filter, filterErr := parseFilter(query, collection)
+			if filterErr != nil {
+				return filterErr
+			}
+			featureQuery.Filter = filter
+			if query.FilterCRS != "" {
+				crs, crsErr := geo.ParseCRS(query.FilterCRS)
+				if crsErr != nil {
+					return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bad 'filter-crs': %s", crsErr))
+				}
+				featureQuery.FilterCRS = crs
+			}
+		} else if query.FilterCRS != "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "'filter-crs' requires 'filter'")
 		}
 
-		list := make([]*FeatureInfo, len(features))
-		for i, result := range features {
-			list[i] = infoFromFeature(result)
+		for key, values := range c.QueryParams() {
+			if featureListParams[key] {
+				continue
+			}
+			if !collection.Queryable(key) {
+				return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("unknown query parameter '%s'", key))
+			}
+			if featureQuery.Properties == nil {
+				featureQuery.Properties = map[string][]string{}
+			}
+			featureQuery.Properties[key] = values
+		}
+
+		stream, streamErr := models.Stream(db, featureQuery)
+		if _, ok := streamErr.(*models.CursorError); ok || streamErr == models.ErrCursorMismatch {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bad 'cursor': %s", streamErr))
+		}
+		if streamErr != nil {
+			return streamErr
+		}
+		defer stream.Close()
+
+		page := &FeaturePage{
+			List:       &FeatureList{Type: "FeatureCollection"},
+			Stream:     stream,
+			Collection: collection,
+			CRS:        crs,
+		}
+
+		// the page is described by the first feature in the stream
+		page.open = func() error {
+			if err := stream.Open(); err != nil {
+				return err
+			}
+
+			links, linksErr := pageLinks(c, stream.Page, featureQuery, config.CursorSecret, format.MediaType)
+			if linksErr != nil {
+				return linksErr
+			}
+
+			page.List.More = stream.Page.More
+			page.List.Links = links
+			page.List.NumberMatched = stream.Page.Matched
+			page.List.NumberReturned = stream.Page.Returned
+			c.Response().Header().Set("Link", linkHeader(links))
+			return nil
 		}
 
-		resultList := &FeatureList{
-			Type:     "FeatureCollection",
-			Features: list,
-			More:     more,
+		setResponseCRS(c, crs)
+		return format.Encode(c, format, http.StatusOK, page)
+	}
+}
+
+// pageLimit returns the number of features to list for a requested count.
+// Counts above the server maximum are reduced to the maximum.
+func pageLimit(count uint64, config *Config) uint64 {
+	if count == 0 {
+		return config.DefaultCount
+	}
+	if count > config.MaxCount {
+		return config.MaxCount
+	}
+	return count
+}
+
+// pageLinks returns self, next, and prev links for a page of features.  The
+// next and prev links use cursors with the sort values of the last and first
+// features.  When paging forward, there is a next page if the query found more
+// features and a previous page if the query started after a position (and vice
+// versa when paging backward).  Links have the media type of the response.
+func pageLinks(c echo.Context, page *models.Page, query *models.FeatureQuery, secret []byte, mediaType string) ([]*Link, error) {
+	links := []*Link{
+		{Href: requestHref(c, c.QueryParams()), Rel: "self", Type: mediaType, Title: "This page"},
+	}
+
+	if page.Returned == 0 {
+		return links, nil
+	}
+
+	href := func(values models.SortValues, backward bool) (string, error) {
+		cursor, err := encodeCursor(secret, query, values, backward)
+		if err != nil {
+			return "", err
 		}
+		params := url.Values{}
+		for name, values := range c.QueryParams() {
+			params[name] = values
+		}
+		params.Del("after")
+		params.Del("before")
+		params.Set("cursor", cursor)
+		return requestHref(c, params), nil
+	}
 
-		return c.JSON(http.StatusOK, resultList)
+	backward := query.Before != nil || (query.Cursor != nil && query.Cursor.Backward)
+	started := query.After != nil || (query.Cursor != nil && !query.Cursor.Backward)
+
+	if page.More || backward {
+		next, err := href(page.Last, false)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, &Link{Href: next, Rel: "next", Type: mediaType, Title: "Next page"})
+	}
+	if (page.More && backward) || started {
+		prev, err := href(page.First, true)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, &Link{Href: prev, Rel: "prev", Type: mediaType, Title: "Previous page"})
 	}
+
+	return links, nil
 }
 
 // AddFeatures adds features to a collection
@@ -110,17 +538,18 @@ func AddFeatures(db *sql.DB) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		name := c.Param("collectionName")
 
-		collection := &models.Collection{Name: name}
-		getErr := models.Get(db, collection)
+		collection, getErr := getCollection(db, name)
 		if getErr != nil {
-			if getErr == sql.ErrNoRows {
-				return echo.NewHTTPError(http.StatusNotFound)
-			}
 			return getErr
 		}
 
-		if collection.Name != name {
-			return echo.NewHTTPError(http.StatusNotFound)
+		crs, crsErr := requestCRS(c, collection)
+		if crsErr != nil {
+			return crsErr
+		}
+
+		if seqType(c.Request().Header.Get(echo.HeaderContentType)) != "" {
+			return addFeatureSeq(c, db, collection, crs)
 		}
 
 		info := &NewFeatureList{}
@@ -128,16 +557,297 @@ func AddFeatures(db *sql.DB) echo.HandlerFunc {
 			return bindErr
 		}
 
+		geometryErr := &models.GeometryError{}
+		for i, feature := range info.Features {
+			if err := checkFeatureGeometry(collection, feature); err != nil {
+				geometryErr.Features = append(geometryErr.Features, &models.FeatureError{Index: i, Reason: err.Error()})
+			}
+		}
+		if len(geometryErr.Features) > 0 {
+			return unprocessable(geometryErr)
+		}
+
 		features := make(models.Features, len(info.Features))
 		for i, feature := range info.Features {
-			features[i] = &models.Feature{
-				ID:             uuid.New(),
-				CollectionName: name,
-				Geometry:       feature.Geometry,
-				Properties:     feature.Properties,
+			features[i] = newFeature(collection, feature, crs)
+		}
+
+		if insertErr := models.BulkInsert(db, &features); insertErr != nil {
+			return unprocessable(insertErr)
+		}
+
+		results := make([]*FeatureResult, len(features))
+		for i, feature := range features {
+			results[i] = resultFromFeature(i, feature)
+		}
+
+		return c.JSON(http.StatusOK, &FeatureResultList{Features: results})
+	}
+}
+
+// checkFeatureGeometry checks that the geometry of a new feature is allowed
+// in a collection and is structurally valid
+func checkFeatureGeometry(collection *models.Collection, feature *NewFeatureInfo) error {
+	if err := collection.CheckGeometry(&feature.Geometry); err != nil {
+		return err
+	}
+	return feature.Geometry.Validate()
+}
+
+// newFeature creates a feature to be added to a collection
+func newFeature(collection *models.Collection, feature *NewFeatureInfo, crs geo.CRS) *models.Feature {
+	return &models.Feature{
+		ID:             uuid.New(),
+		CollectionName: collection.Name,
+		Geometry:       feature.Geometry,
+		Properties:     feature.Properties,
+		CRS:            crs,
+	}
+}
+
+// resultFromFeature reports the result of adding a feature
+func resultFromFeature(index int, feature *models.Feature) *FeatureResult {
+	result := &FeatureResult{Index: index, ID: feature.ID, Valid: true}
+	if validity := feature.Validity; validity != nil {
+		result.Valid = validity.Valid
+		result.Reason = validity.Reason
+		result.Repaired = validity.Repaired
+	}
+	return result
+}
+
+// unprocessable responds with a 422 listing features with geometries that do
+// not match their collection.  Other errors are returned as is.
+func unprocessable(err error) error {
+	if geometryErr, ok := err.(*models.GeometryError); ok {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, &FeatureErrorList{
+			Message:  geometryErr.Error(),
+			Features: geometryErr.Features,
+		})
+	}
+	return err
+}
+
+// getCollection finds a collection by name
+func getCollection(db *sql.DB, name string) (*models.Collection, error) {
+	collection := &models.Collection{Name: name}
+	getErr := models.Get(db, collection)
+	if getErr != nil {
+		if getErr == sql.ErrNoRows {
+			return nil, echo.NewHTTPError(http.StatusNotFound)
+		}
+		return nil, getErr
+	}
+
+	return collection, nil
+}
+
+// getFeature finds a feature by ID in the named collection with its geometry
+// in the given CRS
+func getFeature(db *sql.DB, c echo.Context, crs geo.CRS) (*models.Feature, error) {
+	id, parseErr := uuid.Parse(c.Param("featureId"))
+	if parseErr != nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound)
+	}
+
+	feature := &models.Feature{ID: id, CRS: crs}
+	getErr := models.Get(db, feature)
+	if getErr != nil {
+		if getErr == sql.ErrNoRows {
+			return nil, echo.NewHTTPError(http.StatusNotFound)
+		}
+		return nil, getErr
+	}
+
+	if feature.CollectionName != c.Param("collectionName") {
+		return nil, echo.NewHTTPError(http.StatusNotFound)
+	}
+
+	return feature, nil
+}
+
+// GetFeature responds with a single feature
+func GetFeature(db *sql.DB, formats Formats) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		format, formatErr := formats.negotiate(c, ResourceItem)
+		if formatErr != nil {
+			return formatErr
+		}
+
+		query := &FeatureGetQuery{}
+		if bindErr := c.Bind(query); bindErr != nil {
+			return bindErr
+		}
+
+		collection, getErr := getCollection(db, c.Param("collectionName"))
+		if getErr != nil {
+			return getErr
+		}
+
+		crs, crsErr := collectionCRS(query.CRS, "crs", collection)
+		if crsErr != nil {
+			return crsErr
+		}
+
+		id, parseErr := uuid.Parse(c.Param("featureId"))
+		if parseErr != nil {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+
+		features := models.Features{}
+		_, listErr := models.Query(db, &features, &models.FeatureQuery{
+			Collection:    *collection,
+			ID:            id,
+			Limit:         1,
+			CRS:           crs,
+			PropertyNames: propertyNames(c),
+			SkipGeometry:  query.SkipGeometry,
+		})
+		if listErr != nil {
+			return listErr
+		}
+		if len(features) == 0 {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+
+		setResponseCRS(c, crs)
+		return format.Encode(c, format, http.StatusOK, infoFromFeature(features[0]))
+	}
+}
+
+// ReplaceFeature replaces the geometry and properties of a feature
+func ReplaceFeature(db *sql.DB) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		collection, getErr := getCollection(db, c.Param("collectionName"))
+		if getErr != nil {
+			return getErr
+		}
+
+		crs, crsErr := requestCRS(c, collection)
+		if crsErr != nil {
+			return crsErr
+		}
+
+		feature, getErr := getFeature(db, c, crs)
+		if getErr != nil {
+			return getErr
+		}
+
+		info := &NewFeatureInfo{}
+		if bindErr := c.Bind(info); bindErr != nil {
+			return bindErr
+		}
+
+		if validateErr := c.Validate(info); validateErr != nil {
+			return validateErr
+		}
+
+		feature.Geometry = info.Geometry
+		feature.Properties = info.Properties
+
+		if updateErr := models.Update(db, feature); updateErr != nil {
+			if updateErr == sql.ErrNoRows {
+				return echo.NewHTTPError(http.StatusNotFound)
+			}
+			return unprocessable(updateErr)
+		}
+
+		if feature.Validity != nil && feature.Validity.Repaired {
+			if getErr := models.Get(db, feature); getErr != nil {
+				return getErr
+			}
+		}
+
+		setResponseCRS(c, crs)
+		return c.JSON(http.StatusOK, infoFromFeature(feature))
+	}
+}
+
+// PatchFeature applies a JSON merge patch to the properties of a feature and
+// optionally replaces its geometry
+func PatchFeature(db *sql.DB) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctype := c.Request().Header.Get(echo.HeaderContentType)
+		if !strings.HasPrefix(ctype, echo.MIMEApplicationJSON) && !strings.HasPrefix(ctype, mimeMergePatch) {
+			return echo.ErrUnsupportedMediaType
+		}
+
+		collection, getErr := getCollection(db, c.Param("collectionName"))
+		if getErr != nil {
+			return getErr
+		}
+
+		crs, crsErr := requestCRS(c, collection)
+		if crsErr != nil {
+			return crsErr
+		}
+
+		feature, getErr := getFeature(db, c, crs)
+		if getErr != nil {
+			return getErr
+		}
+
+		patch := &FeaturePatch{}
+		if decodeErr := json.NewDecoder(c.Request().Body).Decode(patch); decodeErr != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("cannot parse patch: %s", decodeErr))
+		}
+
+		if len(patch.Properties) > 0 {
+			var properties interface{}
+			if err := json.Unmarshal(patch.Properties, &properties); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("cannot parse properties: %s", err))
+			}
+			merged, ok := mergePatch(map[string]interface{}(feature.Properties), properties).(map[string]interface{})
+			if !ok {
+				return echo.NewHTTPError(http.StatusBadRequest, "properties patch must be an object")
+			}
+			feature.Properties = merged
+		}
+
+		// the stored geometry is left as is (not the copy read in the
+		// request CRS) unless the patch replaces it
+		if patch.Geometry == nil {
+			if updateErr := models.UpdateProperties(db, feature); updateErr != nil {
+				if updateErr == sql.ErrNoRows {
+					return echo.NewHTTPError(http.StatusNotFound)
+				}
+				return updateErr
 			}
+			setResponseCRS(c, crs)
+			return c.JSON(http.StatusOK, infoFromFeature(feature))
+		}
+
+		feature.Geometry = *patch.Geometry
+		if updateErr := models.Update(db, feature); updateErr != nil {
+			if updateErr == sql.ErrNoRows {
+				return echo.NewHTTPError(http.StatusNotFound)
+			}
+			return unprocessable(updateErr)
+		}
+
+		if feature.Validity != nil && feature.Validity.Repaired {
+			if getErr := models.Get(db, feature); getErr != nil {
+				return getErr
+			}
+		}
+
+		setResponseCRS(c, crs)
+		return c.JSON(http.StatusOK, infoFromFeature(feature))
+	}
+}
+
+// DeleteFeature removes a feature
+func DeleteFeature(db *sql.DB) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		feature, getErr := getFeature(db, c, geo.CRS84)
+		if getErr != nil {
+			return getErr
+		}
+
+		if deleteErr := models.Delete(db, feature); deleteErr != nil {
+			return deleteErr
 		}
 
-		return models.BulkInsert(db, &features)
+		return c.NoContent(http.StatusNoContent)
 	}
 }
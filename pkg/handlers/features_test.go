@@ -0,0 +1,342 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/tschaub/pgfs/pkg/geo"
+	"github.com/tschaub/pgfs/pkg/models"
+)
+
+func TestSimplifyTolerance(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		query     *FeatureListQuery
+		crs       geo.CRS
+		tolerance float64
+		err       bool
+	}{
+		{query: &FeatureListQuery{}, crs: geo.CRS84, tolerance: 0},
+		{query: &FeatureListQuery{Simplify: "0.01"}, crs: geo.CRS84, tolerance: 0.01},
+		{query: &FeatureListQuery{ZoomLevel: "1"}, crs: geo.CRS84, tolerance: 0.703125},
+		{query: &FeatureListQuery{ZoomLevel: "0"}, crs: geo.CRS{SRID: 3857}, tolerance: geo.Resolution(0, geo.CRS{SRID: 3857})},
+		{query: &FeatureListQuery{Simplify: "-1"}, err: true},
+		{query: &FeatureListQuery{Simplify: "NaN"}, err: true},
+		{query: &FeatureListQuery{ZoomLevel: "31"}, err: true},
+		{query: &FeatureListQuery{ZoomLevel: "x"}, err: true},
+		{query: &FeatureListQuery{Simplify: "1", ZoomLevel: "1"}, err: true},
+	}
+
+	for i, c := range cases {
+		tolerance, err := simplifyTolerance(c.query, c.crs)
+		if c.err {
+			assert.NotNil(err, "expected an error for case %d", i)
+			continue
+		}
+		if assert.Nil(err, "unexpected error for case %d", i) {
+			assert.Equal(c.tolerance, tolerance, "unexpected tolerance for case %d", i)
+		}
+	}
+}
+
+func TestPropertyNames(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		target string
+		names  []string
+	}{
+		{"/items", nil},
+		{"/items?properties=", []string{}},
+		{"/items?properties=name", []string{"name"}},
+		{"/items?properties=name,%20population,", []string{"name", "population"}},
+		{"/items?properties=name&properties=area", []string{"name", "area"}},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, c.target, nil)
+		ctx := echo.New().NewContext(req, httptest.NewRecorder())
+		assert.Equal(c.names, propertyNames(ctx), c.target)
+	}
+}
+
+func TestParseSortBy(t *testing.T) {
+	assert := assert.New(t)
+
+	collection := &models.Collection{Name: "countries", Sortables: []string{"name", "population"}}
+
+	keys, err := parseSortBy("+name,-population,id", collection)
+	assert.Nil(err)
+	assert.Equal([]models.SortKey{
+		{Property: "name"},
+		{Property: "population", Descending: true},
+		{Property: "id"},
+	}, keys)
+
+	keys, err = parseSortBy(" name", collection)
+	assert.Nil(err)
+	assert.Equal([]models.SortKey{{Property: "name"}}, keys)
+
+	_, err = parseSortBy("-area", collection)
+	assert.NotNil(err)
+
+	_, err = parseSortBy("name,", collection)
+	assert.NotNil(err)
+}
+
+func TestParseBBox(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		bbox     string
+		crs      string
+		expected *geo.BBox
+	}{
+		{"", "", nil},
+		{"-10,-20,10,20", "", &geo.BBox{MinX: -10, MinY: -20, MaxX: 10, MaxY: 20, CRS: geo.CRS84}},
+		{"-10,-20,10,20", geo.CRS84URI, &geo.BBox{MinX: -10, MinY: -20, MaxX: 10, MaxY: 20, CRS: geo.CRS84}},
+		{"-20,-10,20,10", "http://www.opengis.net/def/crs/EPSG/0/4326", &geo.BBox{MinX: -10, MinY: -20, MaxX: 10, MaxY: 20, CRS: geo.CRS{SRID: 4326, LatLon: true}}},
+		{"-1e6,-2e6,1e6,2e6", "[EPSG:3857]", &geo.BBox{MinX: -1e6, MinY: -2e6, MaxX: 1e6, MaxY: 2e6, CRS: geo.CRS{SRID: 3857}}},
+	}
+
+	for _, c := range cases {
+		bbox, err := parseBBox(&FeatureListQuery{BBox: c.bbox, BBoxCRS: c.crs})
+		assert.Nil(err, "%s %s", c.bbox, c.crs)
+		assert.Equal(c.expected, bbox, "%s %s", c.bbox, c.crs)
+	}
+}
+
+func TestParseBBoxInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		bbox    string
+		crs     string
+		message string
+	}{
+		{"", geo.CRS84URI, "'bbox-crs' requires 'bbox'"},
+		{"1,2,3", "", "bad 'bbox': bbox must have 4 or 6 comma-separated numbers"},
+		{"-10,-20,10,20", "EPSG:x", "bad 'bbox-crs': invalid EPSG code in CRS 'EPSG:x'"},
+		{"-10,-20,10,20", "urn:ogc:def:crs:EPSG::4326", "bad 'bbox-crs': unsupported CRS 'urn:ogc:def:crs:EPSG::4326'"},
+		{"-10,-100,10,20", "", "bad 'bbox': bbox latitude must be between -90 and 90"},
+	}
+
+	for _, c := range cases {
+		_, err := parseBBox(&FeatureListQuery{BBox: c.bbox, BBoxCRS: c.crs})
+		httpErr, ok := err.(*echo.HTTPError)
+		if assert.True(ok, "expected an HTTP error for %s %s", c.bbox, c.crs) {
+			assert.Equal(http.StatusBadRequest, httpErr.Code)
+			assert.Equal(c.message, httpErr.Message)
+		}
+	}
+}
+
+func TestListFeaturesFilters(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	if !assert.Nil(models.Insert(db, &models.Collection{Name: "places", Title: "places", Description: "places", Queryables: []string{"name"}})) {
+		return
+	}
+	router := testRouter(t, db)
+	addFeature(t, router, "places", `{"type":"Feature","geometry":{"type":"Point","coordinates":[1,2]},"properties":{"name":"one"}}`)
+	addFeature(t, router, "places", `{"type":"Feature","geometry":{"type":"Point","coordinates":[30,40]},"properties":{"name":"two"}}`)
+
+	cases := []struct {
+		query    string
+		code     int
+		returned int
+	}{
+		{"bbox=0,0,10,10", http.StatusOK, 1},
+		{"bbox=0,0,10,10,20", http.StatusBadRequest, 0},
+		{"bbox=0,0,1e6,1e6&bbox-crs=EPSG:3857", http.StatusOK, 1},
+		{"bbox-crs=EPSG:3857", http.StatusBadRequest, 0},
+		{"name=two", http.StatusOK, 1},
+		{"name=one&name=two", http.StatusOK, 2},
+		{"rank=1", http.StatusBadRequest, 0},
+		{"filter=name%3D'one'&filter-crs=EPSG:3857", http.StatusOK, 1},
+		{"filter-crs=EPSG:3857", http.StatusBadRequest, 0},
+		{"limit=1", http.StatusOK, 1},
+		{"count=1&limit=1", http.StatusBadRequest, 0},
+	}
+
+	for _, c := range cases {
+		res := serve(router, http.MethodGet, "/collections/places/items?"+c.query, "")
+		if !assert.Equal(c.code, res.Code, c.query) || c.code != http.StatusOK {
+			continue
+		}
+		list := &FeatureList{}
+		if assert.Nil(json.Unmarshal(res.Body.Bytes(), list), c.query) {
+			assert.Equal(c.returned, list.NumberReturned, c.query)
+		}
+	}
+}
+
+func TestPageLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	config := &Config{DefaultCount: 10, MaxCount: 100}
+	assert.Equal(uint64(10), pageLimit(0, config))
+	assert.Equal(uint64(50), pageLimit(50, config))
+	assert.Equal(uint64(100), pageLimit(500, config))
+}
+
+func TestConfigDefaults(t *testing.T) {
+	assert := assert.New(t)
+
+	config, err := Config{}.withDefaults()
+	if assert.Nil(err) {
+		assert.Len(config.CursorSecret, 32)
+		assert.Equal(defaultCount, config.DefaultCount)
+		assert.Equal(maxCount, config.MaxCount)
+	}
+
+	config, err = Config{CursorSecret: []byte("secret"), MaxCount: 100}.withDefaults()
+	if assert.Nil(err) {
+		assert.Equal([]byte("secret"), config.CursorSecret)
+		assert.Equal(uint64(100), config.DefaultCount)
+	}
+}
+
+func TestExportAll(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	if !assert.Nil(models.Insert(db, &models.Collection{Name: "places", Title: "places", Description: "places"})) {
+		return
+	}
+
+	// more features than are listed by default
+	total := int(defaultCount) + 100
+	features := models.Features{}
+	for i := 0; i < total; i++ {
+		var geometry geo.Geometry
+		if err := geometry.UnmarshalJSON([]byte(fmt.Sprintf(`{"type":"Point","coordinates":[%d,1]}`, i%180))); err != nil {
+			t.Fatal(err)
+		}
+		features = append(features, &models.Feature{
+			CollectionName: "places",
+			Geometry:       geometry,
+			Properties:     models.PropertyMap{"rank": float64(i)},
+		})
+	}
+	if !assert.Nil(models.BulkInsert(db, &features)) {
+		return
+	}
+
+	router := testRouter(t, db)
+
+	res := serve(router, http.MethodGet, "/collections/places/items?f=csv", "")
+	assert.Equal(http.StatusOK, res.Code)
+	rows, err := csv.NewReader(res.Body).ReadAll()
+	assert.Nil(err)
+	assert.Len(rows, total+1)
+
+	res = serve(router, http.MethodGet, "/collections/places/items?f=gpkg", "")
+	assert.Equal(http.StatusOK, res.Code)
+	data := res.Body.Bytes()
+	for _, feature := range features {
+		if !assert.True(bytes.Contains(data, []byte(feature.ID.String())), "missing feature %s", feature.ID) {
+			break
+		}
+	}
+
+	// a page is still limited when a count is given
+	res = serve(router, http.MethodGet, "/collections/places/items?f=csv&count=10", "")
+	assert.Equal(http.StatusOK, res.Code)
+	rows, err = csv.NewReader(res.Body).ReadAll()
+	assert.Nil(err)
+	assert.Len(rows, 11)
+}
+
+func TestListFeaturesMissing(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	router := testRouter(t, db)
+
+	res := serve(router, http.MethodGet, "/collections/missing/items", "")
+	assert.Equal(http.StatusNotFound, res.Code)
+
+	if !assert.Nil(models.Insert(db, &models.Collection{Name: "places", Title: "places", Description: "places"})) {
+		return
+	}
+	res = serve(router, http.MethodGet, "/collections/places/items?after=7b1e2c0e-4a55-4d55-9d46-1d4f8f0b9a10", "")
+	assert.Equal(http.StatusBadRequest, res.Code)
+}
+
+// addFeature adds a feature to a collection and returns its id
+func addFeature(t *testing.T, router *echo.Echo, collection string, feature string) string {
+	res := serve(router, http.MethodPost, "/collections/"+collection+"/items", `{"type":"FeatureCollection","features":[`+feature+`]}`)
+	if res.Code != http.StatusOK {
+		t.Fatalf("unexpected response adding a feature: %d %s", res.Code, res.Body.String())
+	}
+	results := &FeatureResultList{}
+	if err := json.Unmarshal(res.Body.Bytes(), results); err != nil {
+		t.Fatal(err)
+	}
+	return results.Features[0].ID.String()
+}
+
+func TestEditFeature(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	if !assert.Nil(models.Insert(db, &models.Collection{Name: "places", Title: "places", Description: "places"})) {
+		return
+	}
+	router := testRouter(t, db)
+
+	id := addFeature(t, router, "places", `{"type":"Feature","geometry":{"type":"Point","coordinates":[1.123456789123,2]},"properties":{"name":"one","rank":1}}`)
+	missing := "/collections/places/items/7b1e2c0e-4a55-4d55-9d46-1d4f8f0b9a10"
+	path := "/collections/places/items/" + id
+
+	cases := []struct {
+		method string
+		target string
+		body   string
+		code   int
+	}{
+		{http.MethodPut, missing, `{"type":"Feature","geometry":{"type":"Point","coordinates":[1,2]},"properties":{}}`, http.StatusNotFound},
+		{http.MethodPatch, missing, `{"properties":{"name":"two"}}`, http.StatusNotFound},
+		{http.MethodDelete, missing, "", http.StatusNotFound},
+		{http.MethodPut, "/collections/other/items/" + id, `{"type":"Feature","geometry":{"type":"Point","coordinates":[1,2]},"properties":{}}`, http.StatusNotFound},
+		{http.MethodPatch, "/collections/places/items/not-an-id", `{"properties":{"name":"two"}}`, http.StatusNotFound},
+		{http.MethodPatch, path, `{"properties":`, http.StatusBadRequest},
+		{http.MethodPatch, path, `{"properties":["name"]}`, http.StatusBadRequest},
+		{http.MethodPatch, path, `{"properties":{"name":"two","rank":null}}`, http.StatusOK},
+	}
+	for _, c := range cases {
+		res := serve(router, c.method, c.target, c.body)
+		assert.Equal(c.code, res.Code, "%s %s %s", c.method, c.target, c.body)
+	}
+
+	// patching properties leaves the stored geometry as is
+	digits := 15
+	features := models.Features{}
+	_, err := models.Query(db, &features, &models.FeatureQuery{Collection: models.Collection{Name: "places"}, MaxDecimalDigits: &digits})
+	if assert.Nil(err) && assert.Len(features, 1) {
+		assert.Equal(models.PropertyMap{"name": "two"}, features[0].Properties)
+		assert.Equal(1.123456789123, features[0].Geometry.GeoJSON().Point[0])
+	}
+
+	res := serve(router, http.MethodPut, path, `{"type":"Feature","geometry":{"type":"Point","coordinates":[3,4]},"properties":{"name":"three"}}`)
+	assert.Equal(http.StatusOK, res.Code)
+
+	res = serve(router, http.MethodDelete, path, "")
+	assert.Equal(http.StatusNoContent, res.Code)
+	res = serve(router, http.MethodDelete, path, "")
+	assert.Equal(http.StatusNotFound, res.Code)
+}
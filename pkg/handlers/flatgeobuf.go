@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/labstack/echo"
+	"github.com/tschaub/pgfs/pkg/flatgeobuf"
+	"github.com/tschaub/pgfs/pkg/models"
+)
+
+// columnTypes maps JSON property types to FlatGeobuf column types.  Other
+// types (objects, arrays, and mixed values) are written as JSON.
+var columnTypes = map[string]flatgeobuf.ColumnType{
+	"boolean": flatgeobuf.Bool,
+	"number":  flatgeobuf.Double,
+	"string":  flatgeobuf.String,
+}
+
+// flatGeobufColumns returns the columns for properties with the given types
+// (sorted by name)
+func flatGeobufColumns(types map[string]string) []*flatgeobuf.Column {
+	columns := make([]*flatgeobuf.Column, 0, len(types))
+	for name, valueType := range types {
+		columnType, ok := columnTypes[valueType]
+		if !ok {
+			columnType = flatgeobuf.JSON
+		}
+		columns = append(columns, &flatgeobuf.Column{Name: name, Type: columnType})
+	}
+	sort.Slice(columns, func(i, j int) bool {
+		return columns[i].Name < columns[j].Name
+	})
+	return columns
+}
+
+// flatGeobufHeader describes the features of a page
+func flatGeobufHeader(page *FeaturePage, types map[string]string) *flatgeobuf.Header {
+	collection := page.Collection
+	return &flatgeobuf.Header{
+		Name:         collection.Name,
+		Title:        collection.Title,
+		Description:  collection.Description,
+		GeometryType: flatgeobuf.GeometryTypeOf(collection.GeometryType),
+		HasZ:         collection.Dimension == 3,
+		Columns:      flatGeobufColumns(types),
+		SRID:         page.CRS.SRID,
+	}
+}
+
+// encodeFlatGeobuf writes a page of features as FlatGeobuf.  Features are
+// written to a temporary file first so that a spatial index can be written
+// before them.
+func encodeFlatGeobuf(c echo.Context, format *Format, code int, value interface{}) error {
+	page, ok := value.(*FeaturePage)
+	if !ok {
+		return fmt.Errorf("cannot encode %T as FlatGeobuf", value)
+	}
+
+	types, typesErr := page.Stream.PropertyTypes()
+	if typesErr != nil {
+		return typesErr
+	}
+
+	if err := page.Open(); err != nil {
+		return err
+	}
+
+	writer, writerErr := flatgeobuf.NewWriter(flatGeobufHeader(page, types))
+	if writerErr != nil {
+		return writerErr
+	}
+	defer writer.Close()
+
+	for page.Stream.Next() {
+		feature := &models.Feature{}
+		if err := page.Stream.Scan(feature); err != nil {
+			return err
+		}
+		if err := writer.Add(feature.Geometry.GeoJSON(), feature.Properties); err != nil {
+			return err
+		}
+	}
+	if err := page.Stream.Err(); err != nil {
+		return err
+	}
+
+	response := c.Response()
+	response.Header().Set(echo.HeaderContentType, format.MediaType)
+	response.WriteHeader(http.StatusOK)
+	_, err := writer.WriteTo(response)
+	return err
+}
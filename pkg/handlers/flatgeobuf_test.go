@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tschaub/pgfs/pkg/flatgeobuf"
+)
+
+func TestFlatGeobufColumns(t *testing.T) {
+	assert := assert.New(t)
+
+	columns := flatGeobufColumns(map[string]string{
+		"name":  "string",
+		"rank":  "number",
+		"open":  "boolean",
+		"tags":  "array",
+		"mixed": "mixed",
+	})
+
+	assert.Equal([]*flatgeobuf.Column{
+		{Name: "mixed", Type: flatgeobuf.JSON},
+		{Name: "name", Type: flatgeobuf.String},
+		{Name: "open", Type: flatgeobuf.Bool},
+		{Name: "rank", Type: flatgeobuf.Double},
+		{Name: "tags", Type: flatgeobuf.JSON},
+	}, columns)
+}
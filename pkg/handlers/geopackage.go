@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/labstack/echo"
+	"github.com/tschaub/pgfs/pkg/gpkg"
+	"github.com/tschaub/pgfs/pkg/models"
+)
+
+// gpkgColumnTypes maps JSON property types to GeoPackage column types.  Other
+// types (objects, arrays, and mixed values) are written as JSON.
+var gpkgColumnTypes = map[string]gpkg.ColumnType{
+	"boolean": gpkg.Boolean,
+	"number":  gpkg.Real,
+	"string":  gpkg.Text,
+}
+
+// geoPackageColumns returns the columns for properties with the given types
+// (sorted by name)
+func geoPackageColumns(types map[string]string) []*gpkg.Column {
+	columns := make([]*gpkg.Column, 0, len(types))
+	for name, valueType := range types {
+		columnType, ok := gpkgColumnTypes[valueType]
+		if !ok {
+			columnType = gpkg.JSON
+		}
+		columns = append(columns, &gpkg.Column{Name: name, Type: columnType})
+	}
+	sort.Slice(columns, func(i, j int) bool {
+		return columns[i].Name < columns[j].Name
+	})
+	return columns
+}
+
+// encodeGeoPackage writes features as a GeoPackage with a table named for
+// the collection.  The database is built in a temporary file and sent when
+// all features have been written.
+func encodeGeoPackage(c echo.Context, format *Format, code int, value interface{}) error {
+	page, ok := value.(*FeaturePage)
+	if !ok {
+		return fmt.Errorf("cannot encode %T as GeoPackage", value)
+	}
+
+	types, typesErr := page.Stream.PropertyTypes()
+	if typesErr != nil {
+		return typesErr
+	}
+
+	definition, definitionErr := page.Stream.SpatialReference(page.CRS.SRID)
+	if definitionErr != nil {
+		return definitionErr
+	}
+
+	if err := page.Open(); err != nil {
+		return err
+	}
+
+	collection := page.Collection
+	writer, writerErr := gpkg.NewWriter(&gpkg.Header{
+		Name:         collection.Name,
+		Title:        collection.Title,
+		Description:  collection.Description,
+		GeometryType: collection.GeometryType,
+		HasZ:         collection.Dimension == 3,
+		Columns:      geoPackageColumns(types),
+		SRID:         page.CRS.SRID,
+		Definition:   definition,
+		LatLon:       page.CRS.LatLon,
+	})
+	if writerErr != nil {
+		return writerErr
+	}
+	defer writer.Close()
+
+	for page.Stream.Next() {
+		feature := &models.Feature{}
+		if err := page.Stream.Scan(feature); err != nil {
+			return err
+		}
+		if err := writer.Add(feature.ID.String(), feature.Geometry.GeoJSON(), feature.Properties); err != nil {
+			return err
+		}
+	}
+	if err := page.Stream.Err(); err != nil {
+		return err
+	}
+
+	response := c.Response()
+	response.Header().Set(echo.HeaderContentType, format.MediaType)
+	response.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", collection.Name+".gpkg"))
+	response.WriteHeader(http.StatusOK)
+	_, err := writer.WriteTo(response)
+	return err
+}
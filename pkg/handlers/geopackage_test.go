@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tschaub/pgfs/pkg/gpkg"
+)
+
+func TestGeoPackageColumns(t *testing.T) {
+	assert := assert.New(t)
+
+	columns := geoPackageColumns(map[string]string{
+		"name":  "string",
+		"rank":  "number",
+		"open":  "boolean",
+		"tags":  "array",
+		"mixed": "mixed",
+	})
+
+	assert.Equal([]*gpkg.Column{
+		{Name: "mixed", Type: gpkg.JSON},
+		{Name: "name", Type: gpkg.Text},
+		{Name: "open", Type: gpkg.Boolean},
+		{Name: "rank", Type: gpkg.Real},
+		{Name: "tags", Type: gpkg.JSON},
+	}, columns)
+}
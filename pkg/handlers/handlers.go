@@ -3,6 +3,7 @@ package handlers
 import (
 	"database/sql"
 	"net/http"
+	"reflect"
 
 	"github.com/labstack/echo"
 	"github.com/labstack/echo/middleware"
@@ -23,8 +24,68 @@ func (v *Validator) Validate(i interface{}) error {
 	return err
 }
 
+// queryNames returns the set of query parameter names bound to a struct
+func queryNames(query interface{}) map[string]bool {
+	names := map[string]bool{}
+	t := reflect.TypeOf(query).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		if name := t.Field(i).Tag.Get("query"); name != "" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// Config holds options for the handlers
+type Config struct {
+	// CursorSecret is used to sign paging cursors.  If empty, a random secret
+	// is used and cursors will not be valid after a restart.
+	CursorSecret []byte
+	// DefaultCount is the number of features listed when no count is given
+	DefaultCount uint64
+	// MaxCount is the largest number of features listed in a single response
+	MaxCount uint64
+	// Formats are the formats available for each resource type (see
+	// DefaultFormats)
+	Formats Formats
+}
+
+const (
+	defaultCount uint64 = 500
+	maxCount     uint64 = 10000
+)
+
+// withDefaults returns a copy of the config with default values for unset options
+func (config Config) withDefaults() (*Config, error) {
+	if len(config.CursorSecret) == 0 {
+		secret, err := newSecret()
+		if err != nil {
+			return nil, err
+		}
+		config.CursorSecret = secret
+	}
+	if config.MaxCount == 0 {
+		config.MaxCount = maxCount
+	}
+	if config.DefaultCount == 0 {
+		config.DefaultCount = defaultCount
+	}
+	if config.DefaultCount > config.MaxCount {
+		config.DefaultCount = config.MaxCount
+	}
+	if config.Formats == nil {
+		config.Formats = DefaultFormats()
+	}
+	return &config, nil
+}
+
 // New creates a new handler
-func New(db *sql.DB) *echo.Echo {
+func New(db *sql.DB, config *Config) (*echo.Echo, error) {
+	config, configErr := config.withDefaults()
+	if configErr != nil {
+		return nil, configErr
+	}
+
 	router := echo.New()
 	router.HideBanner = true
 
@@ -38,24 +99,73 @@ func New(db *sql.DB) *echo.Echo {
 
 	// set up cors
 	router.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderContentLength, echo.HeaderAuthorization},
-		MaxAge:       24 * 60 * 60,
+		AllowHeaders:  []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderContentLength, echo.HeaderAuthorization},
+		ExposeHeaders: []string{"Link"},
+		MaxAge:        24 * 60 * 60,
 	}))
 
+	// landing page
+	router.GET("/", GetLandingPage(config.Formats))
+
+	// conformance classes
+	router.GET("/conformance", GetConformance(config.Formats))
+
+	// API definition
+	router.GET("/api", GetAPI(db, config.Formats))
+
 	// list collections
-	router.GET("/collections", ListCollections(db))
+	router.GET("/collections", ListCollections(db, config.Formats))
 
 	// create new collection
-	router.POST("/collections", CreateCollection(db))
+	router.POST("/collections", CreateCollection(db, config.Formats))
 
 	// get a single collection
-	router.GET("/collections/:name", GetCollection(db))
+	router.GET("/collections/:name", GetCollection(db, config.Formats))
+
+	// update a collection
+	router.PUT("/collections/:name", UpdateCollection(db, config.Formats))
+
+	// delete a collection
+	router.DELETE("/collections/:name", DeleteCollection(db))
+
+	// get the queryable properties of a collection
+	router.GET("/collections/:name/queryables", GetQueryables(db, config.Formats))
+
+	// get the sortable properties of a collection
+	router.GET("/collections/:name/sortables", GetSortables(db, config.Formats))
 
 	// add features to collection
 	router.POST("/collections/:collectionName/items", AddFeatures(db))
 
 	// list features for a collection
-	router.GET("/collections/:collectionName/items", ListFeatures(db))
+	router.GET("/collections/:collectionName/items", ListFeatures(db, config))
+
+	// get a single feature
+	router.GET("/collections/:collectionName/items/:featureId", GetFeature(db, config.Formats))
+
+	// replace a feature
+	router.PUT("/collections/:collectionName/items/:featureId", ReplaceFeature(db))
+
+	// update part of a feature
+	router.PATCH("/collections/:collectionName/items/:featureId", PatchFeature(db))
+
+	// delete a feature
+	router.DELETE("/collections/:collectionName/items/:featureId", DeleteFeature(db))
+
+	// get a vector tile of features in a collection
+	router.GET("/collections/:collectionName/tiles/:tileMatrixSetId/:z/:x/:y", GetCollectionTile(db))
+
+	// get the layers of the dataset vector tiles
+	router.GET("/tiles", GetTileset(db))
+
+	// replace the layers of the dataset vector tiles
+	router.PUT("/tiles", UpdateTileset(db))
+
+	// get a TileJSON document for the dataset vector tiles
+	router.GET("/tiles/:tileMatrixSetId", GetTileJSON(db))
+
+	// get a vector tile with layers for the collections in the tileset
+	router.GET("/tiles/:tileMatrixSetId/:z/:x/:y", GetDatasetTile(db))
 
-	return router
+	return router, nil
 }
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+	_ "github.com/lib/pq" // only works with postgres
+	"github.com/tschaub/pgfs/pkg/models"
+)
+
+// testConnectionEnv names the environment variable with a connection string
+// for a Postgres server with PostGIS.  Integration tests are skipped if unset.
+const testConnectionEnv = "PGFS_TEST_DATABASE"
+
+// testDB creates a disposable database and returns a connection to it along
+// with a function to drop it when done
+func testDB(t *testing.T) (*sql.DB, func()) {
+	connection := os.Getenv(testConnectionEnv)
+	if connection == "" {
+		t.Skipf("set %s to run integration tests", testConnectionEnv)
+	}
+
+	admin, err := sql.Open("postgres", connection)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		t.Fatal(err)
+	}
+	name := "pgfs_test_" + hex.EncodeToString(suffix)
+
+	if _, err := admin.Exec(fmt.Sprintf("CREATE DATABASE %s", name)); err != nil {
+		admin.Close()
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("postgres", withDatabase(t, connection, name))
+	if err != nil {
+		admin.Close()
+		t.Fatal(err)
+	}
+
+	cleanup := func() {
+		db.Close()
+		if _, err := admin.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS %s", name)); err != nil {
+			t.Error(err)
+		}
+		admin.Close()
+	}
+
+	if err := models.Migrate(db); err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+
+	return db, cleanup
+}
+
+// withDatabase returns a connection string (URL or key=value) for another database
+func withDatabase(t *testing.T, connection string, name string) string {
+	if strings.HasPrefix(connection, "postgres://") || strings.HasPrefix(connection, "postgresql://") {
+		u, err := url.Parse(connection)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u.Path = "/" + name
+		return u.String()
+	}
+	return fmt.Sprintf("%s dbname=%s", connection, name)
+}
+
+// testRouter returns the handlers for a test database
+func testRouter(t *testing.T, db *sql.DB) *echo.Echo {
+	router, err := New(db, &Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return router
+}
+
+// serve sends a request to the router and returns the recorded response
+func serve(router *echo.Echo, method string, target string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"sort"
+
+	"github.com/labstack/echo"
+	"github.com/tschaub/pgfs/pkg/geo"
+)
+
+// htmlPage is the data used to render a template
+type htmlPage struct {
+	Base       string
+	Collection string
+	// Map is true if the features can be shown on a map (they are in CRS84)
+	Map   bool
+	Value interface{}
+	// Columns are the property names used in a table of features
+	Columns []string
+}
+
+// htmlFuncs are the functions available in templates
+var htmlFuncs = template.FuncMap{
+	"value": func(v interface{}) string {
+		switch value := v.(type) {
+		case nil:
+			return ""
+		case string:
+			return value
+		default:
+			data, err := json.Marshal(value)
+			if err != nil {
+				return fmt.Sprint(value)
+			}
+			return string(data)
+		}
+	},
+	// geojson encodes a value for use in a script (json.Marshal escapes HTML
+	// characters, so the result is safe in a script element)
+	"geojson": func(v interface{}) (template.JS, error) {
+		data, err := json.Marshal(v)
+		return template.JS(data), err
+	},
+	// bbox returns a GeoJSON polygon for a bounding box
+	"bbox": func(bbox []float64) map[string]interface{} {
+		minX, minY, maxX, maxY := bbox[0], bbox[1], bbox[2], bbox[3]
+		return map[string]interface{}{
+			"type":        "Polygon",
+			"coordinates": [][][]float64{{{minX, minY}, {maxX, minY}, {maxX, maxY}, {minX, maxY}, {minX, minY}}},
+		}
+	},
+}
+
+const htmlLayout = `
+{{define "head"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.}} - pgfs</title>
+<link rel="stylesheet" href="https://unpkg.com/leaflet@1.9.4/dist/leaflet.css">
+<script src="https://unpkg.com/leaflet@1.9.4/dist/leaflet.js"></script>
+<style>
+body { font-family: sans-serif; margin: 0 auto; max-width: 60em; padding: 1em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #ccc; padding: 0.25em 0.5em; text-align: left; }
+#map { height: 400px; margin: 1em 0; }
+nav a { margin-right: 1em; }
+</style>
+</head>
+<body>
+{{end}}
+
+{{define "foot"}}</body>
+</html>
+{{end}}
+
+{{define "links"}}<ul>
+{{range .}}<li><a href="{{.Href}}">{{if .Title}}{{.Title}}{{else}}{{.Rel}}{{end}}</a> ({{.Rel}}{{if .Type}}, {{.Type}}{{end}})</li>
+{{end}}</ul>
+{{end}}
+
+{{define "map"}}<div id="map"></div>
+<script>
+var map = L.map('map');
+L.tileLayer('https://{s}.tile.openstreetmap.org/{z}/{x}/{y}.png', {
+  attribution: '&copy; <a href="https://www.openstreetmap.org/copyright">OpenStreetMap</a> contributors'
+}).addTo(map);
+var layer = L.geoJSON({{geojson .}}, {
+  onEachFeature: function(feature, layer) {
+    if (feature.id) {
+      layer.bindPopup(String(feature.id));
+    }
+  }
+}).addTo(map);
+var bounds = layer.getBounds();
+if (bounds.isValid()) {
+  map.fitBounds(bounds, {maxZoom: 16});
+} else {
+  map.setView([0, 0], 1);
+}
+</script>
+{{end}}
+`
+
+const htmlLandingPage = `
+{{define "landing"}}{{template "head" .Value.Title}}
+<h1>{{.Value.Title}}</h1>
+<p>{{.Value.Description}}</p>
+{{template "links" .Value.Links}}
+{{template "foot"}}{{end}}
+`
+
+const htmlCollectionsPage = `
+{{define "collections"}}{{template "head" "Collections"}}
+<nav><a href="{{.Base}}/">Home</a></nav>
+<h1>Collections</h1>
+<table>
+<tr><th>Title</th><th>Description</th><th>Items</th></tr>
+{{range .Value.Collections}}<tr>
+<td><a href="{{$.Base}}/collections/{{.Name}}">{{.Title}}</a></td>
+<td>{{.Description}}</td>
+<td><a href="{{$.Base}}/collections/{{.Name}}/items">Browse</a></td>
+</tr>
+{{end}}</table>
+{{template "foot"}}{{end}}
+`
+
+const htmlCollectionPage = `
+{{define "collection"}}{{template "head" .Value.Title}}
+<nav><a href="{{.Base}}/">Home</a><a href="{{.Base}}/collections">Collections</a></nav>
+<h1>{{.Value.Title}}</h1>
+<p>{{.Value.Description}}</p>
+<p><a href="{{.Base}}/collections/{{.Value.Name}}/items">Browse the features</a></p>
+{{with .Value.Extent}}{{with .Spatial}}{{range .BBox}}<p>Extent: {{index . 0}}, {{index . 1}}, {{index . 2}}, {{index . 3}}</p>
+{{template "map" (bbox .)}}{{end}}{{end}}{{end}}
+<h2>Links</h2>
+{{template "links" .Value.Links}}
+{{template "foot"}}{{end}}
+`
+
+const htmlItemsPage = `
+{{define "items"}}{{template "head" .Collection}}
+<nav><a href="{{.Base}}/">Home</a><a href="{{.Base}}/collections">Collections</a><a href="{{.Base}}/collections/{{.Collection}}">{{.Collection}}</a></nav>
+<h1>{{.Collection}}</h1>
+<p>Showing {{.Value.NumberReturned}} of {{.Value.NumberMatched}} features.</p>
+<nav>{{range .Value.Links}}{{if or (eq .Rel "prev") (eq .Rel "next")}}<a href="{{.Href}}">{{.Title}}</a>{{end}}{{end}}</nav>
+{{if .Map}}{{template "map" .Value.Features}}{{end}}
+<table>
+<tr><th>id</th>{{range .Columns}}<th>{{.}}</th>{{end}}</tr>
+{{range $feature := .Value.Features}}<tr>
+<td><a href="{{$.Base}}/collections/{{$.Collection}}/items/{{$feature.ID}}">{{$feature.ID}}</a></td>
+{{range $.Columns}}<td>{{value (index $feature.Properties .)}}</td>{{end}}
+</tr>
+{{end}}</table>
+{{template "foot"}}{{end}}
+`
+
+const htmlItemPage = `
+{{define "item"}}{{template "head" .Value.ID}}
+<nav><a href="{{.Base}}/">Home</a><a href="{{.Base}}/collections">Collections</a><a href="{{.Base}}/collections/{{.Collection}}">{{.Collection}}</a><a href="{{.Base}}/collections/{{.Collection}}/items">Items</a></nav>
+<h1>{{.Value.ID}}</h1>
+{{if .Map}}{{template "map" .Value}}{{end}}
+<table>
+{{range .Columns}}<tr><th>{{.}}</th><td>{{value (index $.Value.Properties .)}}</td></tr>
+{{end}}</table>
+{{template "foot"}}{{end}}
+`
+
+var htmlTemplates = template.Must(template.New("").Funcs(htmlFuncs).Parse(
+	htmlLayout + htmlLandingPage + htmlCollectionsPage + htmlCollectionPage + htmlItemsPage + htmlItemPage,
+))
+
+// propertyColumns returns the sorted names of all properties of the features
+func propertyColumns(features []*FeatureInfo) []string {
+	seen := map[string]bool{}
+	columns := []string{}
+	for _, feature := range features {
+		for name := range feature.Properties {
+			if !seen[name] {
+				seen[name] = true
+				columns = append(columns, name)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// encodeHTML returns an encoder that renders a template.  Pages of features
+// are read into memory so they can be shown on a map and in a table.
+func encodeHTML(name string) Encoder {
+	return func(c echo.Context, format *Format, code int, value interface{}) error {
+		page := &htmlPage{
+			Base:       baseURL(c),
+			Collection: c.Param("collectionName"),
+			Value:      value,
+		}
+
+		crs := c.Response().Header().Get(headerContentCRS)
+		page.Map = crs == "" || crs == "<"+geo.CRS84URI+">"
+
+		switch v := value.(type) {
+		case *FeaturePage:
+			if err := v.Open(); err != nil {
+				return err
+			}
+			features, err := readFeatures(v.Stream)
+			if err != nil {
+				return err
+			}
+			list := *v.List
+			list.Features = features
+			page.Value = &list
+			page.Columns = propertyColumns(features)
+		case *FeatureInfo:
+			page.Columns = propertyColumns([]*FeatureInfo{v})
+		}
+
+		buffer := &bytes.Buffer{}
+		if err := htmlTemplates.ExecuteTemplate(buffer, name, page); err != nil {
+			return err
+		}
+		return c.HTMLBlob(code, buffer.Bytes())
+	}
+}
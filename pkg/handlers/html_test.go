@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/tschaub/pgfs/pkg/geo"
+)
+
+func TestGetLandingPageHTML(t *testing.T) {
+	assert := assert.New(t)
+
+	router := echo.New()
+	router.GET("/", GetLandingPage(DefaultFormats()))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(echo.GET, "/?f=html", nil)
+	req.Host = "example.com"
+	router.ServeHTTP(rec, req)
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.Equal(echo.MIMETextHTMLCharsetUTF8, rec.Header().Get(echo.HeaderContentType))
+	assert.Contains(rec.Body.String(), "<h1>pgfs</h1>")
+	assert.Contains(rec.Body.String(), `<a href="http://example.com/collections">`)
+}
+
+func TestLandingPageLinks(t *testing.T) {
+	assert := assert.New(t)
+
+	router := echo.New()
+	router.GET("/", GetLandingPage(DefaultFormats()))
+
+	types := func(target string) map[string]string {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(echo.GET, target, nil)
+		req.Host = "example.com"
+		router.ServeHTTP(rec, req)
+		info := &LandingPageInfo{}
+		if !assert.Nil(json.Unmarshal(rec.Body.Bytes(), info), target) {
+			return nil
+		}
+		types := map[string]string{}
+		for _, link := range info.Links {
+			types[link.Href] = link.Type
+		}
+		return types
+	}
+
+	assert.Equal(map[string]string{
+		"http://example.com/":            echo.MIMEApplicationJSON,
+		"http://example.com/?f=html":     echo.MIMETextHTML,
+		"http://example.com/api":         mimeOpenAPI,
+		"http://example.com/conformance": echo.MIMEApplicationJSON,
+		"http://example.com/collections": echo.MIMEApplicationJSON,
+		"http://example.com/tiles":       echo.MIMEApplicationJSON,
+	}, types("/"))
+
+	// the collection links have the types of the formats configured for them
+	formats := DefaultFormats()
+	formats.Register(ResourceCollections, &Format{Name: "json", MediaType: "application/vnd.example+json", Encode: encodeJSON})
+	router.GET("/custom", GetLandingPage(formats))
+	assert.Equal("application/vnd.example+json", types("/custom")["http://example.com/collections"])
+}
+
+func TestConformanceHTML(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Contains(conformance(DefaultFormats()), conformanceHTML)
+
+	formats := DefaultFormats()
+	delete(formats, ResourceLanding)
+	for _, resource := range []string{ResourceCollections, ResourceCollection, ResourceItems, ResourceItem} {
+		formats[resource] = formats[resource][:len(formats[resource])-1]
+	}
+	assert.NotContains(conformance(formats), conformanceHTML)
+}
+
+func TestEncodeFeatureHTML(t *testing.T) {
+	assert := assert.New(t)
+
+	geometry := geo.Geometry{}
+	assert.Nil(geometry.UnmarshalJSON([]byte(`{"type":"Point","coordinates":[1,2]}`)))
+	id := uuid.New()
+	feature := &FeatureInfo{
+		Type:       "Feature",
+		ID:         id,
+		Geometry:   geometry,
+		Properties: map[string]interface{}{"name": "<b>Paris</b>", "rank": 1},
+	}
+
+	req := httptest.NewRequest(echo.GET, "/collections/cities/items/"+id.String(), nil)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.SetParamNames("collectionName", "featureId")
+	c.SetParamValues("cities", id.String())
+
+	format := &Format{Name: "html", MediaType: echo.MIMETextHTML, Encode: encodeHTML("item")}
+	assert.Nil(format.Encode(c, format, http.StatusOK, feature))
+
+	body := rec.Body.String()
+	assert.Contains(body, "<h1>"+id.String()+"</h1>")
+	assert.Contains(body, "<th>name</th><td>&lt;b&gt;Paris&lt;/b&gt;</td>")
+	assert.Contains(body, "<th>rank</th><td>1</td>")
+	assert.Contains(body, `"geometry":{"type":"Point","coordinates":[1,2]}`)
+	assert.Contains(body, `"name":"\u003cb\u003eParis\u003c/b\u003e"`)
+	assert.True(strings.Index(body, "<th>name</th>") < strings.Index(body, "<th>rank</th>"))
+}
+
+func TestPropertyColumns(t *testing.T) {
+	features := []*FeatureInfo{
+		{Properties: map[string]interface{}{"b": 1, "a": 2}},
+		{Properties: map[string]interface{}{"c": 3, "a": 4}},
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, propertyColumns(features))
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/labstack/echo"
+)
+
+// LandingPageInfo describes the service and links to its resources
+type LandingPageInfo struct {
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	Links       []*Link `json:"links"`
+}
+
+// ConformanceInfo lists the conformance classes implemented by the service
+type ConformanceInfo struct {
+	ConformsTo []string `json:"conformsTo"`
+}
+
+// Conformance classes implemented by formats
+const (
+	conformanceGeoJSON = "http://www.opengis.net/spec/ogcapi-features-1/1.0/conf/geojson"
+	conformanceHTML    = "http://www.opengis.net/spec/ogcapi-features-1/1.0/conf/html"
+)
+
+// conformanceClasses are implemented by the routes of the service.  Classes
+// for representations are declared by the formats that implement them.
+var conformanceClasses = []string{
+	"http://www.opengis.net/spec/ogcapi-features-1/1.0/conf/core",
+	"http://www.opengis.net/spec/ogcapi-features-1/1.0/conf/oas30",
+	"http://www.opengis.net/spec/ogcapi-features-2/1.0/conf/crs",
+	"http://www.opengis.net/spec/ogcapi-features-3/1.0/conf/queryables",
+	"http://www.opengis.net/spec/ogcapi-features-3/1.0/conf/queryables-query-parameters",
+	"http://www.opengis.net/spec/ogcapi-features-3/1.0/conf/filter",
+	"http://www.opengis.net/spec/ogcapi-features-3/1.0/conf/features-filter",
+	"http://www.opengis.net/spec/ogcapi-features-4/1.0/conf/create-replace-delete",
+	"http://www.opengis.net/spec/ogcapi-features-4/1.0/conf/update",
+	"http://www.opengis.net/spec/ogcapi-tiles-1/1.0/conf/core",
+	"http://www.opengis.net/spec/ogcapi-tiles-1/1.0/conf/mvt",
+	"http://www.opengis.net/spec/cql2/1.0/conf/cql2-text",
+	"http://www.opengis.net/spec/cql2/1.0/conf/cql2-json",
+	"http://www.opengis.net/spec/cql2/1.0/conf/basic-cql2",
+	"http://www.opengis.net/spec/cql2/1.0/conf/advanced-comparison-operators",
+	"http://www.opengis.net/spec/cql2/1.0/conf/basic-spatial-functions",
+	"http://www.opengis.net/spec/cql2/1.0/conf/basic-spatial-functions-plus",
+	"http://www.opengis.net/spec/cql2/1.0/conf/spatial-functions",
+	"http://www.opengis.net/spec/cql2/1.0/conf/temporal-functions",
+}
+
+// GetLandingPage responds with links to the API definition, conformance, and collections
+func GetLandingPage(formats Formats) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		format, formatErr := formats.negotiate(c, ResourceLanding)
+		if formatErr != nil {
+			return formatErr
+		}
+
+		base := baseURL(c)
+		info := &LandingPageInfo{
+			Title:       "pgfs",
+			Description: "Postgres backed WFS 3",
+			Links: append(
+				formats.selfLinks(base+"/", ResourceLanding, format.Name, "This document"),
+				formats.link(base+"/api", "service-desc", ResourceAPI, "The API definition"),
+				formats.link(base+"/conformance", "conformance", ResourceConformance, "Conformance classes implemented by this service"),
+				formats.link(base+"/collections", "data", ResourceCollections, "Feature collections"),
+				&Link{Href: base + "/tiles", Rel: "http://www.opengis.net/def/rel/ogc/1.0/tilesets-vector", Type: echo.MIMEApplicationJSON, Title: "Vector tiles"},
+			),
+		}
+
+		return format.Encode(c, format, http.StatusOK, info)
+	}
+}
+
+// conformance returns the conformance classes implemented by the service
+// with the given formats
+func conformance(formats Formats) []string {
+	seen := map[string]bool{}
+	classes := []string{}
+	for _, resourceFormats := range formats {
+		for _, format := range resourceFormats {
+			for _, class := range format.Conformance {
+				if !seen[class] {
+					seen[class] = true
+					classes = append(classes, class)
+				}
+			}
+		}
+	}
+	sort.Strings(classes)
+	return append(append([]string{}, conformanceClasses...), classes...)
+}
+
+// GetConformance responds with the conformance classes implemented by the service
+func GetConformance(formats Formats) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		format, formatErr := formats.negotiate(c, ResourceConformance)
+		if formatErr != nil {
+			return formatErr
+		}
+
+		return format.Encode(c, format, http.StatusOK, &ConformanceInfo{ConformsTo: conformance(formats)})
+	}
+}
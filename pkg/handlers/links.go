@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+// Link is a relation to another resource
+type Link struct {
+	Href  string `json:"href"`
+	Rel   string `json:"rel"`
+	Type  string `json:"type,omitempty"`
+	Title string `json:"title,omitempty"`
+}
+
+const (
+	mimeGeoJSON = "application/geo+json"
+	mimeOpenAPI = "application/vnd.oai.openapi+json;version=3.0"
+	mimeSchema  = "application/schema+json"
+)
+
+// link returns a link to a resource with the media type of its default format
+func (formats Formats) link(href string, rel string, resource string, title string) *Link {
+	link := &Link{Href: href, Rel: rel, Title: title}
+	if resourceFormats := formats[resource]; len(resourceFormats) > 0 {
+		link.Type = resourceFormats[0].MediaType
+	}
+	return link
+}
+
+// selfLinks returns a self link to a resource in the named format (or the
+// default format if the resource has no such format) and alternate links to
+// the resource in its other formats
+func (formats Formats) selfLinks(href string, resource string, name string, title string) []*Link {
+	resourceFormats := formats[resource]
+	if len(resourceFormats) == 0 {
+		return []*Link{{Href: href, Rel: "self", Title: title}}
+	}
+
+	current := resourceFormats[0]
+	for _, format := range resourceFormats {
+		if format.Name == name {
+			current = format
+		}
+	}
+
+	links := []*Link{{Href: href, Rel: "self", Type: current.MediaType, Title: title}}
+	for _, format := range resourceFormats {
+		if format != current {
+			links = append(links, &Link{Href: href + "?f=" + url.QueryEscape(format.Name), Rel: "alternate", Type: format.MediaType, Title: fmt.Sprintf("%s (%s)", title, format.Name)})
+		}
+	}
+	return links
+}
+
+// baseURL returns the scheme and host used to make the request
+func baseURL(c echo.Context) string {
+	return c.Scheme() + "://" + c.Request().Host
+}
+
+// typedJSON responds with JSON using a specific media type
+func typedJSON(c echo.Context, code int, contentType string, i interface{}) error {
+	data, err := json.Marshal(i)
+	if err != nil {
+		return err
+	}
+	return c.Blob(code, contentType, data)
+}
+
+// requestHref returns the URL of the request with a different query string
+func requestHref(c echo.Context, query url.Values) string {
+	href := baseURL(c) + c.Request().URL.Path
+	if encoded := query.Encode(); encoded != "" {
+		href += "?" + encoded
+	}
+	return href
+}
+
+// linkHeader encodes links as the value of an HTTP Link header (RFC 8288)
+func linkHeader(links []*Link) string {
+	values := make([]string, len(links))
+	for i, link := range links {
+		value := fmt.Sprintf("<%s>; rel=\"%s\"", link.Href, link.Rel)
+		if link.Type != "" {
+			value += fmt.Sprintf("; type=\"%s\"", link.Type)
+		}
+		if link.Title != "" {
+			value += fmt.Sprintf("; title=\"%s\"", strings.Replace(link.Title, "\"", "\\\"", -1))
+		}
+		values[i] = value
+	}
+	return strings.Join(values, ", ")
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/tschaub/pgfs/pkg/models"
+)
+
+func TestLinkHeader(t *testing.T) {
+	assert := assert.New(t)
+
+	links := []*Link{
+		{Href: "http://example.com/items?after=a", Rel: "next", Type: mimeGeoJSON},
+		{Href: "http://example.com/items?before=b", Rel: "prev", Title: `The "previous" page`},
+	}
+
+	assert.Equal(
+		`<http://example.com/items?after=a>; rel="next"; type="application/geo+json", `+
+			`<http://example.com/items?before=b>; rel="prev"; title="The \"previous\" page"`,
+		linkHeader(links),
+	)
+	assert.Equal("", linkHeader(nil))
+}
+
+func TestPageLinks(t *testing.T) {
+	secret := []byte("secret")
+	first := &models.Feature{ID: uuid.New(), SortValues: models.SortValues{json.RawMessage(`"a"`)}}
+	last := &models.Feature{ID: uuid.New(), SortValues: models.SortValues{json.RawMessage(`"b"`)}}
+
+	cursor := func(query *models.FeatureQuery, feature *models.Feature, backward bool) string {
+		token, err := encodeCursor(secret, query, feature.SortValues, backward)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	firstPage := &models.FeatureQuery{}
+	afterPage := &models.FeatureQuery{After: first}
+	beforePage := &models.FeatureQuery{Before: last}
+	forwardPage := &models.FeatureQuery{Cursor: &models.Cursor{Values: first.SortValues}}
+	backwardPage := &models.FeatureQuery{Cursor: &models.Cursor{Values: last.SortValues, Backward: true}}
+
+	cases := []struct {
+		name   string
+		target string
+		query  *models.FeatureQuery
+		more   bool
+		rels   map[string]string
+	}{
+		{
+			name:   "first page",
+			target: "/items?count=2",
+			query:  firstPage,
+			more:   true,
+			rels: map[string]string{
+				"self": "http://example.com/items?count=2",
+				"next": "http://example.com/items?count=2&cursor=" + cursor(firstPage, last, false),
+			},
+		},
+		{
+			name:   "last page after a feature",
+			target: "/items?count=2&after=" + first.ID.String(),
+			query:  afterPage,
+			more:   false,
+			rels: map[string]string{
+				"self": "http://example.com/items?after=" + first.ID.String() + "&count=2",
+				"prev": "http://example.com/items?count=2&cursor=" + cursor(afterPage, first, true),
+			},
+		},
+		{
+			name:   "before a feature",
+			target: "/items?before=" + last.ID.String(),
+			query:  beforePage,
+			more:   true,
+			rels: map[string]string{
+				"self": "http://example.com/items?before=" + last.ID.String(),
+				"next": "http://example.com/items?cursor=" + cursor(beforePage, last, false),
+				"prev": "http://example.com/items?cursor=" + cursor(beforePage, first, true),
+			},
+		},
+		{
+			name:   "forward cursor",
+			target: "/items?cursor=x",
+			query:  forwardPage,
+			more:   true,
+			rels: map[string]string{
+				"self": "http://example.com/items?cursor=x",
+				"next": "http://example.com/items?cursor=" + cursor(forwardPage, last, false),
+				"prev": "http://example.com/items?cursor=" + cursor(forwardPage, first, true),
+			},
+		},
+		{
+			name:   "backward cursor at the start",
+			target: "/items?cursor=x",
+			query:  backwardPage,
+			more:   false,
+			rels: map[string]string{
+				"self": "http://example.com/items?cursor=x",
+				"next": "http://example.com/items?cursor=" + cursor(backwardPage, last, false),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, c.target, nil)
+		req.Host = "example.com"
+		ctx := echo.New().NewContext(req, httptest.NewRecorder())
+
+		page := &models.Page{Returned: 2, More: c.more, First: first.SortValues, Last: last.SortValues}
+		links, err := pageLinks(ctx, page, c.query, secret, mimeGeoJSON)
+		if !assert.Nil(t, err, c.name) {
+			continue
+		}
+		rels := map[string]string{}
+		for _, link := range links {
+			rels[link.Rel] = link.Href
+		}
+		assert.Equal(t, c.rels, rels, c.name)
+	}
+}
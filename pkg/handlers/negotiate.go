@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+	"github.com/tschaub/pgfs/pkg/flatgeobuf"
+	"github.com/tschaub/pgfs/pkg/geo"
+	"github.com/tschaub/pgfs/pkg/gpkg"
+	"github.com/tschaub/pgfs/pkg/models"
+)
+
+// Resource types with formats that can be negotiated
+const (
+	ResourceLanding     = "landing"
+	ResourceConformance = "conformance"
+	ResourceAPI         = "api"
+	ResourceCollections = "collections"
+	ResourceCollection  = "collection"
+	ResourceQueryables  = "queryables"
+	ResourceSortables   = "sortables"
+	ResourceItems       = "items"
+	ResourceItem        = "item"
+)
+
+// Encoder writes a value to the response in a format.  For items, the value
+// is a *FeaturePage (which must be opened before reading its list or writing
+// the response status).  For other resources, it is the struct that would
+// otherwise be encoded as JSON.
+type Encoder func(c echo.Context, format *Format, code int, value interface{}) error
+
+// Format is a representation of a resource
+type Format struct {
+	// Name is the value of the f parameter that selects the format
+	Name string
+	// MediaType is the Content-Type of the response
+	MediaType string
+	// Aliases are other media types in an Accept header that select the format
+	Aliases []string
+	// Encode writes the response
+	Encode Encoder
+	// Export is true for item formats that include all matching features
+	// when no count or page position is requested
+	Export bool
+	// Conformance lists the conformance classes implemented by the format
+	Conformance []string
+}
+
+// FeaturePage is a page of features to be encoded.  The list has everything
+// but the features, which are read from the stream.  The list is complete
+// once the page is opened.
+type FeaturePage struct {
+	List       *FeatureList
+	Stream     *models.FeatureStream
+	Collection *models.Collection
+	// CRS is the coordinate reference system of the feature geometries
+	CRS geo.CRS
+	// open completes the list and response headers after opening the stream
+	open func() error
+}
+
+// Open opens the stream and completes the list with a description of the
+// page.  Encoders call this before writing the response status (and after any
+// other queries of the stream), so errors in the feature query are returned
+// as usual.
+func (page *FeaturePage) Open() error {
+	if page.open == nil {
+		return page.Stream.Open()
+	}
+	return page.open()
+}
+
+// Formats lists the formats available for each resource type.  The first
+// format registered for a resource is the default.
+type Formats map[string][]*Format
+
+// Register adds a format for a resource type, replacing any existing format
+// with the same name
+func (formats Formats) Register(resource string, format *Format) {
+	for i, existing := range formats[resource] {
+		if existing.Name == format.Name {
+			formats[resource][i] = format
+			return
+		}
+	}
+	formats[resource] = append(formats[resource], format)
+}
+
+// mediaRange is an entry in an Accept header
+type mediaRange struct {
+	mediaType string
+	quality   float64
+}
+
+// parseAccept returns the media ranges in an Accept header ordered by
+// quality.  Ranges with zero quality are excluded.
+func parseAccept(accept string) []*mediaRange {
+	ranges := []*mediaRange{}
+	for _, value := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(value))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			parsed, parseErr := strconv.ParseFloat(q, 64)
+			if parseErr != nil {
+				continue
+			}
+			quality = parsed
+		}
+		if quality <= 0 {
+			continue
+		}
+		ranges = append(ranges, &mediaRange{mediaType: mediaType, quality: quality})
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].quality > ranges[j].quality
+	})
+	return ranges
+}
+
+// matches is true if the media range includes one of the format's media types
+func (r *mediaRange) matches(format *Format) bool {
+	if r.mediaType == "*/*" {
+		return true
+	}
+	for _, mediaType := range append([]string{format.MediaType}, format.Aliases...) {
+		if base, _, err := mime.ParseMediaType(mediaType); err == nil {
+			mediaType = base
+		}
+		if r.mediaType == mediaType {
+			return true
+		}
+		if strings.HasSuffix(r.mediaType, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(r.mediaType, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiate selects the format for a response.  The f parameter takes
+// precedence over the Accept header.  If neither is given, the default
+// format is used.  A 406 error is returned if no format is acceptable.
+func (formats Formats) negotiate(c echo.Context, resource string) (*Format, error) {
+	available := formats[resource]
+	if len(available) == 0 {
+		return nil, fmt.Errorf("no formats registered for %s", resource)
+	}
+
+	c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+
+	if name := c.QueryParam("f"); name != "" {
+		for _, format := range available {
+			if format.Name == name {
+				return format, nil
+			}
+		}
+		return nil, notAcceptable(available)
+	}
+
+	accept := c.Request().Header.Get(echo.HeaderAccept)
+	if accept == "" {
+		return available[0], nil
+	}
+
+	for _, r := range parseAccept(accept) {
+		for _, format := range available {
+			if r.matches(format) {
+				return format, nil
+			}
+		}
+	}
+
+	return nil, notAcceptable(available)
+}
+
+// notAcceptable returns a 406 error listing the available formats
+func notAcceptable(available []*Format) error {
+	names := make([]string, len(available))
+	for i, format := range available {
+		names[i] = fmt.Sprintf("%s (%s)", format.Name, format.MediaType)
+	}
+	return echo.NewHTTPError(http.StatusNotAcceptable, fmt.Sprintf("supported formats are %s", strings.Join(names, ", ")))
+}
+
+// encodeJSON writes a value as JSON with the media type of the format
+func encodeJSON(c echo.Context, format *Format, code int, value interface{}) error {
+	return typedJSON(c, code, format.MediaType, value)
+}
+
+// encodeFeatureList streams a page of features as a GeoJSON FeatureCollection
+func encodeFeatureList(c echo.Context, format *Format, code int, value interface{}) error {
+	page, ok := value.(*FeaturePage)
+	if !ok {
+		return fmt.Errorf("cannot encode %T as a feature list", value)
+	}
+	if err := page.Open(); err != nil {
+		return err
+	}
+	return streamFeatureList(c, format.MediaType, page.List, page.Stream)
+}
+
+// encodeFeatureSeq streams a page of features as a sequence of GeoJSON texts
+func encodeFeatureSeq(c echo.Context, format *Format, code int, value interface{}) error {
+	page, ok := value.(*FeaturePage)
+	if !ok {
+		return fmt.Errorf("cannot encode %T as a feature sequence", value)
+	}
+	if err := page.Open(); err != nil {
+		return err
+	}
+	return streamFeatureSeq(c, format.MediaType, page.Stream)
+}
+
+// DefaultFormats returns the formats available for each resource type
+func DefaultFormats() Formats {
+	formats := Formats{}
+
+	for _, resource := range []string{ResourceLanding, ResourceConformance, ResourceCollections, ResourceCollection} {
+		formats.Register(resource, &Format{Name: "json", MediaType: echo.MIMEApplicationJSON, Encode: encodeJSON})
+	}
+
+	formats.Register(ResourceAPI, &Format{Name: "json", MediaType: mimeOpenAPI, Aliases: []string{echo.MIMEApplicationJSON}, Encode: encodeJSON})
+
+	for _, resource := range []string{ResourceQueryables, ResourceSortables} {
+		formats.Register(resource, &Format{Name: "json", MediaType: mimeSchema, Aliases: []string{echo.MIMEApplicationJSON}, Encode: encodeJSON})
+	}
+
+	formats.Register(ResourceItems, &Format{Name: "json", MediaType: mimeGeoJSON, Aliases: []string{echo.MIMEApplicationJSON}, Encode: encodeFeatureList, Conformance: []string{conformanceGeoJSON}})
+	formats.Register(ResourceItems, &Format{Name: "jsonseq", MediaType: mimeGeoJSONSeq, Encode: encodeFeatureSeq})
+	formats.Register(ResourceItems, &Format{Name: "ndjson", MediaType: mimeNDJSON, Aliases: []string{"application/ndjson"}, Encode: encodeFeatureSeq})
+	formats.Register(ResourceItems, &Format{Name: "flatgeobuf", MediaType: flatgeobuf.MediaType, Encode: encodeFlatGeobuf, Export: true})
+	formats.Register(ResourceItems, &Format{Name: "csv", MediaType: mimeCSV, Encode: encodeCSV, Export: true})
+	formats.Register(ResourceItems, &Format{Name: "gpkg", MediaType: gpkg.MediaType, Encode: encodeGeoPackage, Export: true})
+
+	formats.Register(ResourceItem, &Format{Name: "json", MediaType: mimeGeoJSON, Aliases: []string{echo.MIMEApplicationJSON}, Encode: encodeJSON})
+
+	html := []string{conformanceHTML}
+	formats.Register(ResourceLanding, &Format{Name: "html", MediaType: echo.MIMETextHTML, Encode: encodeHTML("landing"), Conformance: html})
+	formats.Register(ResourceCollections, &Format{Name: "html", MediaType: echo.MIMETextHTML, Encode: encodeHTML("collections"), Conformance: html})
+	formats.Register(ResourceCollection, &Format{Name: "html", MediaType: echo.MIMETextHTML, Encode: encodeHTML("collection"), Conformance: html})
+	formats.Register(ResourceItems, &Format{Name: "html", MediaType: echo.MIMETextHTML, Encode: encodeHTML("items"), Conformance: html})
+	formats.Register(ResourceItem, &Format{Name: "html", MediaType: echo.MIMETextHTML, Encode: encodeHTML("item"), Conformance: html})
+
+	return formats
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAccept(t *testing.T) {
+	assert := assert.New(t)
+
+	ranges := parseAccept("text/html;q=0.5, application/geo+json, */*;q=0.1, image/png;q=0")
+	types := []string{}
+	for _, r := range ranges {
+		types = append(types, r.mediaType)
+	}
+	assert.Equal([]string{"application/geo+json", "text/html", "*/*"}, types)
+	assert.Len(parseAccept(""), 0)
+}
+
+func TestNegotiate(t *testing.T) {
+	formats := DefaultFormats()
+
+	cases := []struct {
+		name     string
+		target   string
+		accept   string
+		resource string
+		format   string
+		status   int
+	}{
+		{name: "default", target: "/items", resource: ResourceItems, format: "json"},
+		{name: "f parameter", target: "/items?f=ndjson", accept: mimeGeoJSONSeq, resource: ResourceItems, format: "ndjson"},
+		{name: "accept", target: "/items", accept: "application/geo+json-seq", resource: ResourceItems, format: "jsonseq"},
+		{name: "alias", target: "/items", accept: "application/json", resource: ResourceItems, format: "json"},
+		{name: "quality", target: "/items", accept: "application/x-ndjson;q=0.5, application/geo+json", resource: ResourceItems, format: "json"},
+		{name: "wildcard", target: "/conformance", accept: "text/html, */*;q=0.8", resource: ResourceConformance, format: "json"},
+		{name: "html", target: "/", accept: "text/html,application/xhtml+xml,*/*;q=0.8", resource: ResourceLanding, format: "html"},
+		{name: "subtype wildcard", target: "/", accept: "application/*", resource: ResourceLanding, format: "json"},
+		{name: "unsupported accept", target: "/conformance", accept: "text/html", resource: ResourceConformance, status: http.StatusNotAcceptable},
+		{name: "unsupported f", target: "/items?f=xml", resource: ResourceItems, status: http.StatusNotAcceptable},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, c.target, nil)
+		if c.accept != "" {
+			req.Header.Set(echo.HeaderAccept, c.accept)
+		}
+		rec := httptest.NewRecorder()
+		ctx := echo.New().NewContext(req, rec)
+
+		format, err := formats.negotiate(ctx, c.resource)
+		if c.status != 0 {
+			if httpErr, ok := err.(*echo.HTTPError); assert.True(t, ok, c.name) {
+				assert.Equal(t, c.status, httpErr.Code, c.name)
+			}
+			continue
+		}
+		if assert.Nil(t, err, c.name) {
+			assert.Equal(t, c.format, format.Name, c.name)
+		}
+		assert.Equal(t, echo.HeaderAccept, rec.Header().Get(echo.HeaderVary), c.name)
+	}
+}
+
+func TestFormatsRegister(t *testing.T) {
+	assert := assert.New(t)
+
+	formats := Formats{}
+	formats.Register(ResourceLanding, &Format{Name: "json", MediaType: "application/json"})
+	formats.Register(ResourceLanding, &Format{Name: "text", MediaType: "text/plain"})
+	formats.Register(ResourceLanding, &Format{Name: "json", MediaType: "application/vnd+json"})
+
+	if assert.Len(formats[ResourceLanding], 2) {
+		assert.Equal("application/vnd+json", formats[ResourceLanding][0].MediaType)
+		assert.Equal("text", formats[ResourceLanding][1].Name)
+	}
+}
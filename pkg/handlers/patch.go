@@ -0,0 +1,29 @@
+package handlers
+
+// mergePatch applies a JSON merge patch (RFC 7396) to a decoded JSON value
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+
+	result := make(map[string]interface{}, len(targetObject))
+	for key, value := range targetObject {
+		result[key] = value
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(result, key)
+			continue
+		}
+		result[key] = mergePatch(result[key], value)
+	}
+
+	return result
+}
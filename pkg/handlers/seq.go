@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+
+	"github.com/labstack/echo"
+	"github.com/tschaub/pgfs/pkg/geo"
+	"github.com/tschaub/pgfs/pkg/models"
+)
+
+const (
+	// mimeGeoJSONSeq is a sequence of GeoJSON texts (RFC 8142)
+	mimeGeoJSONSeq = "application/geo+json-seq"
+	// mimeNDJSON is newline delimited JSON
+	mimeNDJSON = "application/x-ndjson"
+
+	recordSeparator = "\x1e"
+)
+
+// seqTypes are the media types used for feature sequences
+var seqTypes = map[string]string{
+	mimeGeoJSONSeq:       mimeGeoJSONSeq,
+	mimeNDJSON:           mimeNDJSON,
+	"application/ndjson": mimeNDJSON,
+}
+
+// seqType returns the sequence media type for a Content-Type or Accept value
+// (or "" if it is not a sequence type)
+func seqType(value string) string {
+	mediaType, _, err := mime.ParseMediaType(value)
+	if err != nil {
+		return ""
+	}
+	return seqTypes[mediaType]
+}
+
+// featureSeqReader reads GeoJSON texts from a sequence.  Each text ends with a
+// newline and may start with a record separator.  Blank lines are ignored.
+type featureSeqReader struct {
+	reader *bufio.Reader
+	line   int
+}
+
+func newFeatureSeqReader(r io.Reader) *featureSeqReader {
+	return &featureSeqReader{reader: bufio.NewReader(r)}
+}
+
+// Read decodes the next feature into info, returning io.EOF at the end of
+// the sequence
+func (r *featureSeqReader) Read(info *NewFeatureInfo) error {
+	for {
+		data, readErr := r.reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+		r.line++
+
+		data = bytes.TrimSpace(bytes.TrimLeft(data, recordSeparator))
+		if len(data) == 0 {
+			if readErr == io.EOF {
+				return io.EOF
+			}
+			continue
+		}
+
+		if err := json.Unmarshal(data, info); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bad feature on line %d: %s", r.line, err))
+		}
+		return nil
+	}
+}
+
+// seqBatchSize is the number of features inserted at a time from a sequence
+const seqBatchSize = 1000
+
+// addFeatureSeq adds features read one at a time from a sequence in the
+// request body.  Features are inserted in batches, but nothing is saved if
+// any feature is rejected.  The whole sequence is read so that every rejected
+// feature is reported.  Successful responses report the number of features
+// added and only list those with invalid or repaired geometries, so the
+// response does not grow with the input.
+func addFeatureSeq(c echo.Context, db *sql.DB, collection *models.Collection, crs geo.CRS) error {
+	inserter, inserterErr := models.NewFeatureInserter(db)
+	if inserterErr != nil {
+		return inserterErr
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			inserter.Rollback()
+		}
+	}()
+
+	geometryErr := &models.GeometryError{}
+	summary := &FeatureSeqResult{Features: []*FeatureResult{}}
+	batch := models.Features{}
+	// indexes are the positions of batched features in the sequence
+	indexes := []int{}
+
+	insert := func() error {
+		var err error
+		if len(geometryErr.Features) > 0 {
+			// nothing will be saved, but later features are still checked
+			err = inserter.Check(batch)
+		} else {
+			err = inserter.Insert(batch)
+		}
+		if err != nil {
+			batchErr, ok := err.(*models.GeometryError)
+			if !ok {
+				return err
+			}
+			for _, featureErr := range batchErr.Features {
+				geometryErr.Features = append(geometryErr.Features, &models.FeatureError{Index: indexes[featureErr.Index], Reason: featureErr.Reason})
+			}
+		} else if len(geometryErr.Features) == 0 {
+			for i, feature := range batch {
+				result := resultFromFeature(indexes[i], feature)
+				if !result.Valid {
+					summary.Features = append(summary.Features, result)
+				}
+			}
+			summary.Count += len(batch)
+		}
+		batch = models.Features{}
+		indexes = []int{}
+		return nil
+	}
+
+	reader := newFeatureSeqReader(c.Request().Body)
+	for index := 0; ; index++ {
+		info := &NewFeatureInfo{}
+		readErr := reader.Read(info)
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+
+		if err := checkFeatureGeometry(collection, info); err != nil {
+			geometryErr.Features = append(geometryErr.Features, &models.FeatureError{Index: index, Reason: err.Error()})
+			continue
+		}
+
+		batch = append(batch, newFeature(collection, info, crs))
+		indexes = append(indexes, index)
+		if len(batch) == seqBatchSize {
+			if err := insert(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := insert(); err != nil {
+			return err
+		}
+	}
+
+	if len(geometryErr.Features) > 0 {
+		sort.Slice(geometryErr.Features, func(i, j int) bool {
+			return geometryErr.Features[i].Index < geometryErr.Features[j].Index
+		})
+		return unprocessable(geometryErr)
+	}
+
+	if err := inserter.Commit(); err != nil {
+		return err
+	}
+	committed = true
+
+	return c.JSON(http.StatusOK, summary)
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/tschaub/pgfs/pkg/models"
+)
+
+func TestFeatureSeqReader(t *testing.T) {
+	assert := assert.New(t)
+
+	point := `{"type":"Feature","geometry":{"type":"Point","coordinates":[1,2]},"properties":{"name":"%s"}}`
+	cases := []struct {
+		name  string
+		input string
+		names []string
+	}{
+		{
+			name:  "geojson text sequence",
+			input: "\x1e" + strings.Replace(point, "%s", "a", 1) + "\n\x1e" + strings.Replace(point, "%s", "b", 1) + "\n",
+			names: []string{"a", "b"},
+		},
+		{
+			name:  "newline delimited with blank lines",
+			input: strings.Replace(point, "%s", "a", 1) + "\n\n" + strings.Replace(point, "%s", "b", 1) + "\r\n\n",
+			names: []string{"a", "b"},
+		},
+		{
+			name:  "no final newline",
+			input: strings.Replace(point, "%s", "a", 1),
+			names: []string{"a"},
+		},
+		{
+			name:  "empty",
+			input: "",
+			names: []string{},
+		},
+	}
+
+	for _, c := range cases {
+		reader := newFeatureSeqReader(strings.NewReader(c.input))
+		names := []string{}
+		for {
+			info := &NewFeatureInfo{}
+			err := reader.Read(info)
+			if err == io.EOF {
+				break
+			}
+			if !assert.Nil(err, c.name) {
+				break
+			}
+			names = append(names, info.Properties["name"].(string))
+		}
+		assert.Equal(c.names, names, c.name)
+	}
+
+	reader := newFeatureSeqReader(strings.NewReader(strings.Replace(point, "%s", "a", 1) + "\nnot json\n"))
+	assert.Nil(reader.Read(&NewFeatureInfo{}))
+	err := reader.Read(&NewFeatureInfo{})
+	if httpErr, ok := err.(*echo.HTTPError); assert.True(ok) {
+		assert.Equal(http.StatusBadRequest, httpErr.Code)
+		assert.Contains(httpErr.Message, "line 2")
+	}
+}
+
+func TestSeqType(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(mimeGeoJSONSeq, seqType("application/geo+json-seq"))
+	assert.Equal(mimeNDJSON, seqType("application/x-ndjson; charset=utf-8"))
+	assert.Equal(mimeNDJSON, seqType("application/ndjson"))
+	assert.Equal("", seqType("application/json"))
+	assert.Equal("", seqType(""))
+
+}
+
+func TestAddFeatureSeq(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	collection := &models.Collection{Name: "parcels", Title: "parcels", Description: "parcels", GeometryType: "Polygon", Validation: models.ValidationReject}
+	if !assert.Nil(models.Insert(db, collection)) {
+		return
+	}
+	router := testRouter(t, db)
+
+	square := `{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1],[0,0]]]},"properties":{}}`
+	bowtie := `{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[0,0],[1,1],[1,0],[0,1],[0,0]]]},"properties":{}}`
+	point := `{"type":"Feature","geometry":{"type":"Point","coordinates":[1,2]},"properties":{}}`
+
+	post := func(lines []string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/collections/parcels/items", strings.NewReader(strings.Join(lines, "\n")+"\n"))
+		req.Header.Set(echo.HeaderContentType, mimeNDJSON)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	// features rejected in later batches are reported too
+	lines := []string{point}
+	for i := 0; i < seqBatchSize; i++ {
+		lines = append(lines, square)
+	}
+	lines = append(lines, bowtie)
+	res := post(lines)
+	if assert.Equal(http.StatusUnprocessableEntity, res.Code) {
+		errs := &FeatureErrorList{}
+		if assert.Nil(json.Unmarshal(res.Body.Bytes(), errs)) && assert.Len(errs.Features, 2) {
+			assert.Equal(0, errs.Features[0].Index)
+			assert.Equal(seqBatchSize+1, errs.Features[1].Index)
+		}
+	}
+
+	total, countErr := models.Count(db, &models.Features{}, &models.FeatureQuery{Collection: *collection})
+	assert.Nil(countErr)
+	assert.Equal(uint64(0), total)
+
+	res = post([]string{square, square, square})
+	if assert.Equal(http.StatusOK, res.Code) {
+		summary := &FeatureSeqResult{}
+		if assert.Nil(json.Unmarshal(res.Body.Bytes(), summary)) {
+			assert.Equal(3, summary.Count)
+			assert.Len(summary.Features, 0)
+		}
+	}
+}
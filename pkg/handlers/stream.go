@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/labstack/echo"
+	"github.com/tschaub/pgfs/pkg/models"
+)
+
+var featuresMember = []byte(`"features":[]`)
+
+// streamFeatureList writes a GeoJSON FeatureCollection to the response one
+// feature at a time.  The members of the list other than its features are
+// written as usual and the features are read from the stream.  Headers must
+// be set before calling this.
+func streamFeatureList(c echo.Context, contentType string, list *FeatureList, stream *models.FeatureStream) error {
+	members := *list
+	members.Features = []*FeatureInfo{}
+	data, err := json.Marshal(&members)
+	if err != nil {
+		return err
+	}
+
+	index := bytes.Index(data, featuresMember)
+	if index < 0 {
+		return errors.New("unexpected feature list encoding")
+	}
+	prefix := data[:index+len(featuresMember)-1]
+	suffix := data[index+len(featuresMember)-1:]
+
+	response := c.Response()
+	response.Header().Set(echo.HeaderContentType, contentType)
+	response.WriteHeader(http.StatusOK)
+
+	if _, err := response.Write(prefix); err != nil {
+		return err
+	}
+	if err := writeFeatures(response, stream, nil, nil, []byte(",")); err != nil {
+		return err
+	}
+	_, err = response.Write(suffix)
+	return err
+}
+
+// streamFeatureSeq writes the features in a stream to the response as a
+// sequence of GeoJSON texts.  Headers must be set before calling this.
+func streamFeatureSeq(c echo.Context, contentType string, stream *models.FeatureStream) error {
+	response := c.Response()
+	response.Header().Set(echo.HeaderContentType, contentType)
+	response.WriteHeader(http.StatusOK)
+
+	var start []byte
+	if contentType == mimeGeoJSONSeq {
+		start = []byte(recordSeparator)
+	}
+	return writeFeatures(response, stream, start, []byte("\n"), nil)
+}
+
+// writeFeatures encodes each feature in a stream as JSON.  The start and end
+// bytes are written before and after each feature and the separator is
+// written between features.
+func writeFeatures(w io.Writer, stream *models.FeatureStream, start []byte, end []byte, separator []byte) error {
+	first := true
+	for stream.Next() {
+		feature := &models.Feature{}
+		if err := stream.Scan(feature); err != nil {
+			return err
+		}
+		data, err := json.Marshal(infoFromFeature(feature))
+		if err != nil {
+			return err
+		}
+
+		if !first && len(separator) > 0 {
+			if _, err := w.Write(separator); err != nil {
+				return err
+			}
+		}
+		first = false
+
+		if len(start) > 0 {
+			if _, err := w.Write(start); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		if len(end) > 0 {
+			if _, err := w.Write(end); err != nil {
+				return err
+			}
+		}
+	}
+	return stream.Err()
+}
+
+// readFeatures reads all the features in a stream
+func readFeatures(stream *models.FeatureStream) ([]*FeatureInfo, error) {
+	features := []*FeatureInfo{}
+	for stream.Next() {
+		feature := &models.Feature{}
+		if err := stream.Scan(feature); err != nil {
+			return nil, err
+		}
+		features = append(features, infoFromFeature(feature))
+	}
+	return features, stream.Err()
+}
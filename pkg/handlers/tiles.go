@@ -0,0 +1,343 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+	"github.com/lib/pq"
+	"github.com/tschaub/pgfs/pkg/geo"
+	"github.com/tschaub/pgfs/pkg/models"
+)
+
+const mimeMVT = "application/vnd.mapbox-vector-tile"
+
+// tileFromParams parses the tile matrix set and the zoom, column (x), and row
+// (y) of a tile request
+func tileFromParams(c echo.Context) (*geo.Tile, error) {
+	set, ok := geo.TileMatrixSets[c.Param("tileMatrixSetId")]
+	if !ok {
+		return nil, echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("unknown tile matrix set '%s'", c.Param("tileMatrixSetId")))
+	}
+
+	position := make([]int, 3)
+	for i, name := range []string{"z", "x", "y"} {
+		value, err := strconv.Atoi(c.Param(name))
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bad '%s': must be an integer", name))
+		}
+		position[i] = value
+	}
+
+	tile, err := set.NewTile(position[0], position[1], position[2])
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("bad tile: %s", err))
+	}
+	return tile, nil
+}
+
+// etag returns a strong entity tag for a response body
+func etag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// notModified is true if the If-None-Match header matches an entity tag
+func notModified(c echo.Context, tag string) bool {
+	match := c.Request().Header.Get("If-None-Match")
+	if match == "" {
+		return false
+	}
+	for _, value := range strings.Split(match, ",") {
+		value = strings.TrimPrefix(strings.TrimSpace(value), "W/")
+		if value == tag || value == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// writeTile responds with a vector tile.  Clients can revalidate cached tiles
+// with the ETag, and empty tiles have no content.
+func writeTile(c echo.Context, tile []byte) error {
+	tag := etag(tile)
+	header := c.Response().Header()
+	header.Set("ETag", tag)
+	header.Set("Cache-Control", "no-cache")
+
+	if notModified(c, tag) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	if len(tile) == 0 {
+		return c.NoContent(http.StatusNoContent)
+	}
+	return c.Blob(http.StatusOK, mimeMVT, tile)
+}
+
+// GetCollectionTile responds with a Mapbox Vector Tile of the features in a collection
+func GetCollectionTile(db *sql.DB) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		tile, tileErr := tileFromParams(c)
+		if tileErr != nil {
+			return tileErr
+		}
+
+		collection, getErr := getCollection(db, c.Param("collectionName"))
+		if getErr != nil {
+			return getErr
+		}
+
+		data, dataErr := models.Tile(db, &models.TileQuery{Collection: *collection, Tile: tile})
+		if dataErr != nil {
+			return dataErr
+		}
+
+		return writeTile(c, data)
+	}
+}
+
+// maxTileZoom is the largest zoom level of a tileset layer
+const maxTileZoom = 24
+
+// TileLayerInfo is a collection drawn as a layer of the dataset vector
+// tiles.  The layer is included in tiles from minZoom to maxZoom (inclusive).
+type TileLayerInfo struct {
+	Collection string `json:"collection"`
+	MinZoom    int    `json:"minZoom"`
+	MaxZoom    *int   `json:"maxZoom,omitempty"`
+}
+
+// TilesetInfo defines the layers of the dataset vector tiles in drawing order
+type TilesetInfo struct {
+	Layers []*TileLayerInfo `json:"layers"`
+	Links  []*Link          `json:"links,omitempty"`
+}
+
+// TileJSON describes vector tiles (see https://github.com/mapbox/tilejson-spec)
+type TileJSON struct {
+	TileJSON     string         `json:"tilejson"`
+	Name         string         `json:"name"`
+	Tiles        []string       `json:"tiles"`
+	MinZoom      int            `json:"minzoom"`
+	MaxZoom      int            `json:"maxzoom"`
+	Bounds       []float64      `json:"bounds,omitempty"`
+	VectorLayers []*VectorLayer `json:"vector_layers"`
+}
+
+// VectorLayer describes a layer in TileJSON.  Fields map the properties of
+// features in the layer to a description.
+type VectorLayer struct {
+	ID          string            `json:"id"`
+	Description string            `json:"description,omitempty"`
+	MinZoom     int               `json:"minzoom"`
+	MaxZoom     int               `json:"maxzoom"`
+	Fields      map[string]string `json:"fields"`
+}
+
+// infoFromTileset adds links to the TileJSON for each tile matrix set
+func infoFromTileset(tileset *models.Tileset, base string) *TilesetInfo {
+	info := &TilesetInfo{
+		Layers: []*TileLayerInfo{},
+		Links: []*Link{
+			{Href: base + "/tiles", Rel: "self", Type: echo.MIMEApplicationJSON, Title: "This tileset"},
+		},
+	}
+	for _, layer := range tileset.Layers {
+		maxZoom := layer.MaxZoom
+		info.Layers = append(info.Layers, &TileLayerInfo{Collection: layer.CollectionName, MinZoom: layer.MinZoom, MaxZoom: &maxZoom})
+	}
+
+	ids := make([]string, 0, len(geo.TileMatrixSets))
+	for id := range geo.TileMatrixSets {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		info.Links = append(info.Links, &Link{Href: base + "/tiles/" + id, Rel: "describedby", Type: echo.MIMEApplicationJSON, Title: "TileJSON for " + id})
+	}
+	return info
+}
+
+// tilesetFromInfo validates the layers of a tileset.  The max zoom defaults
+// to the largest zoom level.
+func tilesetFromInfo(info *TilesetInfo) (*models.Tileset, error) {
+	tileset := &models.Tileset{Layers: []*models.TileLayer{}}
+	seen := map[string]bool{}
+	for _, layer := range info.Layers {
+		if layer == nil || layer.Collection == "" {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "each layer must have a 'collection'")
+		}
+		if seen[layer.Collection] {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("collection '%s' is in more than one layer", layer.Collection))
+		}
+		seen[layer.Collection] = true
+
+		maxZoom := maxTileZoom
+		if layer.MaxZoom != nil {
+			maxZoom = *layer.MaxZoom
+		}
+		if layer.MinZoom < 0 || maxZoom > maxTileZoom || layer.MinZoom > maxZoom {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("zoom levels for '%s' must be between 0 and %d with 'minZoom' no greater than 'maxZoom'", layer.Collection, maxTileZoom))
+		}
+
+		tileset.Layers = append(tileset.Layers, &models.TileLayer{CollectionName: layer.Collection, MinZoom: layer.MinZoom, MaxZoom: maxZoom})
+	}
+	return tileset, nil
+}
+
+// GetTileset responds with the definition of the dataset vector tiles
+func GetTileset(db *sql.DB) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		tileset := &models.Tileset{}
+		if err := models.Get(db, tileset); err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, infoFromTileset(tileset, baseURL(c)))
+	}
+}
+
+// UpdateTileset replaces the definition of the dataset vector tiles
+func UpdateTileset(db *sql.DB) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		info := &TilesetInfo{}
+		if bindErr := c.Bind(info); bindErr != nil {
+			return bindErr
+		}
+
+		tileset, infoErr := tilesetFromInfo(info)
+		if infoErr != nil {
+			return infoErr
+		}
+
+		if updateErr := models.Update(db, tileset); updateErr != nil {
+			if pqErr, ok := updateErr.(*pq.Error); ok && pqErr.Code.Name() == "foreign_key_violation" {
+				return echo.NewHTTPError(http.StatusBadRequest, "each layer must be an existing collection")
+			}
+			return updateErr
+		}
+
+		return c.JSON(http.StatusOK, infoFromTileset(tileset, baseURL(c)))
+	}
+}
+
+// GetTileJSON responds with a TileJSON document describing the layers of the
+// dataset vector tiles in a tile matrix set
+func GetTileJSON(db *sql.DB) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		set, ok := geo.TileMatrixSets[c.Param("tileMatrixSetId")]
+		if !ok {
+			return echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("unknown tile matrix set '%s'", c.Param("tileMatrixSetId")))
+		}
+
+		tileset := &models.Tileset{}
+		if err := models.Get(db, tileset); err != nil {
+			return err
+		}
+
+		collections := make([]*models.Collection, len(tileset.Layers))
+		for i, layer := range tileset.Layers {
+			collection, err := getCollection(db, layer.CollectionName)
+			if err != nil {
+				return err
+			}
+			collections[i] = collection
+		}
+
+		return c.JSON(http.StatusOK, tileJSON(set, tileset, collections, baseURL(c)))
+	}
+}
+
+// tileJSON describes the layers of a tileset.  The bounds include the
+// extent of all the layer collections.
+func tileJSON(set *geo.TileMatrixSet, tileset *models.Tileset, collections []*models.Collection, base string) *TileJSON {
+	doc := &TileJSON{
+		TileJSON:     "3.0.0",
+		Name:         "pgfs",
+		Tiles:        []string{fmt.Sprintf("%s/tiles/%s/{z}/{x}/{y}", base, set.ID)},
+		MinZoom:      set.MaxZoom,
+		VectorLayers: []*VectorLayer{},
+	}
+
+	for i, layer := range tileset.Layers {
+		collection := collections[i]
+		maxZoom := layer.MaxZoom
+		if maxZoom > set.MaxZoom {
+			maxZoom = set.MaxZoom
+		}
+
+		fields := map[string]string{models.IDProperty: "Feature identifier"}
+		for _, name := range collection.TileProperties {
+			fields[name] = name
+		}
+		doc.VectorLayers = append(doc.VectorLayers, &VectorLayer{
+			ID:          collection.Name,
+			Description: collection.Description,
+			MinZoom:     layer.MinZoom,
+			MaxZoom:     maxZoom,
+			Fields:      fields,
+		})
+
+		if layer.MinZoom < doc.MinZoom {
+			doc.MinZoom = layer.MinZoom
+		}
+		if maxZoom > doc.MaxZoom {
+			doc.MaxZoom = maxZoom
+		}
+
+		if extent := extentFromCollection(collection); extent != nil && extent.Spatial != nil {
+			bbox := extent.Spatial.BBox[0]
+			if doc.Bounds == nil {
+				doc.Bounds = append([]float64{}, bbox...)
+				continue
+			}
+			doc.Bounds[0] = math.Min(doc.Bounds[0], bbox[0])
+			doc.Bounds[1] = math.Min(doc.Bounds[1], bbox[1])
+			doc.Bounds[2] = math.Max(doc.Bounds[2], bbox[2])
+			doc.Bounds[3] = math.Max(doc.Bounds[3], bbox[3])
+		}
+	}
+
+	if len(tileset.Layers) == 0 {
+		doc.MinZoom = 0
+	}
+	return doc
+}
+
+// GetDatasetTile responds with a Mapbox Vector Tile with a layer for each
+// collection in the tileset that is visible at the tile zoom level
+func GetDatasetTile(db *sql.DB) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		tile, tileErr := tileFromParams(c)
+		if tileErr != nil {
+			return tileErr
+		}
+
+		tileset := &models.Tileset{}
+		if err := models.Get(db, tileset); err != nil {
+			return err
+		}
+
+		queries := []*models.TileQuery{}
+		for _, layer := range tileset.Visible(tile.Zoom) {
+			collection, err := getCollection(db, layer.CollectionName)
+			if err != nil {
+				return err
+			}
+			queries = append(queries, &models.TileQuery{Collection: *collection, Tile: tile})
+		}
+
+		data, dataErr := models.Tile(db, queries...)
+		if dataErr != nil {
+			return dataErr
+		}
+
+		return writeTile(c, data)
+	}
+}
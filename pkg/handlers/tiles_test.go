@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+	"github.com/tschaub/pgfs/pkg/geo"
+	"github.com/tschaub/pgfs/pkg/models"
+)
+
+func TestTileFromParams(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		params []string
+		code   int
+	}{
+		{params: []string{"WebMercatorQuad", "2", "1", "3"}},
+		{params: []string{"WorldCRS84Quad", "0", "1", "0"}},
+		{params: []string{"bogus", "0", "0", "0"}, code: http.StatusNotFound},
+		{params: []string{"WebMercatorQuad", "1", "2", "0"}, code: http.StatusNotFound},
+		{params: []string{"WebMercatorQuad", "1", "x", "0"}, code: http.StatusBadRequest},
+	}
+
+	for _, c := range cases {
+		context := echo.New().NewContext(httptest.NewRequest(echo.GET, "/", nil), httptest.NewRecorder())
+		context.SetParamNames("tileMatrixSetId", "z", "x", "y")
+		context.SetParamValues(c.params...)
+
+		tile, err := tileFromParams(context)
+		if c.code != 0 {
+			if httpErr, ok := err.(*echo.HTTPError); assert.True(ok, c.params) {
+				assert.Equal(c.code, httpErr.Code, c.params)
+			}
+			continue
+		}
+		if assert.Nil(err, c.params) {
+			assert.Equal(c.params[0], tile.Set.ID)
+			assert.Equal(c.params[1:], []string{strconv.Itoa(tile.Zoom), strconv.Itoa(tile.Col), strconv.Itoa(tile.Row)})
+		}
+	}
+}
+
+func TestWriteTile(t *testing.T) {
+	assert := assert.New(t)
+
+	tile := []byte("tile")
+	tag := etag(tile)
+
+	cases := []struct {
+		name        string
+		tile        []byte
+		ifNoneMatch string
+		code        int
+	}{
+		{name: "tile", tile: tile, code: http.StatusOK},
+		{name: "empty", tile: []byte{}, code: http.StatusNoContent},
+		{name: "cached", tile: tile, ifNoneMatch: tag, code: http.StatusNotModified},
+		{name: "cached weak", tile: tile, ifNoneMatch: `"other", W/` + tag, code: http.StatusNotModified},
+		{name: "changed", tile: tile, ifNoneMatch: `"other"`, code: http.StatusOK},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(echo.GET, "/", nil)
+		if c.ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", c.ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		assert.Nil(writeTile(echo.New().NewContext(req, rec), c.tile), c.name)
+		assert.Equal(c.code, rec.Code, c.name)
+		assert.Equal(etag(c.tile), rec.Header().Get("ETag"), c.name)
+		if c.code == http.StatusOK {
+			assert.Equal(mimeMVT, rec.Header().Get(echo.HeaderContentType), c.name)
+			assert.Equal(c.tile, rec.Body.Bytes(), c.name)
+		}
+	}
+}
+
+func TestTilesetFromInfo(t *testing.T) {
+	assert := assert.New(t)
+
+	eight := 8
+	tileset, err := tilesetFromInfo(&TilesetInfo{Layers: []*TileLayerInfo{
+		{Collection: "roads", MinZoom: 4},
+		{Collection: "places", MaxZoom: &eight},
+	}})
+	if assert.Nil(err) {
+		assert.Equal([]*models.TileLayer{
+			{CollectionName: "roads", MinZoom: 4, MaxZoom: maxTileZoom},
+			{CollectionName: "places", MinZoom: 0, MaxZoom: 8},
+		}, tileset.Layers)
+	}
+
+	tileset, err = tilesetFromInfo(&TilesetInfo{})
+	if assert.Nil(err) {
+		assert.Empty(tileset.Layers)
+	}
+
+	bad := []*TilesetInfo{
+		{Layers: []*TileLayerInfo{{MinZoom: 1}}},
+		{Layers: []*TileLayerInfo{{Collection: "roads"}, {Collection: "roads"}}},
+		{Layers: []*TileLayerInfo{{Collection: "roads", MinZoom: 10, MaxZoom: &eight}}},
+		{Layers: []*TileLayerInfo{{Collection: "roads", MinZoom: -1}}},
+		{Layers: []*TileLayerInfo{{Collection: "roads", MinZoom: maxTileZoom + 1}}},
+	}
+	for i, info := range bad {
+		_, err := tilesetFromInfo(info)
+		if httpErr, ok := err.(*echo.HTTPError); assert.True(ok, i) {
+			assert.Equal(http.StatusBadRequest, httpErr.Code, i)
+		}
+	}
+}
+
+func TestTileJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	minX, minY, maxX, maxY := -10.0, 35.0, 30.0, 60.0
+	roads := &models.Collection{Name: "roads", Description: "Roads", TileProperties: []string{"class"}}
+	places := &models.Collection{Name: "places", Description: "Places"}
+	places.Extent = models.Extent{MinX: &minX, MinY: &minY, MaxX: &maxX, MaxY: &maxY}
+
+	tileset := &models.Tileset{Layers: []*models.TileLayer{
+		{CollectionName: "roads", MinZoom: 6, MaxZoom: 24},
+		{CollectionName: "places", MinZoom: 2, MaxZoom: 12},
+	}}
+
+	doc := tileJSON(geo.TileMatrixSets["WorldCRS84Quad"], tileset, []*models.Collection{roads, places}, "http://example.com")
+	assert.Equal("3.0.0", doc.TileJSON)
+	assert.Equal([]string{"http://example.com/tiles/WorldCRS84Quad/{z}/{x}/{y}"}, doc.Tiles)
+	assert.Equal(2, doc.MinZoom)
+	assert.Equal(23, doc.MaxZoom)
+	assert.Equal([]float64{-10, 35, 30, 60}, doc.Bounds)
+	if assert.Len(doc.VectorLayers, 2) {
+		assert.Equal("roads", doc.VectorLayers[0].ID)
+		assert.Equal(23, doc.VectorLayers[0].MaxZoom)
+		assert.Contains(doc.VectorLayers[0].Fields, "class")
+		assert.Contains(doc.VectorLayers[0].Fields, models.IDProperty)
+		assert.Equal(2, doc.VectorLayers[1].MinZoom)
+	}
+
+	doc = tileJSON(geo.TileMatrixSets["WebMercatorQuad"], &models.Tileset{}, nil, "http://example.com")
+	assert.Equal(0, doc.MinZoom)
+	assert.Equal(0, doc.MaxZoom)
+	assert.Nil(doc.Bounds)
+	assert.Empty(doc.VectorLayers)
+}
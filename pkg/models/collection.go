@@ -1,18 +1,116 @@
 package models
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/tschaub/pgfs/pkg/geo"
 	sq "gopkg.in/Masterminds/squirrel.v1"
 )
 
 // Collection is a set of features.
 type Collection struct {
-	Name        string `db:"name"`
-	Title       string `db:"title"`
-	Description string `db:"description"`
+	Name            string         `db:"name"`
+	Title           string         `db:"title"`
+	Description     string         `db:"description"`
+	TimeProperty    string         `db:"time_property"`
+	TimeEndProperty string         `db:"time_end_property"`
+	Queryables      pq.StringArray `db:"queryables"`
+	// SRID is the spatial reference identifier of stored geometries
+	SRID int `db:"srid"`
+	// CRS lists URIs of coordinate reference systems supported in addition
+	// to CRS84 and the storage CRS
+	CRS pq.StringArray `db:"crs"`
+	// GeometryType restricts features to a single GeoJSON geometry type
+	// (e.g. MultiPolygon).  Any type is allowed if empty.
+	GeometryType string `db:"geometry_type"`
+	// Dimension restricts the number of values in each position (2 or 3 for
+	// geometries with Z values).  Any dimension is allowed if 0.
+	Dimension int `db:"dimension"`
+	// Validation is the policy for invalid geometries (ValidationReject,
+	// ValidationWarn, or ValidationRepair)
+	Validation string `db:"validation"`
+	// Sortables are properties that can be used to order features
+	Sortables pq.StringArray `db:"sortables"`
+	// TileProperties are properties included in vector tiles
+	TileProperties pq.StringArray `db:"tile_properties"`
+	Extent
+}
+
+// validation returns the validation policy, warning about invalid geometries by default
+func (collection *Collection) validation() string {
+	if collection.Validation == "" {
+		return ValidationWarn
+	}
+	return collection.Validation
+}
+
+// CheckGeometry returns an error if a geometry does not have the type and
+// dimension required by the collection
+func (collection *Collection) CheckGeometry(geometry *geo.Geometry) error {
+	if collection.GeometryType != "" && geometry.Type() != collection.GeometryType {
+		return fmt.Errorf("expected a %s geometry, got %s", collection.GeometryType, geometry.Type())
+	}
+
+	if collection.Dimension != 0 {
+		dimension := geometry.Dimension()
+		if dimension == 0 {
+			return fmt.Errorf("expected %d coordinate values in all positions", collection.Dimension)
+		}
+		if dimension != collection.Dimension {
+			return fmt.Errorf("expected %d coordinate values in each position, got %d", collection.Dimension, dimension)
+		}
+	}
+
+	return nil
+}
+
+// StorageCRS returns the coordinate reference system of stored geometries
+func (collection *Collection) StorageCRS() geo.CRS {
+	if collection.SRID == 0 || collection.SRID == geo.CRS84.SRID {
+		return geo.CRS84
+	}
+	return geo.CRS{SRID: collection.SRID}
+}
+
+// SupportsCRS returns true if geometries can be read or written in a CRS
+func (collection *Collection) SupportsCRS(crs geo.CRS) bool {
+	if crs.SRID == geo.CRS84.SRID || crs.SRID == collection.StorageCRS().SRID {
+		return true
+	}
+	for _, uri := range collection.CRS {
+		if supported, err := geo.ParseCRS(uri); err == nil && supported.SRID == crs.SRID {
+			return true
+		}
+	}
+	return false
+}
+
+// Queryable returns true if features can be filtered by the named property
+func (collection *Collection) Queryable(name string) bool {
+	for _, queryable := range collection.Queryables {
+		if queryable == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Sortable returns true if features can be ordered by the named property.
+// Features can always be ordered by ID.
+func (collection *Collection) Sortable(name string) bool {
+	if name == IDProperty {
+		return true
+	}
+	for _, sortable := range collection.Sortables {
+		if sortable == name {
+			return true
+		}
+	}
+	return false
 }
 
 // Collection implements the Record interface
@@ -27,9 +125,9 @@ type CollectionsQuery struct {
 }
 
 // where adds a where clause to the builder based on the query
-func (query *CollectionsQuery) where(builder sq.SelectBuilder) sq.SelectBuilder {
+func (query *CollectionsQuery) where(builder sq.SelectBuilder) (sq.SelectBuilder, error) {
 	// TODO: query collections
-	return builder
+	return builder, nil
 }
 
 // CollectionsQuery implements the Querier interface
@@ -44,7 +142,18 @@ var selectCollections = builder.
 	Select(
 		column(collectionTable, "name"),
 		column(collectionTable, "title"),
-		column(collectionTable, "description")).
+		column(collectionTable, "description"),
+		column(collectionTable, "time_property"),
+		column(collectionTable, "time_end_property"),
+		column(collectionTable, "queryables"),
+		column(collectionTable, "srid"),
+		column(collectionTable, "crs"),
+		column(collectionTable, "geometry_type"),
+		column(collectionTable, "dimension"),
+		column(collectionTable, "validation"),
+		column(collectionTable, "sortables"),
+		column(collectionTable, "tile_properties")).
+	Columns(extentColumns...).
 	From(collectionTable).
 	OrderBy(fmt.Sprintf("%s ASC", column(collectionTable, "name")))
 
@@ -53,9 +162,21 @@ func (collection *Collection) insert(db *sqlx.DB) error {
 	sql, args, sqlErr := builder.
 		Insert(collectionTable).
 		SetMap(sq.Eq{
-			"title":       collection.Title,
-			"name":        collection.Name,
-			"description": collection.Description,
+			"title":             collection.Title,
+			"name":              collection.Name,
+			"description":       collection.Description,
+			"time_property":     collection.TimeProperty,
+			"time_end_property": collection.TimeEndProperty,
+			"queryables":        collection.Queryables,
+			"srid":              collection.StorageCRS().SRID,
+			"crs":               collection.CRS,
+			"geometry_type":     collection.GeometryType,
+			"dimension":         collection.Dimension,
+			"validation":        collection.validation(),
+			"sortables":         collection.Sortables,
+			"tile_properties":   collection.TileProperties,
+			// a new collection has no features to compute an extent from
+			"extent_valid": true,
 		}).ToSql()
 
 	if sqlErr != nil {
@@ -70,13 +191,22 @@ func (collection *Collection) insert(db *sqlx.DB) error {
 	return collection.get(db)
 }
 
-// update updates a collection's editable fields
+// update updates a collection's editable fields, returning sql.ErrNoRows if
+// there is no matching collection.  The storage SRID, geometry type, and
+// dimension are not editable.
 func (collection *Collection) update(db *sqlx.DB) error {
 	sql, args, sqlErr := builder.
 		Update(collectionTable).
 		SetMap(sq.Eq{
-			"title":       collection.Title,
-			"description": collection.Description,
+			"title":             collection.Title,
+			"description":       collection.Description,
+			"time_property":     collection.TimeProperty,
+			"time_end_property": collection.TimeEndProperty,
+			"queryables":        collection.Queryables,
+			"crs":               collection.CRS,
+			"validation":        collection.validation(),
+			"sortables":         collection.Sortables,
+			"tile_properties":   collection.TileProperties,
 		}).
 		Where(sq.Eq{"name": collection.Name}).ToSql()
 
@@ -84,8 +214,14 @@ func (collection *Collection) update(db *sqlx.DB) error {
 		return sqlErr
 	}
 
-	_, execErr := db.Exec(sql, args...)
-	return execErr
+	// the extent depends on the time properties
+	return changeFeatures(db, collection.Name, func(tx *sqlx.Tx) error {
+		result, err := tx.Exec(sql, args...)
+		if err != nil {
+			return err
+		}
+		return requireRows(result)
+	})
 }
 
 // get finds a collection by name
@@ -98,19 +234,86 @@ func (collection *Collection) get(db *sqlx.DB) error {
 	return db.Get(collection, sql, args...)
 }
 
-// delete removes the collection
+// ErrCollectionNotEmpty is returned when deleting a collection with features
+// without force
+var ErrCollectionNotEmpty = errors.New("collection has features")
+
+// DeleteCollection removes a collection.  If force is true, the features in
+// the collection are deleted with it.  Otherwise ErrCollectionNotEmpty is
+// returned if the collection has features.
+func DeleteCollection(db *sql.DB, collection *Collection, force bool) error {
+	return collection.remove(sqlx.NewDb(db, driverName), force)
+}
+
+// delete removes the collection and its features
 func (collection *Collection) delete(db *sqlx.DB) error {
-	// TODO: delete features first
+	return collection.remove(db, true)
+}
 
-	sql, args, sqlErr := builder.
+var collectionHasFeaturesSQL = `
+SELECT EXISTS (SELECT 1 FROM features WHERE collection_name = $1)
+`
+
+// remove deletes the collection (and its features if force is true) in a
+// transaction.  The collection is locked first (with or without force), so no
+// features can be added until it is deleted.
+func (collection *Collection) remove(db *sqlx.DB, force bool) error {
+	tx, txErr := db.Beginx()
+	if txErr != nil {
+		return txErr
+	}
+
+	var err error
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var name string
+	if err = tx.Get(&name, "SELECT name FROM collections WHERE name = $1 FOR UPDATE", collection.Name); err != nil {
+		return err
+	}
+
+	if !force {
+		var hasFeatures bool
+		if err = tx.Get(&hasFeatures, collectionHasFeaturesSQL, collection.Name); err != nil {
+			return err
+		}
+		if hasFeatures {
+			err = ErrCollectionNotEmpty
+			return err
+		}
+	}
+
+	featuresSQL, featuresArgs, err := builder.
+		Delete(featureTable).
+		Where(sq.Eq{"collection_name": collection.Name}).ToSql()
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(featuresSQL, featuresArgs...); err != nil {
+		return err
+	}
+
+	sql, args, err := builder.
 		Delete(collectionTable).
 		Where(sq.Eq{"name": collection.Name}).ToSql()
+	if err != nil {
+		return err
+	}
 
-	if sqlErr != nil {
-		return sqlErr
+	result, err := tx.Exec(sql, args...)
+	if err != nil {
+		return err
 	}
 
-	_, err := db.Exec(sql, args...)
+	if err = requireRows(result); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
 	return err
 }
 
@@ -128,7 +331,12 @@ func (collections *Collections) query(db *sqlx.DB, query Querier) (bool, error)
 		collectionQuery = &CollectionsQuery{}
 	}
 
-	sql, args, err := collectionQuery.where(selectCollections).ToSql()
+	builder, whereErr := collectionQuery.where(selectCollections)
+	if whereErr != nil {
+		return false, whereErr
+	}
+
+	sql, args, err := builder.ToSql()
 	if err != nil {
 		return false, err
 	}
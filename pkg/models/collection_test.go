@@ -0,0 +1,188 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectionDelete(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	features := setupFeatures(t, db)
+
+	assert.Nil(Delete(db, &Collection{Name: "first"}))
+	assert.Equal(sql.ErrNoRows, Get(db, &Collection{Name: "first"}))
+
+	for _, feature := range features[:2] {
+		assert.Equal(sql.ErrNoRows, Get(db, &Feature{ID: feature.ID}), "expected features to be deleted")
+	}
+
+	assert.Nil(Get(db, &Collection{Name: "second"}))
+	assert.Nil(Get(db, &Feature{ID: features[2].ID}), "expected features in other collections to remain")
+}
+
+func TestCollectionDeleteMissing(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert.Equal(t, sql.ErrNoRows, Delete(db, &Collection{Name: "missing"}))
+}
+
+func TestDeleteCollection(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	features := setupFeatures(t, db)
+
+	assert.Equal(ErrCollectionNotEmpty, DeleteCollection(db, &Collection{Name: "first"}, false))
+	assert.Nil(Get(db, &Feature{ID: features[0].ID}), "expected features to remain")
+
+	assert.Nil(DeleteCollection(db, &Collection{Name: "first"}, true))
+	assert.Equal(sql.ErrNoRows, Get(db, &Collection{Name: "first"}))
+
+	assert.Nil(Insert(db, &Collection{Name: "empty", Title: "empty", Description: "empty"}))
+	assert.Nil(DeleteCollection(db, &Collection{Name: "empty"}, false))
+	assert.Equal(sql.ErrNoRows, DeleteCollection(db, &Collection{Name: "empty"}, false))
+}
+
+func TestCollectionExtent(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	features := setupFeatures(t, db)
+
+	collection := &Collection{Name: "first"}
+	assert.Nil(Get(db, collection))
+	assert.True(collection.Extent.Valid)
+	assert.Equal(1.0, *collection.Extent.MinX)
+	assert.Equal(2.0, *collection.Extent.MinY)
+	assert.Equal(3.0, *collection.Extent.MaxX)
+	assert.Equal(4.0, *collection.Extent.MaxY)
+
+	updated := &Feature{
+		ID:             features[1].ID,
+		CollectionName: "first",
+		Geometry:       mustGeometry(t, `{"type":"Point","coordinates":[7,8]}`),
+		Properties:     PropertyMap{"name": "two"},
+	}
+	assert.Nil(Update(db, updated))
+
+	collection = &Collection{Name: "first"}
+	assert.Nil(Get(db, collection))
+	assert.Equal(7.0, *collection.Extent.MaxX, "expected the extent to be recomputed")
+	assert.Equal(8.0, *collection.Extent.MaxY, "expected the extent to be recomputed")
+}
+
+func TestCollectionExtentBadTime(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	assert.Nil(Insert(db, &Collection{Name: "events", Title: "events", Description: "events", TimeProperty: "when"}))
+
+	features := Features{}
+	for _, when := range []interface{}{"2020-01-02T00:00:00Z", "soon", "2020-13-45", 42.0, "2021-03-04T00:00:00Z"} {
+		features = append(features, &Feature{
+			CollectionName: "events",
+			Geometry:       mustGeometry(t, `{"type":"Point","coordinates":[1,2]}`),
+			Properties:     PropertyMap{"when": when},
+		})
+	}
+	assert.Nil(BulkInsert(db, &features))
+
+	collection := &Collection{Name: "events"}
+	if !assert.Nil(Get(db, collection), "expected values that are not timestamps to be ignored") {
+		return
+	}
+	assert.True(collection.Extent.Valid)
+	if assert.NotNil(collection.Extent.Start) && assert.NotNil(collection.Extent.End) {
+		assert.Equal(2020, collection.Extent.Start.UTC().Year())
+		assert.Equal(2021, collection.Extent.End.UTC().Year())
+	}
+}
+
+func TestCollectionExtentChanges(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	collection := &Collection{Name: "events", Title: "events", Description: "events"}
+	assert.Nil(Insert(db, collection))
+
+	first := &Feature{
+		CollectionName: "events",
+		Geometry:       mustGeometry(t, `{"type":"Point","coordinates":[1,2]}`),
+		Properties:     PropertyMap{"when": "2020-01-02T00:00:00Z"},
+	}
+	second := &Feature{
+		CollectionName: "events",
+		Geometry:       mustGeometry(t, `{"type":"Point","coordinates":[5,6]}`),
+		Properties:     PropertyMap{"when": "2021-03-04T00:00:00Z"},
+	}
+	assert.Nil(Insert(db, first))
+	assert.Nil(Insert(db, second))
+
+	// the extent is cached when features change, not when it is read
+	var maxX float64
+	assert.Nil(db.QueryRow("SELECT extent_max_x FROM collections WHERE name = 'events'").Scan(&maxX))
+	assert.Equal(5.0, maxX)
+
+	assert.Nil(Delete(db, second))
+	assert.Nil(Get(db, collection))
+	assert.Equal(1.0, *collection.Extent.MaxX, "expected the extent to shrink")
+	assert.Nil(collection.Extent.Start)
+
+	collection.TimeProperty = "when"
+	assert.Nil(Update(db, collection))
+	assert.Nil(Get(db, collection))
+	if assert.NotNil(collection.Extent.Start, "expected the time property to be used") {
+		assert.Equal(2020, collection.Extent.Start.UTC().Year())
+	}
+}
+
+func TestCollectionExtentEmpty(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	assert.Nil(Insert(db, &Collection{Name: "empty", Title: "empty", Description: "empty"}))
+
+	collection := &Collection{Name: "empty"}
+	assert.Nil(Get(db, collection))
+	assert.True(collection.Extent.Valid)
+	assert.Nil(collection.Extent.MinX)
+	assert.Nil(collection.Extent.Start)
+}
+
+func TestCollectionCheckGeometry(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		collection *Collection
+		geometry   string
+		ok         bool
+	}{
+		{&Collection{}, `{"type":"Point","coordinates":[1,2,3]}`, true},
+		{&Collection{GeometryType: "Point"}, `{"type":"Point","coordinates":[1,2]}`, true},
+		{&Collection{GeometryType: "MultiPolygon"}, `{"type":"Point","coordinates":[1,2]}`, false},
+		{&Collection{Dimension: 3}, `{"type":"LineString","coordinates":[[1,2,3],[4,5,6]]}`, true},
+		{&Collection{Dimension: 3}, `{"type":"LineString","coordinates":[[1,2],[4,5]]}`, false},
+		{&Collection{Dimension: 2}, `{"type":"LineString","coordinates":[[1,2],[4,5,6]]}`, false},
+	}
+
+	for _, c := range cases {
+		geometry := mustGeometry(t, c.geometry)
+		err := c.collection.CheckGeometry(&geometry)
+		if c.ok {
+			assert.Nil(err, c.geometry)
+		} else {
+			assert.NotNil(err, c.geometry)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Extent is the spatial (CRS84) and temporal extent of the features in a
+// collection.  The extent is cached with the collection and recomputed when
+// features are changed, in the same transaction.  Time values that are not
+// valid timestamps are ignored.
+type Extent struct {
+	Valid bool       `db:"extent_valid"`
+	MinX  *float64   `db:"extent_min_x"`
+	MinY  *float64   `db:"extent_min_y"`
+	MaxX  *float64   `db:"extent_max_x"`
+	MaxY  *float64   `db:"extent_max_y"`
+	Start *time.Time `db:"extent_start"`
+	End   *time.Time `db:"extent_end"`
+}
+
+var extentColumns = []string{
+	column(collectionTable, "extent_valid"),
+	column(collectionTable, "extent_min_x"),
+	column(collectionTable, "extent_min_y"),
+	column(collectionTable, "extent_max_x"),
+	column(collectionTable, "extent_max_y"),
+	column(collectionTable, "extent_start"),
+	column(collectionTable, "extent_end"),
+}
+
+// extentSQL computes and caches the extents of the collections matching a
+// condition on the collection (aliased as c)
+var extentSQL = `
+UPDATE collections SET
+	extent_valid = TRUE,
+	extent_min_x = ST_XMin(extent.bbox),
+	extent_min_y = ST_YMin(extent.bbox),
+	extent_max_x = ST_XMax(extent.bbox),
+	extent_max_y = ST_YMax(extent.bbox),
+	extent_start = extent.start_time,
+	extent_end = extent.end_time
+FROM (
+	SELECT
+		c.name,
+		ST_Extent(ST_Transform(f.geometry, 4326)) AS bbox,
+		MIN(pgfs_timestamptz(f.properties->>c.time_property)) AS start_time,
+		MAX(pgfs_timestamptz(f.properties->>COALESCE(NULLIF(c.time_end_property, ''), c.time_property))) AS end_time
+	FROM collections AS c
+	LEFT JOIN features AS f ON f.collection_name = c.name
+	WHERE %s
+	GROUP BY c.name
+) AS extent
+WHERE collections.name = extent.name
+`
+
+var refreshExtentSQL = fmt.Sprintf(extentSQL, "c.name = $1")
+
+// refreshStaleExtentsSQL computes extents that were not cached by an earlier
+// version (see Migrate)
+var refreshStaleExtentsSQL = fmt.Sprintf(extentSQL, "NOT c.extent_valid")
+
+// lockExtentSQL locks a collection so that features are changed (and its
+// extent computed) by one transaction at a time.  Unlike FOR UPDATE, this
+// does not conflict with the locks taken when features reference it.
+var lockExtentSQL = `
+SELECT name
+FROM collections
+WHERE name = $1
+FOR NO KEY UPDATE
+`
+
+// lockExtent locks a collection before its features are changed in a
+// transaction
+func lockExtent(tx *sqlx.Tx, collectionName string) error {
+	_, err := tx.Exec(lockExtentSQL, collectionName)
+	return err
+}
+
+// refreshExtent computes and caches the extent of a collection.  It is called
+// in the transaction that changes features (after lockExtent), so collections
+// are read without computing extents.
+func refreshExtent(tx *sqlx.Tx, collectionName string) error {
+	_, err := tx.Exec(refreshExtentSQL, collectionName)
+	return err
+}
+
+// changeFeatures calls change in a transaction with the collection locked and
+// refreshes the collection extent before committing
+func changeFeatures(db *sqlx.DB, collectionName string, change func(*sqlx.Tx) error) error {
+	tx, txErr := db.Beginx()
+	if txErr != nil {
+		return txErr
+	}
+
+	var err error
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err = lockExtent(tx, collectionName); err != nil {
+		return err
+	}
+	if err = change(tx); err != nil {
+		return err
+	}
+	if err = refreshExtent(tx, collectionName); err != nil {
+		return err
+	}
+	err = tx.Commit()
+	return err
+}
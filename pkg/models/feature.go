@@ -1,14 +1,19 @@
 package models
 
 import (
+	"database/sql"
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/tschaub/pgfs/pkg/cql"
 	"github.com/tschaub/pgfs/pkg/geo"
+	"github.com/tschaub/pgfs/pkg/temporal"
 	sq "gopkg.in/Masterminds/squirrel.v1"
 )
 
@@ -48,6 +53,14 @@ type Feature struct {
 	Geometry       geo.Geometry `db:"geometry"`
 	Properties     PropertyMap  `db:"properties"`
 	CollectionName string       `db:"collection_name"`
+	// CRS of the geometry when reading or writing (CRS84 if not set).
+	// Geometries are transformed to and from the storage CRS of the collection.
+	CRS geo.CRS `db:"-"`
+	// Validity is set when the feature is saved
+	Validity *Validity `db:"-"`
+	// SortValues are set when the feature is read and can be used to page
+	// from the feature with a Cursor
+	SortValues SortValues `db:"sort_values"`
 }
 
 // Feature implements the Record interface
@@ -62,44 +75,260 @@ var _ RecordSet = (*Features)(nil)
 // Features implements the BulkInsertable interface
 var _ BulkInsertable = (*Features)(nil)
 
+// Features implements the Countable interface
+var _ Countable = (*Features)(nil)
+
 // FeatureQuery is used for querying Features
 type FeatureQuery struct {
 	Collection Collection
 	Limit      uint64
 	After      *Feature
+	Before     *Feature
+	BBox       *geo.BBox
+	Datetime   *temporal.Interval
+	Properties map[string][]string
+	Filter     cql.Expression
+	FilterCRS  geo.CRS
+	CRS        geo.CRS
+	// Tolerance simplifies geometries (in CRS units) if greater than zero
+	Tolerance float64
+	// MaxDecimalDigits limits the precision of coordinates if not nil
+	MaxDecimalDigits *int
+	// PropertyNames limits the properties that are read if not nil
+	PropertyNames []string
+	// SkipGeometry leaves the geometry of features empty
+	SkipGeometry bool
+	// ID limits the query to a single feature if not zero
+	ID uuid.UUID
+	// SortBy orders features by properties (features are always ordered by ID last)
+	SortBy []SortKey
+	// Cursor is the position to page from (used instead of After or Before)
+	Cursor *Cursor
+	// All streams every matching feature instead of a page (Limit is ignored)
+	All bool
 }
 
 var defaultFeatureLimit uint64 = 500
 
-// where adds a where clause to the builder based on the query
-func (query *FeatureQuery) where(builder sq.SelectBuilder) sq.SelectBuilder {
-	builder = builder.
-		Where(sq.Eq{column(featureTable, "collection_name"): query.Collection.Name})
+var defaultMaxDecimalDigits = 9
 
-	if query.After != nil {
-		builder = builder.Where(sq.Gt{column(featureTable, "id"): query.After.ID})
+// where adds a where clause to the builder based on the query.  Features are
+// ordered by the sort keys (and ID) and paged with the Cursor or the After or
+// Before feature.  Paging backward returns features in reverse order.  The
+// number of features is not limited (see page).
+func (query *FeatureQuery) where(builder sq.SelectBuilder) (sq.SelectBuilder, error) {
+	builder = query.filter(builder)
+
+	keys := query.sortKeys()
+	if query.Cursor != nil {
+		after, err := cursorKeyset(keys, query.Cursor)
+		if err != nil {
+			return builder, err
+		}
+		builder = builder.Where(after)
+	} else if query.After != nil {
+		builder = builder.Where(featureKeyset(keys, query.After.ID))
+	} else if query.Before != nil {
+		builder = builder.Where(featureKeyset(keys, query.Before.ID))
 	}
 
+	return builder.OrderBy(orderBy(keys)...), nil
+}
+
+// page adds a where clause to the builder (see where) and limits it to one
+// more feature than the query limit, so a following page can be detected
+func (query *FeatureQuery) page(builder sq.SelectBuilder) (sq.SelectBuilder, error) {
 	if query.Limit == 0 {
 		query.Limit = defaultFeatureLimit
 	}
+	builder, err := query.where(builder)
+	if err != nil {
+		return builder, err
+	}
+	return builder.Limit(query.Limit + 1), nil
+}
 
-	return builder.
-		OrderBy(fmt.Sprintf("%s ASC", column(featureTable, "id"))).
-		Limit(query.Limit + 1)
+// filter adds the conditions of the query other than paging to the builder
+func (query *FeatureQuery) filter(builder sq.SelectBuilder) sq.SelectBuilder {
+	builder = builder.
+		Where(sq.Eq{column(featureTable, "collection_name"): query.Collection.Name})
+
+	if query.ID != uuid.Nil {
+		builder = builder.Where(sq.Eq{column(featureTable, "id"): query.ID})
+	}
+
+	if query.BBox != nil {
+		storage := query.Collection.StorageCRS()
+		intersects := sq.Or{}
+		for _, box := range query.BBox.Split() {
+			// latitudes outside the storage CRS cannot be transformed
+			box = box.Clamp(storage)
+			intersects = append(intersects, sq.Expr(
+				fmt.Sprintf("ST_Intersects(%s, ST_Transform(ST_MakeEnvelope(?, ?, ?, ?, ?), ?))", column(featureTable, "geometry")),
+				box.MinX, box.MinY, box.MaxX, box.MaxY, box.CRS.SRID, storage.SRID,
+			))
+		}
+		builder = builder.Where(intersects)
+	}
+
+	if query.Datetime != nil {
+		builder = builder.Where(query.datetimeFilter())
+	}
+
+	for name, values := range query.Properties {
+		builder = builder.Where(propertyFilter(name, values))
+	}
+
+	if query.Filter != nil {
+		crs := query.FilterCRS
+		if crs.SRID == 0 {
+			crs = geo.CRS84
+		}
+		builder = builder.Where(cql.Where(query.Filter, &cql.Options{
+			ID:         column(featureTable, "id"),
+			Geometry:   column(featureTable, "geometry"),
+			Properties: column(featureTable, "properties"),
+			SRID:       query.Collection.StorageCRS().SRID,
+			CRS:        crs,
+		}))
+	}
+
+	return builder
+}
+
+// datetimeFilter matches features with a time (or time range) that
+// intersects the query interval
+func (query *FeatureQuery) datetimeFilter() sq.Sqlizer {
+	start := query.Collection.TimeProperty
+	end := query.Collection.TimeEndProperty
+	filter := sq.And{}
+
+	if end == "" {
+		if query.Datetime.Start != nil {
+			filter = append(filter, sq.Expr(fmt.Sprintf("%s >= ?", timeProperty), start, *query.Datetime.Start))
+		}
+		if query.Datetime.End != nil {
+			filter = append(filter, sq.Expr(fmt.Sprintf("%s <= ?", timeProperty), start, *query.Datetime.End))
+		}
+		return filter
+	}
+
+	// a missing start or end leaves the feature's time range open
+	if query.Datetime.Start != nil {
+		filter = append(filter, sq.Expr(fmt.Sprintf("COALESCE(%s, 'infinity') >= ?", timeProperty), end, *query.Datetime.Start))
+	}
+	if query.Datetime.End != nil {
+		filter = append(filter, sq.Expr(fmt.Sprintf("COALESCE(%s, '-infinity') <= ?", timeProperty), start, *query.Datetime.End))
+	}
+	return filter
+}
+
+// propertyFilter matches features with a property equal to any of the
+// values.  Values that parse as JSON numbers, booleans, or null also match
+// properties of that type.
+func propertyFilter(name string, values []string) sq.Sqlizer {
+	filter := sq.Or{}
+	for _, value := range values {
+		candidates := []interface{}{value}
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(value), &parsed); err == nil {
+			switch parsed.(type) {
+			case float64, bool, nil:
+				candidates = append(candidates, parsed)
+			}
+		}
+
+		for _, candidate := range candidates {
+			filter = append(filter, sq.Expr(
+				fmt.Sprintf("%s @> ?", column(featureTable, "properties")),
+				PropertyMap{name: candidate},
+			))
+		}
+	}
+	return filter
 }
 
 var _ Querier = (*FeatureQuery)(nil)
 
 var featureTable = "features"
 
-var selectFeatures = builder.
-	Select(
-		column(featureTable, "id"),
-		alias(fmt.Sprintf("ST_AsGeoJSON(%s)", column(featureTable, "geometry")), "geometry"),
-		column(featureTable, "properties"),
-	).
-	From(featureTable)
+// timeProperty is the value of a time property.  Values that are not valid
+// timestamps are null (see pgfs_timestamptz), like missing values.
+var timeProperty = fmt.Sprintf("pgfs_timestamptz(%s->>?)", column(featureTable, "properties"))
+
+// selectFeatures selects features with geometries transformed to the query
+// CRS.  Geometries are simplified if the query tolerance (in CRS units) is
+// greater than zero, and coordinates are rounded to the query number of
+// decimal digits (9 if nil).  Geometries are null if skipped and properties
+// are limited to the query property names if not nil.
+func selectFeatures(query *FeatureQuery) sq.SelectBuilder {
+	crs := query.CRS
+	if crs.SRID == 0 {
+		crs = geo.CRS84
+	}
+
+	geometry := fmt.Sprintf("ST_Transform(%s, %d)", column(featureTable, "geometry"), crs.SRID)
+	if query.Tolerance > 0 {
+		geometry = fmt.Sprintf("ST_SimplifyPreserveTopology(%s, %s)", geometry, strconv.FormatFloat(query.Tolerance, 'g', -1, 64))
+	}
+	if crs.LatLon {
+		geometry = fmt.Sprintf("ST_FlipCoordinates(%s)", geometry)
+	}
+
+	maxDecimalDigits := defaultMaxDecimalDigits
+	if query.MaxDecimalDigits != nil {
+		maxDecimalDigits = *query.MaxDecimalDigits
+	}
+
+	geometry = fmt.Sprintf("ST_AsGeoJSON(%s, %d)", geometry, maxDecimalDigits)
+	if query.SkipGeometry {
+		geometry = "NULL"
+	}
+
+	builder := builder.
+		Select(
+			column(featureTable, "id"),
+			alias(geometry, "geometry"),
+			column(featureTable, "collection_name"),
+			sortValuesColumn(query.sortKeys()),
+		)
+
+	if query.PropertyNames == nil {
+		builder = builder.Columns(column(featureTable, "properties"))
+	} else {
+		pairs := make([]string, len(query.PropertyNames))
+		args := make([]interface{}, 2*len(query.PropertyNames))
+		for i, name := range query.PropertyNames {
+			pairs[i] = fmt.Sprintf("?::text, %s->?", column(featureTable, "properties"))
+			args[2*i] = name
+			args[2*i+1] = name
+		}
+		builder = builder.Column(alias(fmt.Sprintf("jsonb_build_object(%s)", strings.Join(pairs, ", ")), "properties"), args...)
+	}
+
+	return builder.From(featureTable)
+}
+
+// geometryValue transforms the GeoJSON geometry of a feature from its CRS to
+// the storage CRS of its collection, repairing it if needed
+func geometryValue(feature *Feature) sq.Sqlizer {
+	crs := feature.CRS
+	if crs.SRID == 0 {
+		crs = geo.CRS84
+	}
+
+	geometry := fmt.Sprintf("ST_SetSRID(ST_GeomFromGeoJSON(?), %d)", crs.SRID)
+	if crs.LatLon {
+		geometry = fmt.Sprintf("ST_FlipCoordinates(%s)", geometry)
+	}
+
+	geometry = fmt.Sprintf("ST_Transform(%s, (SELECT srid FROM %s WHERE name = ?))", geometry, collectionTable)
+	if feature.Validity != nil && feature.Validity.Repaired {
+		geometry = fmt.Sprintf("ST_MakeValid(%s)", geometry)
+	}
+
+	return sq.Expr(geometry, feature.Geometry, feature.CollectionName)
+}
 
 func getFeatureInsertSQL(feature *Feature) (string, []interface{}, error) {
 	feature.ID = uuid.New()
@@ -109,45 +338,151 @@ func getFeatureInsertSQL(feature *Feature) (string, []interface{}, error) {
 		SetMap(sq.Eq{
 			"id":              feature.ID,
 			"collection_name": feature.CollectionName,
-			"geometry":        sq.Expr("ST_SetSRID(ST_GeomFromGeoJSON(?), 4326)", feature.Geometry),
+			"geometry":        geometryValue(feature),
 			"properties":      feature.Properties,
 		}).ToSql()
 }
 
+// FeatureError describes a problem with the feature at an index in a list
+type FeatureError struct {
+	Index  int    `json:"index"`
+	Reason string `json:"reason"`
+}
+
+// GeometryError is returned when feature geometries are invalid or do not
+// match the geometry type or dimension required by their collection
+type GeometryError struct {
+	Features []*FeatureError
+}
+
+func (e *GeometryError) Error() string {
+	indexes := make([]string, len(e.Features))
+	for i, f := range e.Features {
+		indexes[i] = strconv.Itoa(f.Index)
+	}
+	return fmt.Sprintf("geometries of features at indexes %s cannot be saved", strings.Join(indexes, ", "))
+}
+
+// checkGeometries makes sure feature geometries match the constraints of
+// their collections and validates them according to the collection policy,
+// returning a *GeometryError if any cannot be saved
+func checkGeometries(db sqlx.Queryer, features Features) error {
+	collections := map[string]*Collection{}
+	geometryErr := &GeometryError{}
+
+	for i, feature := range features {
+		collection, ok := collections[feature.CollectionName]
+		if !ok {
+			sql, args, err := selectCollections.Where(sq.Eq{column(collectionTable, "name"): feature.CollectionName}).ToSql()
+			if err != nil {
+				return err
+			}
+			collection = &Collection{}
+			if err := sqlx.Get(db, collection, sql, args...); err != nil {
+				return err
+			}
+			collections[feature.CollectionName] = collection
+		}
+
+		if err := collection.CheckGeometry(&feature.Geometry); err != nil {
+			geometryErr.Features = append(geometryErr.Features, &FeatureError{Index: i, Reason: err.Error()})
+			continue
+		}
+
+		if err := validateGeometry(db, collection, feature); err != nil {
+			geometryErr.Features = append(geometryErr.Features, &FeatureError{Index: i, Reason: err.Error()})
+		}
+	}
+
+	if len(geometryErr.Features) > 0 {
+		return geometryErr
+	}
+	return nil
+}
+
 // insert persists a new feature
 func (feature *Feature) insert(db *sqlx.DB) error {
+	if err := checkGeometries(db, Features{feature}); err != nil {
+		return err
+	}
+
 	sql, args, sqlErr := getFeatureInsertSQL(feature)
 	if sqlErr != nil {
 		return sqlErr
 	}
-	_, execErr := db.Exec(sql, args...)
-	return execErr
+
+	return changeFeatures(db, feature.CollectionName, func(tx *sqlx.Tx) error {
+		_, err := tx.Exec(sql, args...)
+		return err
+	})
 }
 
 // get retrieves a single feature by ID
 func (feature *Feature) get(db *sqlx.DB) error {
-	sql, args, err := selectFeatures.Where(sq.Eq{column(featureTable, "id"): feature.ID}).ToSql()
+	sql, args, err := selectFeatures(&FeatureQuery{CRS: feature.CRS}).Where(sq.Eq{column(featureTable, "id"): feature.ID}).ToSql()
 	if err != nil {
 		return err
 	}
 	return db.Get(feature, sql, args...)
 }
 
-// update updates a feature's editable fields
+// update updates the editable fields of a feature in its collection, returning
+// sql.ErrNoRows if there is no matching feature
 func (feature *Feature) update(db *sqlx.DB) error {
+	if err := checkGeometries(db, Features{feature}); err != nil {
+		return err
+	}
+
 	sql, args, sqlErr := builder.
 		Update(featureTable).
 		SetMap(sq.Eq{
-			"geometry":   sq.Expr("ST_SetSRID(ST_GeomFromGeoJSON(?), 4326)", feature.Geometry),
+			"geometry":   geometryValue(feature),
 			"properties": feature.Properties,
+		}).
+		Where(sq.Eq{
+			"id":              feature.ID,
+			"collection_name": feature.CollectionName,
+		}).ToSql()
+
+	if sqlErr != nil {
+		return sqlErr
+	}
+
+	return changeFeatures(db, feature.CollectionName, func(tx *sqlx.Tx) error {
+		result, err := tx.Exec(sql, args...)
+		if err != nil {
+			return err
+		}
+		return requireRows(result)
+	})
+}
+
+// UpdateProperties replaces the properties of a feature without changing its
+// geometry, returning sql.ErrNoRows if there is no matching feature
+func UpdateProperties(db *sql.DB, feature *Feature) error {
+	return feature.updateProperties(sqlx.NewDb(db, driverName))
+}
+
+func (feature *Feature) updateProperties(db *sqlx.DB) error {
+	sql, args, sqlErr := builder.
+		Update(featureTable).
+		Set("properties", feature.Properties).
+		Where(sq.Eq{
+			"id":              feature.ID,
+			"collection_name": feature.CollectionName,
 		}).ToSql()
 
 	if sqlErr != nil {
 		return sqlErr
 	}
 
-	_, execErr := db.Exec(sql, args...)
-	return execErr
+	return changeFeatures(db, feature.CollectionName, func(tx *sqlx.Tx) error {
+		result, err := tx.Exec(sql, args...)
+		if err != nil {
+			return err
+		}
+		return requireRows(result)
+	})
 }
 
 // delete performs a delete
@@ -160,8 +495,10 @@ func (feature *Feature) delete(db *sqlx.DB) error {
 		return sqlErr
 	}
 
-	_, err := db.Exec(sql, args...)
-	return err
+	return changeFeatures(db, feature.CollectionName, func(tx *sqlx.Tx) error {
+		_, err := tx.Exec(sql, args...)
+		return err
+	})
 }
 
 // insert saves a list of features
@@ -178,18 +515,48 @@ func (features *Features) insert(db *sqlx.DB) error {
 		}
 	}()
 
-	for _, feature := range *features {
-		sql, args, err := getFeatureInsertSQL(feature)
+	collectionNames := map[string]bool{}
+	err = insertFeatures(tx, *features, collectionNames)
+	if err != nil {
+		return err
+	}
+
+	for name := range collectionNames {
+		err = refreshExtent(tx, name)
 		if err != nil {
 			return err
 		}
-		_, err = tx.Exec(sql, args...)
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+// insertFeatures checks and inserts features in a transaction, locking their
+// collections (see lockExtent) and adding their names to a set.  The extents
+// of the collections must be refreshed before committing.
+func insertFeatures(tx *sqlx.Tx, features Features, collectionNames map[string]bool) error {
+	if err := checkGeometries(tx, features); err != nil {
+		return err
+	}
+
+	for _, feature := range features {
+		if !collectionNames[feature.CollectionName] {
+			if err := lockExtent(tx, feature.CollectionName); err != nil {
+				return err
+			}
+		}
+		sql, args, err := getFeatureInsertSQL(feature)
 		if err != nil {
 			return err
 		}
+		if _, err := tx.Exec(sql, args...); err != nil {
+			return err
+		}
+		collectionNames[feature.CollectionName] = true
 	}
 
-	return tx.Commit()
+	return nil
 }
 
 // query gets a list of features
@@ -206,7 +573,12 @@ func (features *Features) query(db *sqlx.DB, query Querier) (bool, error) {
 		featureQuery = &FeatureQuery{}
 	}
 
-	sql, args, err := featureQuery.where(selectFeatures).ToSql()
+	builder, pageErr := featureQuery.page(selectFeatures(featureQuery))
+	if pageErr != nil {
+		return false, pageErr
+	}
+
+	sql, args, err := builder.ToSql()
 	if err != nil {
 		return false, err
 	}
@@ -227,5 +599,37 @@ func (features *Features) query(db *sqlx.DB, query Querier) (bool, error) {
 		*features = (*features)[:limit]
 	}
 
+	if featureQuery.backward() {
+		list := *features
+		for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+			list[i], list[j] = list[j], list[i]
+		}
+	}
+
 	return more, nil
 }
+
+// count gets the number of features that match a query, ignoring paging
+func (features *Features) count(db *sqlx.DB, query Querier) (uint64, error) {
+	featureQuery, ok := query.(*FeatureQuery)
+	if !ok {
+		return 0, errors.New("invalid feature query")
+	}
+
+	return countFeatures(db, featureQuery)
+}
+
+// countFeatures gets the number of features that match a query, ignoring paging
+func countFeatures(db sqlx.Queryer, query *FeatureQuery) (uint64, error) {
+	sql, args, err := query.filter(builder.Select("COUNT(*)").From(featureTable)).ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	var count uint64
+	if err := sqlx.Get(db, &count, sql, args...); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
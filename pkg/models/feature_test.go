@@ -0,0 +1,614 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/google/uuid"
+	geojson "github.com/paulmach/go.geojson"
+	"github.com/stretchr/testify/assert"
+	"github.com/tschaub/pgfs/pkg/cql"
+	"github.com/tschaub/pgfs/pkg/geo"
+	"github.com/tschaub/pgfs/pkg/temporal"
+)
+
+func mustGeometry(t *testing.T, data string) geo.Geometry {
+	var g geo.Geometry
+	if err := g.UnmarshalJSON([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func mustJSON(t *testing.T, g geo.Geometry) string {
+	data, err := g.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// setupFeatures creates two collections with two features in the first and
+// one feature in the second
+func setupFeatures(t *testing.T, db *sql.DB) Features {
+	for _, name := range []string{"first", "second"} {
+		collection := &Collection{Name: name, Title: name, Description: name}
+		if err := Insert(db, collection); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	features := Features{
+		{CollectionName: "first", Geometry: mustGeometry(t, `{"type":"Point","coordinates":[1,2]}`), Properties: PropertyMap{"name": "one"}},
+		{CollectionName: "first", Geometry: mustGeometry(t, `{"type":"Point","coordinates":[3,4]}`), Properties: PropertyMap{"name": "two"}},
+		{CollectionName: "second", Geometry: mustGeometry(t, `{"type":"Point","coordinates":[5,6]}`), Properties: PropertyMap{"name": "three"}},
+	}
+	if err := BulkInsert(db, &features); err != nil {
+		t.Fatal(err)
+	}
+
+	return features
+}
+
+func TestFeatureUpdate(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	features := setupFeatures(t, db)
+
+	updated := &Feature{
+		ID:             features[0].ID,
+		CollectionName: "first",
+		Geometry:       mustGeometry(t, `{"type":"Point","coordinates":[7,8]}`),
+		Properties:     PropertyMap{"name": "updated"},
+	}
+	assert.Nil(Update(db, updated))
+
+	got := &Feature{ID: features[0].ID}
+	assert.Nil(Get(db, got))
+	assert.Equal("updated", got.Properties["name"])
+	assert.Equal(`{"type":"Point","coordinates":[7,8]}`, mustJSON(t, got.Geometry))
+
+	for _, feature := range features[1:] {
+		other := &Feature{ID: feature.ID}
+		assert.Nil(Get(db, other))
+		assert.Equal(feature.Properties, other.Properties, "expected other features to be unchanged")
+		assert.Equal(mustJSON(t, feature.Geometry), mustJSON(t, other.Geometry), "expected other geometries to be unchanged")
+	}
+}
+
+func TestFeatureUpdateWrongCollection(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	features := setupFeatures(t, db)
+
+	updated := &Feature{
+		ID:             features[2].ID,
+		CollectionName: "first",
+		Geometry:       mustGeometry(t, `{"type":"Point","coordinates":[7,8]}`),
+		Properties:     PropertyMap{"name": "updated"},
+	}
+	assert.Equal(sql.ErrNoRows, Update(db, updated))
+
+	got := &Feature{ID: features[2].ID}
+	assert.Nil(Get(db, got))
+	assert.Equal("three", got.Properties["name"])
+}
+
+func TestFeatureUpdateMissing(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	features := setupFeatures(t, db)
+
+	missing := &Feature{
+		ID:             uuid.New(),
+		CollectionName: "first",
+		Geometry:       mustGeometry(t, `{"type":"Point","coordinates":[7,8]}`),
+		Properties:     PropertyMap{"name": "updated"},
+	}
+	assert.Equal(sql.ErrNoRows, Update(db, missing))
+
+	for _, feature := range features {
+		other := &Feature{ID: feature.ID}
+		assert.Nil(Get(db, other))
+		assert.Equal(feature.Properties, other.Properties, "expected features to be unchanged")
+	}
+}
+
+func TestFeatureQueryPaging(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	setupFeatures(t, db)
+
+	query := &FeatureQuery{Collection: Collection{Name: "first"}, Limit: 1}
+	page := Features{}
+	more, err := Query(db, &page, query)
+	assert.Nil(err)
+	assert.True(more)
+	assert.Len(page, 1)
+
+	next := Features{}
+	more, err = Query(db, &next, &FeatureQuery{Collection: Collection{Name: "first"}, Limit: 1, After: page[0]})
+	assert.Nil(err)
+	assert.False(more)
+	assert.Len(next, 1)
+
+	prev := Features{}
+	more, err = Query(db, &prev, &FeatureQuery{Collection: Collection{Name: "first"}, Limit: 1, Before: next[0]})
+	assert.Nil(err)
+	assert.False(more)
+	if assert.Len(prev, 1) {
+		assert.Equal(page[0].ID, prev[0].ID)
+	}
+
+	count, err := Count(db, &Features{}, query)
+	assert.Nil(err)
+	assert.Equal(uint64(2), count)
+}
+
+func TestFeatureCRS(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	assert.Nil(Insert(db, &Collection{Name: "mercator", Title: "mercator", Description: "mercator", SRID: 3857}))
+
+	feature := &Feature{
+		CollectionName: "mercator",
+		Geometry:       mustGeometry(t, `{"type":"Point","coordinates":[1,2]}`),
+		Properties:     PropertyMap{},
+		CRS:            geo.CRS{SRID: 4326, LatLon: true},
+	}
+	assert.Nil(Insert(db, feature))
+
+	point := func(feature *Feature) []float64 {
+		g, err := geojson.UnmarshalGeometry([]byte(mustJSON(t, feature.Geometry)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return g.Point
+	}
+
+	lonLat := &Feature{ID: feature.ID}
+	assert.Nil(Get(db, lonLat))
+	assert.InDeltaSlice([]float64{2, 1}, point(lonLat), 1e-6)
+
+	latLon := &Feature{ID: feature.ID, CRS: geo.CRS{SRID: 4326, LatLon: true}}
+	assert.Nil(Get(db, latLon))
+	assert.InDeltaSlice([]float64{1, 2}, point(latLon), 1e-6)
+}
+
+func TestFeatureQueryBBoxMercator(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	mercator := Collection{Name: "mercator", Title: "mercator", Description: "mercator", SRID: 3857}
+	assert.Nil(Insert(db, &mercator))
+	assert.Nil(Insert(db, &Feature{
+		CollectionName: "mercator",
+		Geometry:       mustGeometry(t, `{"type":"Point","coordinates":[1,2]}`),
+		Properties:     PropertyMap{},
+	}))
+
+	// the poles cannot be transformed to Web Mercator
+	boxes := []*geo.BBox{
+		{MinX: -180, MinY: -90, MaxX: 180, MaxY: 90, CRS: geo.CRS84},
+		{MinX: 170, MinY: -90, MaxX: 10, MaxY: 90, CRS: geo.CRS84},
+	}
+	for _, box := range boxes {
+		features := Features{}
+		_, err := Query(db, &features, &FeatureQuery{Collection: mercator, BBox: box})
+		if assert.Nil(err) {
+			assert.Len(features, 1)
+		}
+
+		count, countErr := Count(db, &Features{}, &FeatureQuery{Collection: mercator, BBox: box})
+		assert.Nil(countErr)
+		assert.Equal(uint64(1), count)
+	}
+}
+
+func TestFeaturesInsertGeometryType(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	assert.Nil(Insert(db, &Collection{Name: "points", Title: "points", Description: "points", GeometryType: "Point", Dimension: 2}))
+
+	features := Features{
+		{CollectionName: "points", Geometry: mustGeometry(t, `{"type":"Point","coordinates":[1,2]}`), Properties: PropertyMap{}},
+		{CollectionName: "points", Geometry: mustGeometry(t, `{"type":"LineString","coordinates":[[1,2],[3,4]]}`), Properties: PropertyMap{}},
+		{CollectionName: "points", Geometry: mustGeometry(t, `{"type":"Point","coordinates":[1,2,3]}`), Properties: PropertyMap{}},
+	}
+
+	err := BulkInsert(db, &features)
+	geometryErr, ok := err.(*GeometryError)
+	if assert.True(ok, "expected a geometry error, got %v", err) && assert.Len(geometryErr.Features, 2) {
+		assert.Equal(1, geometryErr.Features[0].Index)
+		assert.Equal(2, geometryErr.Features[1].Index)
+	}
+
+	count, countErr := Count(db, &Features{}, &FeatureQuery{Collection: Collection{Name: "points"}})
+	assert.Nil(countErr)
+	assert.Equal(uint64(0), count, "expected no features to be inserted")
+}
+
+func TestFeaturesInsertValidation(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+
+	bowtie := `{"type":"Polygon","coordinates":[[[0,0],[1,1],[1,0],[0,1],[0,0]]]}`
+	square := `{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1],[0,0]]]}`
+
+	for _, policy := range []string{ValidationReject, ValidationWarn, ValidationRepair} {
+		assert.Nil(Insert(db, &Collection{Name: policy, Title: policy, Description: policy, Validation: policy}))
+	}
+
+	rejected := Features{
+		{CollectionName: ValidationReject, Geometry: mustGeometry(t, square), Properties: PropertyMap{}},
+		{CollectionName: ValidationReject, Geometry: mustGeometry(t, bowtie), Properties: PropertyMap{}},
+	}
+	err := BulkInsert(db, &rejected)
+	geometryErr, ok := err.(*GeometryError)
+	if assert.True(ok, "expected a geometry error, got %v", err) && assert.Len(geometryErr.Features, 1) {
+		assert.Equal(1, geometryErr.Features[0].Index)
+	}
+
+	warned := Features{
+		{CollectionName: ValidationWarn, Geometry: mustGeometry(t, bowtie), Properties: PropertyMap{}},
+	}
+	assert.Nil(BulkInsert(db, &warned))
+	if assert.NotNil(warned[0].Validity) {
+		assert.False(warned[0].Validity.Valid)
+		assert.NotEmpty(warned[0].Validity.Reason)
+		assert.False(warned[0].Validity.Repaired)
+	}
+
+	repaired := Features{
+		{CollectionName: ValidationRepair, Geometry: mustGeometry(t, bowtie), Properties: PropertyMap{}},
+	}
+	assert.Nil(BulkInsert(db, &repaired))
+	if assert.NotNil(repaired[0].Validity) {
+		assert.True(repaired[0].Validity.Repaired)
+	}
+
+	stored := &Feature{ID: repaired[0].ID}
+	assert.Nil(Get(db, stored))
+	assert.Equal("MultiPolygon", stored.Geometry.Type())
+}
+
+func TestFeatureQueryProjection(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	features := setupFeatures(t, db)
+	features[0].Properties["population"] = 42.0
+	assert.Nil(Update(db, features[0]))
+
+	results := Features{}
+	_, err := Query(db, &results, &FeatureQuery{
+		Collection:    Collection{Name: "first"},
+		ID:            features[0].ID,
+		PropertyNames: []string{"population"},
+		SkipGeometry:  true,
+	})
+	assert.Nil(err)
+	if assert.Len(results, 1) {
+		assert.Equal(PropertyMap{"population": 42.0}, results[0].Properties)
+		assert.True(results[0].Geometry.Empty())
+	}
+}
+
+func mustInterval(t *testing.T, value string) *temporal.Interval {
+	interval, err := temporal.ParseInterval(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return interval
+}
+
+func TestDatetimeFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	query := &FeatureQuery{
+		Collection: Collection{TimeProperty: "when"},
+		Datetime:   mustInterval(t, "2020-01-01T00:00:00Z/.."),
+	}
+	sql, args, err := query.datetimeFilter().ToSql()
+	assert.Nil(err)
+	assert.Equal("(pgfs_timestamptz(features.properties->>?) >= ?)", sql)
+	assert.Equal([]interface{}{"when", *query.Datetime.Start}, args)
+
+	query.Collection.TimeEndProperty = "until"
+	query.Datetime = mustInterval(t, "2020-01-01")
+	sql, args, err = query.datetimeFilter().ToSql()
+	assert.Nil(err)
+	assert.Equal("(COALESCE(pgfs_timestamptz(features.properties->>?), 'infinity') >= ? AND COALESCE(pgfs_timestamptz(features.properties->>?), '-infinity') <= ?)", sql)
+	assert.Equal([]interface{}{"until", *query.Datetime.Start, "when", *query.Datetime.End}, args)
+}
+
+func TestFeatureQueryDatetime(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	events := Collection{Name: "events", Title: "events", Description: "events", TimeProperty: "when"}
+	spans := Collection{Name: "spans", Title: "spans", Description: "spans", TimeProperty: "start", TimeEndProperty: "end"}
+	assert.Nil(Insert(db, &events))
+	assert.Nil(Insert(db, &spans))
+
+	features := Features{}
+	for i, when := range []interface{}{"2020-01-01T00:00:00Z", "2020-06-01T12:00:00Z", "2021-01-01T00:00:00Z", "not a time", "2020-13-45", 42.0, nil} {
+		features = append(features, &Feature{
+			CollectionName: "events",
+			Geometry:       mustGeometry(t, `{"type":"Point","coordinates":[1,2]}`),
+			Properties:     PropertyMap{"when": when, "rank": float64(i)},
+		})
+	}
+	for i, span := range [][]interface{}{
+		{"2020-01-01T00:00:00Z", "2020-02-01T00:00:00Z"},
+		{"2020-03-01T00:00:00Z", nil},
+		{nil, "2019-12-01T00:00:00Z"},
+		{"bad", "worse"},
+	} {
+		features = append(features, &Feature{
+			CollectionName: "spans",
+			Geometry:       mustGeometry(t, `{"type":"Point","coordinates":[1,2]}`),
+			Properties:     PropertyMap{"start": span[0], "end": span[1], "rank": float64(i)},
+		})
+	}
+	assert.Nil(BulkInsert(db, &features))
+
+	ranks := func(collection Collection, datetime string) []float64 {
+		results := Features{}
+		_, err := Query(db, &results, &FeatureQuery{
+			Collection: collection,
+			Datetime:   mustInterval(t, datetime),
+			SortBy:     []SortKey{{Property: "rank"}},
+		})
+		if !assert.Nil(err, datetime) {
+			return nil
+		}
+		values := []float64{}
+		for _, feature := range results {
+			values = append(values, feature.Properties["rank"].(float64))
+		}
+		return values
+	}
+
+	// times that are not valid are ignored (a single time never matches and
+	// a range without a valid start or end is open)
+	cases := []struct {
+		collection Collection
+		datetime   string
+		ranks      []float64
+	}{
+		{events, "2020-06-01T12:00:00Z", []float64{1}},
+		{events, "2020-01-01", []float64{0}},
+		{events, "2020-01-01T00:00:00Z/2020-06-01T12:00:00Z", []float64{0, 1}},
+		{events, "2020-01-01T00:00:01Z/2021-01-01T00:00:00Z", []float64{1, 2}},
+		{events, "../2020-06-01T12:00:00Z", []float64{0, 1}},
+		{events, "2020-06-01T12:00:00Z/..", []float64{1, 2}},
+		{events, "/2019-01-01", []float64{}},
+		{spans, "2020-01-15", []float64{0, 3}},
+		{spans, "2030-01-01/..", []float64{1, 3}},
+		{spans, "../2019-12-01T00:00:00Z", []float64{2, 3}},
+		{spans, "2019-01-01/2020-03-01T00:00:00Z", []float64{0, 1, 2, 3}},
+	}
+	for _, c := range cases {
+		assert.Equal(c.ranks, ranks(c.collection, c.datetime), "%s %s", c.collection.Name, c.datetime)
+	}
+}
+
+func TestFeatureQueryFilterTime(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	events := Collection{Name: "events", Title: "events", Description: "events"}
+	assert.Nil(Insert(db, &events))
+
+	features := Features{}
+	for i, when := range []interface{}{"2020-01-01", "2020-06-01T12:00:00Z", "not a time", "2020-13-45", 42.0} {
+		features = append(features, &Feature{
+			CollectionName: "events",
+			Geometry:       mustGeometry(t, `{"type":"Point","coordinates":[1,2]}`),
+			Properties:     PropertyMap{"when": when, "rank": float64(i)},
+		})
+	}
+	assert.Nil(BulkInsert(db, &features))
+
+	// values that are not dates or timestamps never match
+	cases := []struct {
+		filter string
+		ranks  []float64
+	}{
+		{"when > TIMESTAMP('2020-03-01T00:00:00Z')", []float64{1}},
+		{"when = DATE('2020-01-01')", []float64{0}},
+		{"when BETWEEN DATE('2019-01-01') AND DATE('2021-01-01')", []float64{0, 1}},
+		{"T_AFTER(when, TIMESTAMP('2020-03-01T00:00:00Z'))", []float64{1}},
+		{"T_DURING(when, INTERVAL('2019-01-01T00:00:00Z', '..'))", []float64{0, 1}},
+		{"T_BEFORE(INTERVAL(when, '2020-07-01T00:00:00Z'), DATE('2021-01-01'))", []float64{0, 1}},
+	}
+
+	for _, c := range cases {
+		filter, err := cql.Parse(c.filter)
+		if !assert.Nil(err, c.filter) {
+			continue
+		}
+		results := Features{}
+		_, queryErr := Query(db, &results, &FeatureQuery{Collection: events, Filter: filter, SortBy: []SortKey{{Property: "rank"}}})
+		if !assert.Nil(queryErr, c.filter) {
+			continue
+		}
+		ranks := []float64{}
+		for _, feature := range results {
+			ranks = append(ranks, feature.Properties["rank"].(float64))
+		}
+		assert.Equal(c.ranks, ranks, c.filter)
+	}
+}
+
+func TestFeatureQuerySortBy(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	assert.Nil(Insert(db, &Collection{Name: "places", Title: "places", Description: "places", Sortables: []string{"rank"}}))
+
+	features := Features{}
+	for _, rank := range []float64{2, 1, 2, 3, 1} {
+		features = append(features, &Feature{
+			CollectionName: "places",
+			Geometry:       mustGeometry(t, `{"type":"Point","coordinates":[1,2]}`),
+			Properties:     PropertyMap{"rank": rank},
+		})
+	}
+	assert.Nil(BulkInsert(db, &features))
+
+	ranks := []float64{}
+	var after *Feature
+	for {
+		page := Features{}
+		more, err := Query(db, &page, &FeatureQuery{
+			Collection: Collection{Name: "places"},
+			Limit:      2,
+			After:      after,
+			SortBy:     []SortKey{{Property: "rank", Descending: true}},
+		})
+		assert.Nil(err)
+		for _, feature := range page {
+			ranks = append(ranks, feature.Properties["rank"].(float64))
+		}
+		if !more {
+			break
+		}
+		after = page[len(page)-1]
+	}
+
+	assert.Equal([]float64{3, 2, 2, 1, 1}, ranks)
+}
+
+func TestFeatureQueryCursor(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	assert.Nil(Insert(db, &Collection{Name: "places", Title: "places", Description: "places", Sortables: []string{"rank"}}))
+
+	features := Features{}
+	for _, rank := range []float64{2, 1, 2, 3, 1} {
+		features = append(features, &Feature{
+			CollectionName: "places",
+			Geometry:       mustGeometry(t, `{"type":"Point","coordinates":[1,2]}`),
+			Properties:     PropertyMap{"rank": rank},
+		})
+	}
+	assert.Nil(BulkInsert(db, &features))
+
+	sortBy := []SortKey{{Property: "rank", Descending: true}}
+	first := Features{}
+	_, err := Query(db, &first, &FeatureQuery{Collection: Collection{Name: "places"}, Limit: 2, SortBy: sortBy})
+	assert.Nil(err)
+	if !assert.Len(first, 2) {
+		return
+	}
+
+	// paging continues after the last feature is deleted
+	last := first[len(first)-1]
+	assert.Nil(Delete(db, last))
+
+	next := Features{}
+	more, err := Query(db, &next, &FeatureQuery{
+		Collection: Collection{Name: "places"},
+		Limit:      2,
+		SortBy:     sortBy,
+		Cursor:     &Cursor{Values: last.SortValues},
+	})
+	assert.Nil(err)
+	assert.True(more)
+	ranks := []float64{}
+	for _, feature := range next {
+		ranks = append(ranks, feature.Properties["rank"].(float64))
+	}
+	assert.Equal([]float64{2, 1}, ranks)
+
+	previous := Features{}
+	_, err = Query(db, &previous, &FeatureQuery{
+		Collection: Collection{Name: "places"},
+		Limit:      2,
+		SortBy:     sortBy,
+		Cursor:     &Cursor{Values: next[0].SortValues, Backward: true},
+	})
+	assert.Nil(err)
+	if assert.Len(previous, 1) {
+		assert.Equal(first[0].ID, previous[0].ID)
+	}
+
+	_, err = Query(db, &Features{}, &FeatureQuery{
+		Collection: Collection{Name: "places"},
+		Cursor:     &Cursor{Values: last.SortValues},
+	})
+	assert.Equal(ErrCursorMismatch, err)
+}
+
+func TestFeatureInserter(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	assert.Nil(Insert(db, &Collection{Name: "places", Title: "places", Description: "places", GeometryType: "Point"}))
+
+	newFeatures := func(count int) Features {
+		features := Features{}
+		for i := 0; i < count; i++ {
+			features = append(features, &Feature{
+				CollectionName: "places",
+				Geometry:       mustGeometry(t, `{"type":"Point","coordinates":[1,2]}`),
+				Properties:     PropertyMap{},
+			})
+		}
+		return features
+	}
+
+	count := func() uint64 {
+		matched, err := Count(db, &Features{}, &FeatureQuery{Collection: Collection{Name: "places"}})
+		assert.Nil(err)
+		return matched
+	}
+
+	inserter, err := NewFeatureInserter(db)
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Nil(inserter.Insert(newFeatures(2)))
+	assert.Nil(inserter.Insert(newFeatures(3)))
+	assert.Equal(uint64(0), count())
+	assert.Nil(inserter.Commit())
+	assert.Equal(uint64(5), count())
+
+	inserter, err = NewFeatureInserter(db)
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Nil(inserter.Insert(newFeatures(2)))
+	line := newFeatures(1)
+	line[0].Geometry = mustGeometry(t, `{"type":"LineString","coordinates":[[1,2],[3,4]]}`)
+	insertErr := inserter.Insert(line)
+	if geometryErr, ok := insertErr.(*GeometryError); assert.True(ok) {
+		assert.Equal(0, geometryErr.Features[0].Index)
+	}
+	assert.Nil(inserter.Rollback())
+	assert.Equal(uint64(5), count())
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// FeatureInserter adds features in batches so that large inputs do not need
+// to be held in memory.  All batches are inserted in a single transaction,
+// so nothing is saved unless Commit is called.  Other changes to the features
+// of a collection wait until the inserter is done.
+type FeatureInserter struct {
+	tx              *sqlx.Tx
+	collectionNames map[string]bool
+}
+
+// NewFeatureInserter starts a transaction for inserting features
+func NewFeatureInserter(db *sql.DB) (*FeatureInserter, error) {
+	tx, err := sqlx.NewDb(db, driverName).Beginx()
+	if err != nil {
+		return nil, err
+	}
+	return &FeatureInserter{tx: tx, collectionNames: map[string]bool{}}, nil
+}
+
+// Insert adds a batch of features.  A *GeometryError is returned if any
+// geometries are rejected (with indexes relative to the batch).
+func (inserter *FeatureInserter) Insert(features Features) error {
+	return insertFeatures(inserter.tx, features, inserter.collectionNames)
+}
+
+// Check validates the geometries of a batch of features without inserting
+// them, returning a *GeometryError as Insert does.  This allows problems to
+// be reported for a whole input after a batch has been rejected.
+func (inserter *FeatureInserter) Check(features Features) error {
+	return checkGeometries(inserter.tx, features)
+}
+
+// Commit saves all inserted features
+func (inserter *FeatureInserter) Commit() error {
+	for name := range inserter.collectionNames {
+		if err := refreshExtent(inserter.tx, name); err != nil {
+			inserter.tx.Rollback()
+			return err
+		}
+	}
+	return inserter.tx.Commit()
+}
+
+// Rollback discards all inserted features
+func (inserter *FeatureInserter) Rollback() error {
+	return inserter.tx.Rollback()
+}
@@ -23,9 +23,71 @@ CREATE TABLE IF NOT EXISTS features (
 );
 CREATE INDEX IF NOT EXISTS features_collection_name_idx ON features(collection_name);
 CREATE INDEX IF NOT EXISTS features_geometry_idx ON features USING GIST(geometry);
+
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS time_property TEXT NOT NULL DEFAULT '';
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS time_end_property TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS queryables TEXT[];
+CREATE INDEX IF NOT EXISTS features_properties_idx ON features USING GIN(properties jsonb_path_ops);
+
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS extent_valid BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS extent_min_x DOUBLE PRECISION;
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS extent_min_y DOUBLE PRECISION;
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS extent_max_x DOUBLE PRECISION;
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS extent_max_y DOUBLE PRECISION;
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS extent_start TIMESTAMPTZ;
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS extent_end TIMESTAMPTZ;
+
+CREATE OR REPLACE FUNCTION pgfs_timestamptz(value TEXT) RETURNS TIMESTAMPTZ AS $$
+BEGIN
+	RETURN value::TIMESTAMPTZ;
+EXCEPTION WHEN data_exception THEN
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+CREATE OR REPLACE FUNCTION pgfs_date(value TEXT) RETURNS DATE AS $$
+BEGIN
+	RETURN value::DATE;
+EXCEPTION WHEN data_exception THEN
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS srid INTEGER NOT NULL DEFAULT 4326;
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS crs TEXT[];
+
+-- allow any SRID in the geometry column (only once, since it rewrites the table)
+DO $$
+BEGIN
+	IF EXISTS (
+		SELECT 1 FROM geometry_columns
+		WHERE f_table_schema = current_schema() AND f_table_name = 'features' AND f_geometry_column = 'geometry' AND srid <> 0
+	) THEN
+		ALTER TABLE features ALTER COLUMN geometry TYPE GEOMETRY(GEOMETRY);
+	END IF;
+END
+$$;
+
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS geometry_type TEXT NOT NULL DEFAULT '';
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS dimension INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS validation TEXT NOT NULL DEFAULT 'warn';
+
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS sortables TEXT[];
+
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS tile_properties TEXT[];
+
+CREATE TABLE IF NOT EXISTS tileset_layers (
+	collection_name TEXT PRIMARY KEY REFERENCES collections(name) ON DELETE CASCADE,
+	position INTEGER NOT NULL,
+	min_zoom INTEGER NOT NULL,
+	max_zoom INTEGER NOT NULL
+);
 `
 
 var drop = `
+DROP TABLE tileset_layers;
 DROP TABLE features;
 DROP TABLE collections;
 `
@@ -33,8 +95,13 @@ DROP TABLE collections;
 // Migrate updates the database
 func Migrate(db *sql.DB) error {
 	sqlxDB := sqlx.NewDb(db, driverName)
-	_, err := sqlxDB.Exec(create)
+	if _, err := sqlxDB.Exec(create); err != nil {
+		return err
+	}
 
+	// extents are computed when features change, so any missing from an
+	// earlier version are computed now
+	_, err := sqlxDB.Exec(refreshStaleExtentsSQL)
 	return err
 }
 
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrateAgain(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+
+	var srid int
+	assert.Nil(db.QueryRow("SELECT srid FROM geometry_columns WHERE f_table_name = 'features'").Scan(&srid))
+	assert.Equal(0, srid, "expected geometries with any SRID")
+
+	// a table rewrite would change the file node of the features table
+	var before, after uint32
+	assert.Nil(db.QueryRow("SELECT relfilenode FROM pg_class WHERE relname = 'features'").Scan(&before))
+	assert.Nil(Migrate(db))
+	assert.Nil(db.QueryRow("SELECT relfilenode FROM pg_class WHERE relname = 'features'").Scan(&after))
+	assert.Equal(before, after, "expected the features table not to be rewritten")
+}
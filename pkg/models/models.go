@@ -20,7 +20,7 @@ type Record interface {
 
 // Querier builds quieries
 type Querier interface {
-	where(sq.SelectBuilder) sq.SelectBuilder
+	where(sq.SelectBuilder) (sq.SelectBuilder, error)
 }
 
 // RecordSet represents a set of database records
@@ -28,6 +28,11 @@ type RecordSet interface {
 	query(*sqlx.DB, Querier) (bool, error)
 }
 
+// Countable represents a set of records that can be counted
+type Countable interface {
+	count(*sqlx.DB, Querier) (uint64, error)
+}
+
 // BulkInsertable represents a set of records that can be inserted in bulk
 type BulkInsertable interface {
 	insert(*sqlx.DB) error
@@ -62,3 +67,8 @@ func Query(db *sql.DB, records RecordSet, query Querier) (bool, error) {
 func BulkInsert(db *sql.DB, records BulkInsertable) error {
 	return records.insert(sqlx.NewDb(db, driverName))
 }
+
+// Count gets the number of records that match a query
+func Count(db *sql.DB, records Countable, query Querier) (uint64, error) {
+	return records.count(sqlx.NewDb(db, driverName), query)
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	_ "github.com/lib/pq" // only works with postgres
+)
+
+// testConnectionEnv names the environment variable with a connection string
+// for a Postgres server with PostGIS.  Integration tests are skipped if unset.
+const testConnectionEnv = "PGFS_TEST_DATABASE"
+
+// testDB creates a disposable database and returns a connection to it along
+// with a function to drop it when done
+func testDB(t *testing.T) (*sql.DB, func()) {
+	connection := os.Getenv(testConnectionEnv)
+	if connection == "" {
+		t.Skipf("set %s to run integration tests", testConnectionEnv)
+	}
+
+	admin, err := sql.Open(driverName, connection)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		t.Fatal(err)
+	}
+	name := "pgfs_test_" + hex.EncodeToString(suffix)
+
+	if _, err := admin.Exec(fmt.Sprintf("CREATE DATABASE %s", name)); err != nil {
+		admin.Close()
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open(driverName, withDatabase(t, connection, name))
+	if err != nil {
+		admin.Close()
+		t.Fatal(err)
+	}
+
+	cleanup := func() {
+		db.Close()
+		if _, err := admin.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS %s", name)); err != nil {
+			t.Error(err)
+		}
+		admin.Close()
+	}
+
+	if err := Migrate(db); err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+
+	return db, cleanup
+}
+
+// withDatabase returns a connection string (URL or key=value) for another database
+func withDatabase(t *testing.T, connection string, name string) string {
+	if strings.HasPrefix(connection, "postgres://") || strings.HasPrefix(connection, "postgresql://") {
+		u, err := url.Parse(connection)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u.Path = "/" + name
+		return u.String()
+	}
+	return fmt.Sprintf("%s dbname=%s", connection, name)
+}
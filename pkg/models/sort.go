@@ -0,0 +1,212 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+)
+
+// SortKey orders features by a property (or the feature ID)
+type SortKey struct {
+	Property   string
+	Descending bool
+}
+
+// IDProperty is the name used to sort by feature identifier
+const IDProperty = "id"
+
+// SortValues are the JSON values of the sort keys (including the ID) for a feature
+type SortValues []json.RawMessage
+
+// Scan implements the sql.Scanner interface
+func (v *SortValues) Scan(src interface{}) error {
+	source, ok := src.([]byte)
+	if !ok {
+		return errors.New("type assertion .([]byte) for SortValues failed")
+	}
+	return json.Unmarshal(source, v)
+}
+
+// Cursor is a position in a list of features ordered by sort keys
+type Cursor struct {
+	// Values of the sort keys for the feature before the position (or after
+	// it when paging backward)
+	Values SortValues
+	// Backward is true when paging toward the start of the list
+	Backward bool
+}
+
+// backward is true if the query pages toward the start of the list
+func (query *FeatureQuery) backward() bool {
+	if query.Cursor != nil {
+		return query.Cursor.Backward
+	}
+	return query.After == nil && query.Before != nil
+}
+
+// sortKeys returns the keys used to order features.  The ID is added as a
+// final key so that the order is stable when sorting by non-unique
+// properties.  The direction of each key is reversed when paging backward.
+func (query *FeatureQuery) sortKeys() []SortKey {
+	keys := []SortKey{}
+	hasID := false
+	for _, key := range query.SortBy {
+		keys = append(keys, key)
+		if key.Property == IDProperty {
+			hasID = true
+			break
+		}
+	}
+	if !hasID {
+		keys = append(keys, SortKey{Property: IDProperty})
+	}
+
+	if query.backward() {
+		for i := range keys {
+			keys[i].Descending = !keys[i].Descending
+		}
+	}
+
+	return keys
+}
+
+// sortExpression returns the SQL used to order features by a key.  Property
+// values are compared as JSONB with missing values treated as JSON null.
+func sortExpression(key SortKey) string {
+	if key.Property == IDProperty {
+		return column(featureTable, "id")
+	}
+	return fmt.Sprintf("COALESCE(%s->%s, 'null'::jsonb)", column(featureTable, "properties"), quoteLiteral(key.Property))
+}
+
+// sortValue returns the SQL for the value of a key for the feature with an ID
+func sortValue(key SortKey, id uuid.UUID) (string, []interface{}) {
+	if key.Property == IDProperty {
+		return "?", []interface{}{id}
+	}
+	sql := fmt.Sprintf("(SELECT COALESCE(anchor.properties->?, 'null'::jsonb) FROM %s AS anchor WHERE anchor.id = ?)", featureTable)
+	return sql, []interface{}{key.Property, id}
+}
+
+// cursorValue returns the SQL for a JSON sort value from a cursor
+func cursorValue(key SortKey, value json.RawMessage) (string, []interface{}, error) {
+	if key.Property == IDProperty {
+		var id uuid.UUID
+		if err := json.Unmarshal(value, &id); err != nil {
+			return "", nil, &CursorError{Key: key.Property, Reason: err.Error()}
+		}
+		return "?", []interface{}{id}, nil
+	}
+	return "?::jsonb", []interface{}{string(value)}, nil
+}
+
+// sortValuesColumn returns the SQL to select the values of the sort keys
+func sortValuesColumn(keys []SortKey) string {
+	expressions := make([]string, len(keys))
+	for i, key := range keys {
+		expressions[i] = sortExpression(key)
+		if key.Property == IDProperty {
+			expressions[i] = fmt.Sprintf("to_jsonb(%s)", expressions[i])
+		}
+	}
+	return alias(fmt.Sprintf("jsonb_build_array(%s)", strings.Join(expressions, ", ")), "sort_values")
+}
+
+// orderBy returns ORDER BY clauses for the keys
+func orderBy(keys []SortKey) []string {
+	clauses := make([]string, len(keys))
+	for i, key := range keys {
+		direction := "ASC"
+		if key.Descending {
+			direction = "DESC"
+		}
+		clauses[i] = fmt.Sprintf("%s %s", sortExpression(key), direction)
+	}
+	return clauses
+}
+
+// pageOrderBy returns ORDER BY clauses for the keys using the sort values
+// selected for a page of features (see sortValuesColumn)
+func pageOrderBy(keys []SortKey, page string) []string {
+	clauses := make([]string, len(keys))
+	for i, key := range keys {
+		direction := "ASC"
+		if key.Descending {
+			direction = "DESC"
+		}
+		value := fmt.Sprintf("%s.sort_values->%d", page, i)
+		if key.Property == IDProperty {
+			value = fmt.Sprintf("(%s.sort_values->>%d)::uuid", page, i)
+		}
+		clauses[i] = fmt.Sprintf("%s %s", value, direction)
+	}
+	return clauses
+}
+
+// keyset matches features that come after a position when ordered by the
+// keys.  The value function returns the SQL for the value of each key at the
+// position.
+func keyset(keys []SortKey, value func(SortKey, int) (string, []interface{})) sq.Sqlizer {
+	after := sq.Or{}
+	for i, key := range keys {
+		condition := sq.And{}
+		for j, previous := range keys[:i] {
+			sql, args := value(previous, j)
+			condition = append(condition, sq.Expr(fmt.Sprintf("%s = %s", sortExpression(previous), sql), args...))
+		}
+
+		op := ">"
+		if key.Descending {
+			op = "<"
+		}
+		sql, args := value(key, i)
+		condition = append(condition, sq.Expr(fmt.Sprintf("%s %s %s", sortExpression(key), op, sql), args...))
+		after = append(after, condition)
+	}
+	return after
+}
+
+// featureKeyset matches features that come after the feature with an ID
+func featureKeyset(keys []SortKey, id uuid.UUID) sq.Sqlizer {
+	return keyset(keys, func(key SortKey, i int) (string, []interface{}) {
+		return sortValue(key, id)
+	})
+}
+
+// cursorKeyset matches features that come after a cursor
+func cursorKeyset(keys []SortKey, cursor *Cursor) (sq.Sqlizer, error) {
+	if len(cursor.Values) != len(keys) {
+		return nil, ErrCursorMismatch
+	}
+
+	sqls := make([]string, len(keys))
+	args := make([][]interface{}, len(keys))
+	for i, key := range keys {
+		var err error
+		sqls[i], args[i], err = cursorValue(key, cursor.Values[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return keyset(keys, func(key SortKey, i int) (string, []interface{}) {
+		return sqls[i], args[i]
+	}), nil
+}
+
+// ErrCursorMismatch is returned when a cursor does not have a value for each sort key
+var ErrCursorMismatch = errors.New("cursor does not match the sort order")
+
+// CursorError is returned when a cursor value cannot be used for a sort key
+type CursorError struct {
+	Key    string
+	Reason string
+}
+
+func (e *CursorError) Error() string {
+	return fmt.Sprintf("bad cursor value for '%s': %s", e.Key, e.Reason)
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	query := &FeatureQuery{}
+	assert.Equal([]SortKey{{Property: "id"}}, query.sortKeys())
+
+	query = &FeatureQuery{SortBy: []SortKey{{Property: "name", Descending: true}}}
+	assert.Equal([]SortKey{{Property: "name", Descending: true}, {Property: "id"}}, query.sortKeys())
+
+	query = &FeatureQuery{SortBy: []SortKey{{Property: "id", Descending: true}, {Property: "name"}}}
+	assert.Equal([]SortKey{{Property: "id", Descending: true}}, query.sortKeys())
+
+	query = &FeatureQuery{SortBy: []SortKey{{Property: "name"}}, Before: &Feature{}}
+	assert.Equal([]SortKey{{Property: "name", Descending: true}, {Property: "id", Descending: true}}, query.sortKeys())
+}
+
+func TestOrderBy(t *testing.T) {
+	assert.Equal(t, []string{
+		"COALESCE(features.properties->'name', 'null'::jsonb) DESC",
+		"features.id ASC",
+	}, orderBy([]SortKey{{Property: "name", Descending: true}, {Property: "id"}}))
+}
+
+func TestKeyset(t *testing.T) {
+	assert := assert.New(t)
+
+	id := uuid.New()
+	sql, args, err := featureKeyset([]SortKey{{Property: "name", Descending: true}, {Property: "id"}}, id).ToSql()
+	assert.Nil(err)
+
+	name := "COALESCE(features.properties->'name', 'null'::jsonb)"
+	value := "(SELECT COALESCE(anchor.properties->?, 'null'::jsonb) FROM features AS anchor WHERE anchor.id = ?)"
+	assert.Equal("(("+name+" < "+value+") OR ("+name+" = "+value+" AND features.id > ?))", sql)
+	assert.Equal([]interface{}{"name", id, "name", id, id}, args)
+}
+
+func TestQuoteLiteral(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(`'name'`, quoteLiteral("name"))
+	assert.Equal(`'it''s'`, quoteLiteral("it's"))
+	assert.Equal(` E'back\\slash'`, quoteLiteral(`back\slash`))
+}
+
+func TestCursorKeyset(t *testing.T) {
+	assert := assert.New(t)
+
+	id := uuid.New()
+	keys := []SortKey{{Property: "name"}, {Property: "id"}}
+	cursor := &Cursor{Values: SortValues{json.RawMessage(`"Paris"`), json.RawMessage(`"` + id.String() + `"`)}}
+
+	after, err := cursorKeyset(keys, cursor)
+	if assert.Nil(err) {
+		sql, args, sqlErr := after.ToSql()
+		assert.Nil(sqlErr)
+		name := "COALESCE(features.properties->'name', 'null'::jsonb)"
+		assert.Equal("(("+name+" > ?::jsonb) OR ("+name+" = ?::jsonb AND features.id > ?))", sql)
+		assert.Equal([]interface{}{`"Paris"`, `"Paris"`, id}, args)
+	}
+
+	_, err = cursorKeyset(keys, &Cursor{Values: SortValues{json.RawMessage(`"Paris"`)}})
+	assert.Equal(ErrCursorMismatch, err)
+
+	_, err = cursorKeyset(keys, &Cursor{Values: SortValues{json.RawMessage(`"Paris"`), json.RawMessage(`42`)}})
+	_, ok := err.(*CursorError)
+	assert.True(ok)
+}
+
+func TestSortValuesColumn(t *testing.T) {
+	assert.Equal(t,
+		"jsonb_build_array(COALESCE(features.properties->'name', 'null'::jsonb), to_jsonb(features.id)) as sort_values",
+		sortValuesColumn([]SortKey{{Property: "name"}, {Property: "id"}}),
+	)
+}
+
+func TestPageOrderBy(t *testing.T) {
+	assert.Equal(t,
+		[]string{"page.sort_values->0 DESC", "(page.sort_values->>1)::uuid ASC"},
+		pageOrderBy([]SortKey{{Property: "rank", Descending: true}, {Property: "id"}}, "page"),
+	)
+}
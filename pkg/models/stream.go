@@ -0,0 +1,283 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+)
+
+// Page describes the features returned by a stream.  The number of matched
+// features is known when the stream starts and the rest is known when it is
+// opened.
+type Page struct {
+	// Matched is the number of features that match the query filters
+	Matched uint64
+	// Returned is the number of features in the page
+	Returned int
+	// More is true if there are features after the page (or before it when
+	// paging backward)
+	More bool
+	// First has the sort values of the first feature in the page
+	First SortValues
+	// Last has the sort values of the last feature in the page
+	Last SortValues
+}
+
+// FeatureStream iterates over a page of features without loading them all
+// into memory.  The page is read in a single snapshot of the database, so the
+// Page description matches the features returned by the stream.  Features are
+// not selected until the stream is opened, so other queries can be made in
+// the same snapshot before then.  A stream must be closed when it is no longer
+// needed.
+type FeatureStream struct {
+	Page  *Page
+	query *FeatureQuery
+	tx    *sqlx.Tx
+	sql   string
+	args  []interface{}
+	rows  *sqlx.Rows
+	err   error
+	// ready is true if the first feature was read when opening the stream
+	ready bool
+}
+
+// Stream starts reading the features that match a query.  Features are
+// always returned in order, including when paging backward.
+func Stream(db *sql.DB, query *FeatureQuery) (*FeatureStream, error) {
+	return streamFeatures(sqlx.NewDb(db, driverName), query)
+}
+
+func streamFeatures(db *sqlx.DB, query *FeatureQuery) (*FeatureStream, error) {
+	// the query is built first so that bad cursors are reported before
+	// starting a transaction
+	pageSQL, args, sqlErr := selectPage(query)
+	if sqlErr != nil {
+		return nil, sqlErr
+	}
+
+	tx, txErr := db.BeginTxx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if txErr != nil {
+		return nil, txErr
+	}
+
+	stream := &FeatureStream{query: query, tx: tx}
+
+	matched, countErr := countFeatures(tx, query)
+	if countErr != nil {
+		stream.Close()
+		return nil, countErr
+	}
+	stream.Page = &Page{Matched: matched}
+	if query.All {
+		stream.Page.Returned = int(matched)
+	}
+
+	stream.sql = pageSQL
+	stream.args = args
+	return stream, nil
+}
+
+// selectPage returns the SQL that selects the features of a page in order.
+// Each feature of a page is selected with the number of features found (up to
+// one more than the limit) and the sort values at the start and end of the
+// page (see pageRow).  All matching features are selected when streaming all
+// features.
+func selectPage(query *FeatureQuery) (string, []interface{}, error) {
+	if query.All {
+		builder, err := query.where(selectFeatures(query))
+		if err != nil {
+			return "", nil, err
+		}
+		return builder.ToSql()
+	}
+
+	builder, pageErr := query.page(selectFeatures(query))
+	if pageErr != nil {
+		return "", nil, pageErr
+	}
+	sql, args, err := builder.ToSql()
+	if err != nil {
+		return "", nil, err
+	}
+
+	// the window is in query order (reversed when paging backward)
+	window := strings.Join(pageOrderBy(query.sortKeys(), "page"), ", ")
+	forward := &FeatureQuery{SortBy: query.SortBy}
+	sql = fmt.Sprintf(
+		"SELECT * FROM (SELECT page.*, "+
+			"row_number() OVER w AS page_position, "+
+			"count(*) OVER w AS page_rows, "+
+			"first_value(page.sort_values) OVER w AS page_first, "+
+			"COALESCE(nth_value(page.sort_values, %d) OVER w, last_value(page.sort_values) OVER w) AS page_last "+
+			"FROM (%s) AS page "+
+			"WINDOW w AS (ORDER BY %s ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING)) AS page "+
+			"WHERE page.page_position <= %d ORDER BY %s",
+		query.Limit, sql, window, query.Limit, strings.Join(pageOrderBy(forward.sortKeys(), "page"), ", "),
+	)
+
+	return sql, args, nil
+}
+
+// pageRow is a feature selected with a description of its page
+type pageRow struct {
+	Feature
+	// Position of the feature in query order
+	Position int64 `db:"page_position"`
+	// Rows is the number of features found for the page (one more than the
+	// limit if there are more features)
+	Rows uint64 `db:"page_rows"`
+	// First has the sort values of the first feature in query order
+	First SortValues `db:"page_first"`
+	// Last has the sort values of the last feature of the page in query order
+	Last SortValues `db:"page_last"`
+}
+
+// describe sets the features returned by a page from one of its rows
+func (page *Page) describe(row *pageRow, query *FeatureQuery) {
+	page.Returned = int(row.Rows)
+	if row.Rows > query.Limit {
+		page.Returned = int(query.Limit)
+		page.More = true
+	}
+	page.First = row.First
+	page.Last = row.Last
+	if query.backward() {
+		page.First, page.Last = page.Last, page.First
+	}
+}
+
+// PropertyTypes returns the JSON type of each property of the features that
+// match the stream query (as named by jsonb_typeof).  Properties with values
+// of more than one type (other than null) have the type "mixed".  Types are
+// read in the same snapshot as the features (this must be called before the
+// stream is opened).
+func (stream *FeatureStream) PropertyTypes() (map[string]string, error) {
+	query := stream.query
+	property := "property"
+	builder := builder.
+		Select(column(property, "key"), fmt.Sprintf("jsonb_typeof(%s)", column(property, "value"))).
+		From(fmt.Sprintf("%s, jsonb_each(%s) AS %s", featureTable, column(featureTable, "properties"), property))
+
+	if query.PropertyNames != nil {
+		builder = builder.Where(sq.Eq{column(property, "key"): query.PropertyNames})
+	}
+
+	sql, args, sqlErr := query.filter(builder).GroupBy("1", "2").ToSql()
+	if sqlErr != nil {
+		return nil, sqlErr
+	}
+
+	rows, queryErr := stream.tx.Queryx(sql, args...)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+	defer rows.Close()
+
+	types := map[string]string{}
+	for rows.Next() {
+		var key, valueType string
+		if err := rows.Scan(&key, &valueType); err != nil {
+			return nil, err
+		}
+		existing, ok := types[key]
+		switch {
+		case !ok || existing == "null":
+			types[key] = valueType
+		case valueType != "null" && valueType != existing:
+			types[key] = "mixed"
+		}
+	}
+	return types, rows.Err()
+}
+
+// SpatialReference returns the WKT definition of a spatial reference system
+// from the PostGIS spatial_ref_sys table (or an empty string if there is
+// none).  This must be called before the stream is opened.
+func (stream *FeatureStream) SpatialReference(srid int) (string, error) {
+	var definition sql.NullString
+	err := stream.tx.Get(&definition, "SELECT srtext FROM spatial_ref_sys WHERE srid = $1", srid)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return definition.String, err
+}
+
+// Open selects the features and reads the first one, so that an error in the
+// query is returned before any feature is written.  Other queries (like
+// PropertyTypes) must be made before this.  The stream is opened by the first
+// call to Next if it is not already open.
+func (stream *FeatureStream) Open() error {
+	if stream.rows != nil || stream.err != nil {
+		return stream.Err()
+	}
+	rows, err := stream.tx.Queryx(stream.sql, stream.args...)
+	if err != nil {
+		stream.err = err
+		return err
+	}
+	stream.rows = rows
+	stream.ready = rows.Next()
+	if !stream.ready || stream.query.All {
+		return rows.Err()
+	}
+
+	// the first row describes the page
+	row := &pageRow{}
+	if err := rows.StructScan(row); err != nil {
+		return err
+	}
+	stream.Page.describe(row, stream.query)
+	return nil
+}
+
+// Next prepares the next feature to be read with Scan
+func (stream *FeatureStream) Next() bool {
+	if stream.rows == nil && stream.Open() != nil {
+		return false
+	}
+	if stream.ready {
+		stream.ready = false
+		return true
+	}
+	return stream.rows.Next()
+}
+
+// Scan reads the current feature
+func (stream *FeatureStream) Scan(feature *Feature) error {
+	if stream.rows == nil {
+		return errors.New("stream is not open")
+	}
+	if stream.query.All {
+		return stream.rows.StructScan(feature)
+	}
+	row := &pageRow{}
+	if err := stream.rows.StructScan(row); err != nil {
+		return err
+	}
+	*feature = row.Feature
+	return nil
+}
+
+// Err returns any error encountered while iterating
+func (stream *FeatureStream) Err() error {
+	if stream.rows == nil {
+		return stream.err
+	}
+	return stream.rows.Err()
+}
+
+// Close releases the resources used by the stream
+func (stream *FeatureStream) Close() error {
+	if stream.rows != nil {
+		if err := stream.rows.Close(); err != nil {
+			stream.tx.Rollback()
+			return err
+		}
+	}
+	return stream.tx.Rollback()
+}
@@ -0,0 +1,195 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readStream(t *testing.T, stream *FeatureStream) []float64 {
+	ranks := []float64{}
+	for stream.Next() {
+		feature := &Feature{}
+		if err := stream.Scan(feature); err != nil {
+			t.Fatal(err)
+		}
+		ranks = append(ranks, feature.Properties["rank"].(float64))
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return ranks
+}
+
+func TestStream(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	assert.Nil(Insert(db, &Collection{Name: "places", Title: "places", Description: "places", Sortables: []string{"rank"}}))
+
+	features := Features{}
+	for _, rank := range []float64{2, 1, 4, 3, 5} {
+		features = append(features, &Feature{
+			CollectionName: "places",
+			Geometry:       mustGeometry(t, `{"type":"Point","coordinates":[1,2]}`),
+			Properties:     PropertyMap{"rank": rank},
+		})
+	}
+	assert.Nil(BulkInsert(db, &features))
+
+	sortBy := []SortKey{{Property: "rank"}}
+	stream, err := Stream(db, &FeatureQuery{Collection: Collection{Name: "places"}, Limit: 2, SortBy: sortBy})
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Equal([]float64{1, 2}, readStream(t, stream))
+	assert.Equal(uint64(5), stream.Page.Matched)
+	assert.Equal(2, stream.Page.Returned)
+	assert.True(stream.Page.More)
+	assert.Nil(stream.Close())
+
+	last := stream.Page.Last
+	stream, err = Stream(db, &FeatureQuery{Collection: Collection{Name: "places"}, Limit: 10, SortBy: sortBy, Cursor: &Cursor{Values: last}})
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Equal([]float64{3, 4, 5}, readStream(t, stream))
+	assert.False(stream.Page.More)
+	assert.Nil(stream.Close())
+
+	stream, err = Stream(db, &FeatureQuery{Collection: Collection{Name: "places"}, Limit: 2, SortBy: sortBy, Cursor: &Cursor{Values: stream.Page.Last, Backward: true}})
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Equal([]float64{3, 4}, readStream(t, stream))
+	assert.True(stream.Page.More)
+	assert.Equal(3.0, mustRank(t, stream.Page.First))
+	assert.Equal(4.0, mustRank(t, stream.Page.Last))
+	assert.Nil(stream.Close())
+}
+
+func mustRank(t *testing.T, values SortValues) float64 {
+	var rank float64
+	if err := json.Unmarshal(values[0], &rank); err != nil {
+		t.Fatal(err)
+	}
+	return rank
+}
+
+func TestStreamAll(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	assert.Nil(Insert(db, &Collection{Name: "places", Title: "places", Description: "places"}))
+
+	features := Features{}
+	for _, properties := range []PropertyMap{
+		{"rank": 1.0, "name": "one", "open": true},
+		{"rank": 2.0, "name": 2.0, "tags": []interface{}{"a"}},
+		{"rank": 3.0, "name": nil, "open": nil},
+	} {
+		features = append(features, &Feature{
+			CollectionName: "places",
+			Geometry:       mustGeometry(t, `{"type":"Point","coordinates":[1,2]}`),
+			Properties:     properties,
+		})
+	}
+	assert.Nil(BulkInsert(db, &features))
+
+	stream, err := Stream(db, &FeatureQuery{Collection: Collection{Name: "places"}, Limit: 1, All: true})
+	if !assert.Nil(err) {
+		return
+	}
+	defer stream.Close()
+
+	types, typesErr := stream.PropertyTypes()
+	assert.Nil(typesErr)
+	assert.Equal(map[string]string{"rank": "number", "name": "mixed", "open": "boolean", "tags": "array"}, types)
+
+	assert.Equal([]float64{1, 2, 3}, readStream(t, stream))
+	assert.Equal(3, stream.Page.Returned)
+	assert.False(stream.Page.More)
+}
+
+func TestSelectPage(t *testing.T) {
+	assert := assert.New(t)
+
+	// one more feature than the limit is selected to find out if there are more
+	sql, _, err := selectPage(&FeatureQuery{Collection: Collection{Name: "places"}, Limit: 2})
+	assert.Nil(err)
+	assert.Contains(sql, "LIMIT 3) AS page")
+	assert.Contains(sql, "WHERE page.page_position <= 2 ORDER BY (page.sort_values->>0)::uuid ASC")
+
+	sql, _, err = selectPage(&FeatureQuery{Collection: Collection{Name: "places"}, Limit: 1, All: true})
+	assert.Nil(err)
+	assert.NotContains(sql, "LIMIT")
+	assert.NotContains(sql, "page_position")
+
+	query := &FeatureQuery{Collection: Collection{Name: "places"}}
+	sql, _, err = selectPage(query)
+	assert.Nil(err)
+	assert.Contains(sql, "LIMIT 501) AS page")
+	assert.Equal(defaultFeatureLimit, query.Limit)
+
+	// the window is in query order and the page is in forward order
+	sql, _, err = selectPage(&FeatureQuery{Collection: Collection{Name: "places"}, Limit: 2, Cursor: &Cursor{Values: SortValues{json.RawMessage(`"7b1e2c0e-4a55-4d55-9d46-1d4f8f0b9a10"`)}, Backward: true}})
+	assert.Nil(err)
+	assert.Contains(sql, "WINDOW w AS (ORDER BY (page.sort_values->>0)::uuid DESC ROWS")
+	assert.True(strings.HasSuffix(sql, "ORDER BY (page.sort_values->>0)::uuid ASC"), sql)
+
+	// bad cursors are reported instead of starting over
+	_, _, err = selectPage(&FeatureQuery{Collection: Collection{Name: "places"}, Cursor: &Cursor{Values: SortValues{json.RawMessage(`42`)}}})
+	_, ok := err.(*CursorError)
+	assert.True(ok)
+
+	_, _, err = selectPage(&FeatureQuery{Collection: Collection{Name: "places"}, All: true, Cursor: &Cursor{Values: SortValues{}}})
+	assert.Equal(ErrCursorMismatch, err)
+}
+
+func TestPageDescribe(t *testing.T) {
+	assert := assert.New(t)
+
+	first := SortValues{json.RawMessage("1")}
+	last := SortValues{json.RawMessage("2")}
+
+	page := &Page{Matched: 5}
+	page.describe(&pageRow{Rows: 3, First: first, Last: last}, &FeatureQuery{Limit: 2})
+	assert.Equal(&Page{Matched: 5, Returned: 2, More: true, First: first, Last: last}, page)
+
+	page = &Page{Matched: 2}
+	page.describe(&pageRow{Rows: 2, First: first, Last: last}, &FeatureQuery{Limit: 2})
+	assert.Equal(&Page{Matched: 2, Returned: 2, First: first, Last: last}, page)
+
+	// values are in query order when paging backward
+	page = &Page{Matched: 5}
+	page.describe(&pageRow{Rows: 1, First: last, Last: first}, &FeatureQuery{Limit: 2, Cursor: &Cursor{Backward: true}})
+	assert.Equal(&Page{Matched: 5, Returned: 1, First: first, Last: last}, page)
+}
+
+func TestStreamSpatialReference(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	assert.Nil(Insert(db, &Collection{Name: "places", Title: "places", Description: "places"}))
+
+	stream, err := Stream(db, &FeatureQuery{Collection: Collection{Name: "places"}, Limit: 1})
+	if !assert.Nil(err) {
+		return
+	}
+	defer stream.Close()
+
+	definition, definitionErr := stream.SpatialReference(3857)
+	assert.Nil(definitionErr)
+	assert.Contains(definition, "Pseudo-Mercator")
+
+	definition, definitionErr = stream.SpatialReference(999999)
+	assert.Nil(definitionErr)
+	assert.Equal("", definition)
+
+	assert.Empty(readStream(t, stream))
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tschaub/pgfs/pkg/geo"
+	sq "gopkg.in/Masterminds/squirrel.v1"
+)
+
+// tileExtent is the size of a vector tile in tile coordinates
+const tileExtent = 4096
+
+// tileBuffer is the number of tile coordinates that geometries extend past
+// the edges of a tile (so lines and polygons are drawn without seams)
+const tileBuffer = 64
+
+// TileQuery selects the features of a collection in a vector tile
+type TileQuery struct {
+	Collection Collection
+	Tile       *geo.Tile
+}
+
+// layer returns a query for a Mapbox Vector Tile layer with the features of
+// the collection.  Geometries are simplified to the tile resolution, so less
+// detail is included at lower zoom levels.  Only properties listed in the
+// collection's tile properties are included, along with the feature id.
+func (query *TileQuery) layer() sq.SelectBuilder {
+	bounds := query.Tile.Bounds()
+	srid := bounds.CRS.SRID
+	resolution := query.Tile.Size() / tileExtent
+	buffer := resolution * tileBuffer
+
+	envelope := "ST_MakeEnvelope(?, ?, ?, ?, ?)"
+	tolerance := strconv.FormatFloat(resolution, 'g', -1, 64)
+	geometry := fmt.Sprintf(
+		"ST_AsMVTGeom(ST_SimplifyPreserveTopology(ST_Transform(%s, %d), %s), %s, %d, %d, true)",
+		column(featureTable, "geometry"), srid, tolerance, envelope, tileExtent, tileBuffer,
+	)
+	geometryArgs := []interface{}{bounds.MinX, bounds.MinY, bounds.MaxX, bounds.MaxY, srid}
+
+	pairs := []string{}
+	propertyArgs := []interface{}{}
+	for _, name := range query.Collection.TileProperties {
+		pairs = append(pairs, fmt.Sprintf("?::text, %s->?", column(featureTable, "properties")))
+		propertyArgs = append(propertyArgs, name, name)
+	}
+	properties := fmt.Sprintf(
+		"jsonb_strip_nulls(jsonb_build_object(%s)) || jsonb_build_object('%s', %s)",
+		strings.Join(pairs, ", "), IDProperty, column(featureTable, "id"),
+	)
+
+	features := builder.
+		Select().
+		Column(alias(geometry, "geometry"), geometryArgs...).
+		Column(alias(properties, "properties"), propertyArgs...).
+		From(featureTable).
+		Where(sq.Eq{column(featureTable, "collection_name"): query.Collection.Name}).
+		Where(
+			fmt.Sprintf("%s && ST_Transform(%s, ?)", column(featureTable, "geometry"), envelope),
+			bounds.MinX-buffer, bounds.MinY-buffer, bounds.MaxX+buffer, bounds.MaxY+buffer, srid,
+			query.Collection.StorageCRS().SRID,
+		)
+
+	return builder.
+		Select().
+		Column("ST_AsMVT(layer.*, ?, ?, 'geometry')", query.Collection.Name, tileExtent).
+		FromSelect(features.PlaceholderFormat(sq.Question), "layer").
+		Where("layer.geometry IS NOT NULL")
+}
+
+// Tile returns a Mapbox Vector Tile with a layer for each query.  A tile with
+// several layers is the concatenation of single layer tiles.  The tile is
+// empty if there are no features in its bounds.
+func Tile(db *sql.DB, queries ...*TileQuery) ([]byte, error) {
+	if len(queries) == 0 {
+		return []byte{}, nil
+	}
+
+	layers := make([]string, len(queries))
+	args := []interface{}{}
+	for i, query := range queries {
+		layerSQL, layerArgs, layerErr := query.layer().PlaceholderFormat(sq.Question).ToSql()
+		if layerErr != nil {
+			return nil, layerErr
+		}
+		// a layer without features is null
+		layers[i] = fmt.Sprintf("COALESCE((%s), ''::bytea)", layerSQL)
+		args = append(args, layerArgs...)
+	}
+
+	sql, args, sqlErr := builder.Select().Column(sq.Expr(strings.Join(layers, " || "), args...)).ToSql()
+	if sqlErr != nil {
+		return nil, sqlErr
+	}
+
+	var tile []byte
+	if err := sqlx.Get(sqlx.NewDb(db, driverName), &tile, sql, args...); err != nil {
+		return nil, err
+	}
+	return tile, nil
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tschaub/pgfs/pkg/geo"
+)
+
+func mustTile(t *testing.T, set string, zoom, col, row int) *geo.Tile {
+	tile, err := geo.TileMatrixSets[set].NewTile(zoom, col, row)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tile
+}
+
+func TestTileLayer(t *testing.T) {
+	assert := assert.New(t)
+
+	query := &TileQuery{
+		Collection: Collection{Name: "places", TileProperties: []string{"name"}},
+		Tile:       mustTile(t, "WorldCRS84Quad", 0, 1, 0),
+	}
+
+	sql, args, err := query.layer().ToSql()
+	if !assert.Nil(err) {
+		return
+	}
+
+	assert.True(strings.HasPrefix(sql, "SELECT ST_AsMVT(layer.*, $1, $2, 'geometry') FROM (SELECT ST_AsMVTGeom("), sql)
+	assert.Contains(sql, "ST_SimplifyPreserveTopology(ST_Transform(features.geometry, 4326), 0.0439453125)")
+	assert.Contains(sql, "jsonb_build_object($8::text, features.properties->$9)")
+	assert.True(strings.HasSuffix(sql, ") AS layer WHERE layer.geometry IS NOT NULL"), sql)
+	assert.NotContains(sql, "?")
+
+	// layer name and extent, tile bounds, property names, collection name, buffered bounds
+	assert.Equal([]interface{}{
+		"places", tileExtent,
+		0.0, -90.0, 180.0, 90.0, 4326,
+		"name", "name",
+		"places",
+		-2.8125, -92.8125, 182.8125, 92.8125, 4326, 4326,
+	}, args)
+}
+
+func TestTile(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	assert.Nil(Insert(db, &Collection{Name: "places", Title: "places", Description: "places", TileProperties: []string{"name"}}))
+
+	features := Features{{
+		CollectionName: "places",
+		Geometry:       mustGeometry(t, `{"type":"Point","coordinates":[10,20]}`),
+		Properties:     PropertyMap{"name": "one", "secret": "hidden"},
+	}}
+	assert.Nil(BulkInsert(db, &features))
+
+	collection := &Collection{Name: "places"}
+	assert.Nil(Get(db, collection))
+
+	tile, err := Tile(db, &TileQuery{Collection: *collection, Tile: mustTile(t, "WebMercatorQuad", 1, 1, 0)})
+	assert.Nil(err)
+	assert.NotEmpty(tile)
+	assert.Contains(string(tile), "places")
+	assert.Contains(string(tile), "one")
+	assert.NotContains(string(tile), "hidden")
+
+	tile, err = Tile(db, &TileQuery{Collection: *collection, Tile: mustTile(t, "WebMercatorQuad", 1, 0, 1)})
+	assert.Nil(err)
+	assert.Empty(tile)
+
+	assert.Nil(Insert(db, &Collection{Name: "roads", Title: "roads", Description: "roads"}))
+	roads := Features{{
+		CollectionName: "roads",
+		Geometry:       mustGeometry(t, `{"type":"LineString","coordinates":[[10,20],[11,21]]}`),
+		Properties:     PropertyMap{},
+	}}
+	assert.Nil(BulkInsert(db, &roads))
+
+	tileBounds := mustTile(t, "WebMercatorQuad", 1, 1, 0)
+	placesTile, placesErr := Tile(db, &TileQuery{Collection: *collection, Tile: tileBounds})
+	assert.Nil(placesErr)
+	roadsTile, roadsErr := Tile(db, &TileQuery{Collection: Collection{Name: "roads"}, Tile: tileBounds})
+	assert.Nil(roadsErr)
+
+	// layers are concatenated
+	tile, err = Tile(db, &TileQuery{Collection: *collection, Tile: tileBounds}, &TileQuery{Collection: Collection{Name: "roads"}, Tile: tileBounds})
+	assert.Nil(err)
+	assert.Equal(append(placesTile, roadsTile...), tile)
+
+	tile, err = Tile(db)
+	assert.Nil(err)
+	assert.Empty(tile)
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"github.com/jmoiron/sqlx"
+)
+
+// TileLayer is a collection drawn as a layer of the dataset vector tiles
+type TileLayer struct {
+	CollectionName string `db:"collection_name"`
+	// MinZoom and MaxZoom are the zoom levels where the layer is included
+	MinZoom int `db:"min_zoom"`
+	MaxZoom int `db:"max_zoom"`
+}
+
+// Tileset is the definition of the dataset vector tiles.  There is a single
+// tileset with layers in drawing order.  Layers are removed when their
+// collection is deleted.
+type Tileset struct {
+	Layers []*TileLayer
+}
+
+// Tileset implements the Record interface
+var _ Record = (*Tileset)(nil)
+
+var tileLayerTable = "tileset_layers"
+
+// Visible returns the layers included at a zoom level
+func (tileset *Tileset) Visible(zoom int) []*TileLayer {
+	layers := []*TileLayer{}
+	for _, layer := range tileset.Layers {
+		if zoom >= layer.MinZoom && zoom <= layer.MaxZoom {
+			layers = append(layers, layer)
+		}
+	}
+	return layers
+}
+
+// get reads the tileset layers
+func (tileset *Tileset) get(db *sqlx.DB) error {
+	sql, args, sqlErr := builder.
+		Select(
+			column(tileLayerTable, "collection_name"),
+			column(tileLayerTable, "min_zoom"),
+			column(tileLayerTable, "max_zoom")).
+		From(tileLayerTable).
+		OrderBy(column(tileLayerTable, "position")).ToSql()
+	if sqlErr != nil {
+		return sqlErr
+	}
+
+	layers := []*TileLayer{}
+	if err := db.Select(&layers, sql, args...); err != nil {
+		return err
+	}
+	tileset.Layers = layers
+	return nil
+}
+
+// insert replaces the tileset layers (there is only one tileset)
+func (tileset *Tileset) insert(db *sqlx.DB) error {
+	return tileset.update(db)
+}
+
+// update replaces the tileset layers
+func (tileset *Tileset) update(db *sqlx.DB) error {
+	tx, txErr := db.Beginx()
+	if txErr != nil {
+		return txErr
+	}
+
+	var err error
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	deleteSQL, deleteArgs, err := builder.Delete(tileLayerTable).ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(deleteSQL, deleteArgs...); err != nil {
+		return err
+	}
+
+	if len(tileset.Layers) > 0 {
+		insert := builder.
+			Insert(tileLayerTable).
+			Columns("collection_name", "position", "min_zoom", "max_zoom")
+		for i, layer := range tileset.Layers {
+			insert = insert.Values(layer.CollectionName, i, layer.MinZoom, layer.MaxZoom)
+		}
+
+		var sql string
+		var args []interface{}
+		if sql, args, err = insert.ToSql(); err != nil {
+			return err
+		}
+		if _, err = tx.Exec(sql, args...); err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+// delete removes all the tileset layers
+func (tileset *Tileset) delete(db *sqlx.DB) error {
+	sql, args, sqlErr := builder.Delete(tileLayerTable).ToSql()
+	if sqlErr != nil {
+		return sqlErr
+	}
+	_, err := db.Exec(sql, args...)
+	tileset.Layers = nil
+	return err
+}
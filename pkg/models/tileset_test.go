@@ -0,0 +1,57 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTilesetVisible(t *testing.T) {
+	assert := assert.New(t)
+
+	roads := &TileLayer{CollectionName: "roads", MinZoom: 6, MaxZoom: 24}
+	places := &TileLayer{CollectionName: "places", MinZoom: 2, MaxZoom: 12}
+	tileset := &Tileset{Layers: []*TileLayer{roads, places}}
+
+	assert.Equal([]*TileLayer{}, tileset.Visible(1))
+	assert.Equal([]*TileLayer{places}, tileset.Visible(2))
+	assert.Equal([]*TileLayer{roads, places}, tileset.Visible(12))
+	assert.Equal([]*TileLayer{roads}, tileset.Visible(13))
+}
+
+func TestTileset(t *testing.T) {
+	db, cleanup := testDB(t)
+	defer cleanup()
+
+	assert := assert.New(t)
+	for _, name := range []string{"roads", "places"} {
+		assert.Nil(Insert(db, &Collection{Name: name, Title: name, Description: name}))
+	}
+
+	tileset := &Tileset{}
+	assert.Nil(Get(db, tileset))
+	assert.Empty(tileset.Layers)
+
+	layers := []*TileLayer{
+		{CollectionName: "roads", MinZoom: 6, MaxZoom: 24},
+		{CollectionName: "places", MinZoom: 2, MaxZoom: 12},
+	}
+	assert.Nil(Update(db, &Tileset{Layers: layers}))
+	assert.Nil(Get(db, tileset))
+	assert.Equal(layers, tileset.Layers)
+
+	// replacing the layers keeps their new order
+	assert.Nil(Update(db, &Tileset{Layers: []*TileLayer{layers[1], layers[0]}}))
+	assert.Nil(Get(db, tileset))
+	assert.Equal([]*TileLayer{layers[1], layers[0]}, tileset.Layers)
+
+	// layers must be existing collections
+	assert.NotNil(Update(db, &Tileset{Layers: []*TileLayer{{CollectionName: "bogus"}}}))
+	assert.Nil(Get(db, tileset))
+	assert.Len(tileset.Layers, 2)
+
+	// deleting a collection removes its layer
+	assert.Nil(Delete(db, &Collection{Name: "roads"}))
+	assert.Nil(Get(db, tileset))
+	assert.Equal([]*TileLayer{layers[1]}, tileset.Layers)
+}
@@ -1,6 +1,10 @@
 package models
 
-import "fmt"
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
 
 func column(table, name string) string {
 	return fmt.Sprintf("%s.%s", table, name)
@@ -9,3 +13,24 @@ func column(table, name string) string {
 func alias(name, alias string) string {
 	return fmt.Sprintf("%s as %s", name, alias)
 }
+
+// requireRows returns sql.ErrNoRows if no rows were affected
+func requireRows(result sql.Result) error {
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// quoteLiteral quotes a string for use as a SQL literal
+func quoteLiteral(literal string) string {
+	literal = strings.Replace(literal, `'`, `''`, -1)
+	if strings.Contains(literal, `\`) {
+		return ` E'` + strings.Replace(literal, `\`, `\\`, -1) + `'`
+	}
+	return `'` + literal + `'`
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Policies for features with invalid geometries
+const (
+	// ValidationReject refuses to save invalid geometries
+	ValidationReject = "reject"
+	// ValidationWarn saves invalid geometries and reports why they are invalid
+	ValidationWarn = "warn"
+	// ValidationRepair saves invalid geometries after repairing them with ST_MakeValid
+	ValidationRepair = "repair"
+)
+
+// Validity is the result of validating the geometry of a feature
+type Validity struct {
+	Valid    bool
+	Reason   string
+	Repaired bool
+}
+
+// validitySQL reports if a geometry is valid and the type it would have if repaired
+var validitySQL = `
+SELECT
+	ST_IsValid(input.geometry) AS valid,
+	ST_IsValidReason(input.geometry) AS reason,
+	GeometryType(ST_MakeValid(input.geometry)) AS repaired_type
+FROM (SELECT %s AS geometry) AS input
+`
+
+type validityResult struct {
+	Valid        bool   `db:"valid"`
+	Reason       string `db:"reason"`
+	RepairedType string `db:"repaired_type"`
+}
+
+// validateGeometry checks the geometry of a feature and applies the validation
+// policy of its collection.  The feature's Validity is set if the geometry
+// can be saved, otherwise an error describes the problem.
+func validateGeometry(db sqlx.Queryer, collection *Collection, feature *Feature) error {
+	if err := feature.Geometry.Validate(); err != nil {
+		return err
+	}
+
+	feature.Validity = nil
+	sql, args, err := geometryValue(feature).ToSql()
+	if err != nil {
+		return err
+	}
+
+	result := &validityResult{}
+	if err := sqlx.Get(db, result, fmt.Sprintf(validitySQL, sql), args...); err != nil {
+		return err
+	}
+
+	if result.Valid {
+		feature.Validity = &Validity{Valid: true}
+		return nil
+	}
+
+	switch collection.Validation {
+	case ValidationReject:
+		return fmt.Errorf("invalid geometry: %s", result.Reason)
+	case ValidationRepair:
+		if collection.GeometryType != "" && !strings.EqualFold(result.RepairedType, collection.GeometryType) {
+			return fmt.Errorf("invalid geometry (%s) cannot be repaired as a %s", result.Reason, collection.GeometryType)
+		}
+		feature.Validity = &Validity{Reason: result.Reason, Repaired: true}
+		return nil
+	}
+
+	feature.Validity = &Validity{Reason: result.Reason}
+	return nil
+}
@@ -0,0 +1,88 @@
+package temporal
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// Interval is a closed time interval.  A nil Start or End means the
+// interval is unbounded on that side.
+type Interval struct {
+	Start *time.Time
+	End   *time.Time
+}
+
+// Instant returns true if the interval starts and ends at the same time
+func (interval *Interval) Instant() bool {
+	return interval.Start != nil && interval.End != nil && interval.Start.Equal(*interval.End)
+}
+
+// ParseInterval parses an instant (e.g. 2018-02-12T23:20:50Z or 2018-02-12)
+// or an interval with a slash between the start and end (e.g.
+// 2018-02-12T00:00:00Z/2018-03-18T12:31:12Z).  Either end of an interval
+// may be ".." or empty to leave it unbounded.  A date covers the whole day.
+func ParseInterval(value string) (*Interval, error) {
+	parts := strings.Split(value, "/")
+
+	if len(parts) == 1 {
+		start, end, err := parseTime(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		return &Interval{Start: &start, End: &end}, nil
+	}
+
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("cannot parse '%s' as an instant or interval", value)
+	}
+
+	interval := &Interval{}
+	if !unbounded(parts[0]) {
+		start, _, err := parseTime(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		interval.Start = &start
+	}
+
+	if !unbounded(parts[1]) {
+		_, end, err := parseTime(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		interval.End = &end
+	}
+
+	if interval.Start == nil && interval.End == nil {
+		return nil, errors.New("interval must have a start or an end")
+	}
+
+	if interval.Start != nil && interval.End != nil && interval.Start.After(*interval.End) {
+		return nil, errors.New("interval start must not be after the end")
+	}
+
+	return interval, nil
+}
+
+func unbounded(value string) bool {
+	return value == "" || value == ".."
+}
+
+// parseTime returns the first and last instant represented by the value
+func parseTime(value string) (time.Time, time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, t, nil
+	}
+
+	day, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("cannot parse '%s' as a date or date-time", value)
+	}
+
+	// the database stores microseconds
+	return day, day.AddDate(0, 0, 1).Add(-time.Microsecond), nil
+}
@@ -0,0 +1,70 @@
+package temporal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseInterval(t *testing.T) {
+	assert := assert.New(t)
+
+	instant := time.Date(2018, 2, 12, 23, 20, 50, 0, time.UTC)
+	day := time.Date(2018, 2, 12, 0, 0, 0, 0, time.UTC)
+	endOfDay := day.AddDate(0, 0, 1).Add(-time.Microsecond)
+	later := time.Date(2018, 3, 18, 12, 31, 12, 0, time.UTC)
+
+	cases := []struct {
+		value string
+		start *time.Time
+		end   *time.Time
+	}{
+		{"2018-02-12T23:20:50Z", &instant, &instant},
+		{"2018-02-12", &day, &endOfDay},
+		{"2018-02-12T23:20:50Z/2018-03-18T12:31:12Z", &instant, &later},
+		{"2018-02-12/2018-02-12", &day, &endOfDay},
+		{"2018-02-12T23:20:50Z/..", &instant, nil},
+		{"2018-02-12T23:20:50Z/", &instant, nil},
+		{"../2018-03-18T12:31:12Z", nil, &later},
+		{"/2018-03-18T12:31:12Z", nil, &later},
+	}
+
+	for i, c := range cases {
+		interval, err := ParseInterval(c.value)
+		if !assert.Nil(err, "expected no error for case %d", i) {
+			continue
+		}
+		if c.start == nil {
+			assert.Nil(interval.Start, "expected open start for case %d", i)
+		} else if assert.NotNil(interval.Start, "expected start for case %d", i) {
+			assert.True(c.start.Equal(*interval.Start), "unexpected start for case %d", i)
+		}
+		if c.end == nil {
+			assert.Nil(interval.End, "expected open end for case %d", i)
+		} else if assert.NotNil(interval.End, "expected end for case %d", i) {
+			assert.True(c.end.Equal(*interval.End), "unexpected end for case %d", i)
+		}
+	}
+
+	interval, _ := ParseInterval("2018-02-12T23:20:50Z")
+	assert.True(interval.Instant())
+}
+
+func TestParseIntervalInvalid(t *testing.T) {
+	assert := assert.New(t)
+	cases := []string{
+		"",
+		"yesterday",
+		"2018-02-30",
+		"../..",
+		"/",
+		"2018-02-12/2018-02-11",
+		"2018-02-12/2018-02-13/2018-02-14",
+	}
+
+	for _, value := range cases {
+		_, err := ParseInterval(value)
+		assert.NotNil(err, "expected an error for '%s'", value)
+	}
+}